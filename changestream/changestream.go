@@ -0,0 +1,100 @@
+/**
+ * @Author KYIMH
+ * @Description
+ * @Date 2026/10/20 02:10
+ **/
+
+package changestream
+
+import (
+	"context"
+	"errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+var (
+	//the watched collection was dropped or renamed, the stream can not be resumed
+	ErrInvalidated = errors.New("changestream: stream invalidated")
+	//the event carries no full document, example: a delete, or an update without Config.FullDocument
+	ErrNoFullDocument = errors.New("changestream: event has no full document")
+	//the saved token is malformed or aged out of the oplog, see Config.RestartIfLost
+	ErrNotResumable = errors.New("changestream: stream can not be resumed")
+)
+
+//OperationType -> operationType of a change event
+type OperationType string
+
+const (
+	Insert     OperationType = "insert"
+	Update     OperationType = "update"
+	Replace    OperationType = "replace"
+	Delete     OperationType = "delete"
+	Invalidate OperationType = "invalidate"
+)
+
+//Namespace -> database and collection of a change
+type Namespace struct {
+	Db   string `bson:"db"`
+	Coll string `bson:"coll"`
+}
+
+//UpdateDescription -> fields changed by an update
+type UpdateDescription struct {
+	UpdatedFields bson.M   `bson:"updatedFields"`
+	RemovedFields []string `bson:"removedFields"`
+}
+
+//Event -> one change of the watched collection
+//Token: resume token of the event, persisted by the TokenStore once the handler returned nil
+//FullDocument: the document after the change for inserts and replaces, for updates with Config.FullDocument
+type Event struct {
+	Token             bson.Raw            `bson:"_id"`
+	OperationType     OperationType       `bson:"operationType"`
+	Namespace         Namespace           `bson:"ns"`
+	DocumentKey       bson.M              `bson:"documentKey"`
+	FullDocument      bson.Raw            `bson:"fullDocument"`
+	UpdateDescription *UpdateDescription  `bson:"updateDescription"`
+	ClusterTime       primitive.Timestamp `bson:"clusterTime"`
+}
+
+//Handler -> callback of every event, a returned error stops the watcher before the token of the event is saved
+//so the event is delivered again after a restart, handlers must be idempotent
+type Handler func(event Event) error
+
+//TokenStore -> where the resume token of a watcher is persisted
+type TokenStore interface {
+	//token saved under name, nil if none
+	Load(name string) (bson.Raw, error)
+	Save(name string, token bson.Raw) error
+}
+
+//Config -> change stream watcher config
+//Name: name of the consumer, key of its resume token, consumers with different names keep their own position
+//DbName: pool name of the watched collection, staict_const.Chat if empty
+//Operations: operation types to deliver, every type if empty
+//Pipeline: extra stages after the Operations filter, example: []bson.M{{"$match": bson.M{"fullDocument.to_id": uid}}},
+//invalidate events always pass its $match stages, other stages must keep them and their operationType or a dropped collection is never noticed
+//FullDocument: look the current document up for update events
+//SaveEvery: save the token after that many handled events, 1 if 0, a larger value saves round trips and redelivers up to SaveEvery-1 events after a crash
+//RetryInterval: wait before reopening a failed stream, 1s if 0
+//RestartIfLost: start from the current time when the saved token can not be resumed, the events in between are skipped, Run returns ErrNotResumable otherwise
+type Config struct {
+	Name          string
+	DbName        string
+	Operations    []OperationType
+	Pipeline      []bson.M
+	FullDocument  bool
+	SaveEvery     int
+	RetryInterval time.Duration
+	RestartIfLost bool
+}
+
+//change stream watcher operators
+type Watcher interface {
+	//watch until ctx is done, the handler fails, the stream is invalidated or can not be resumed, failed streams are reopened
+	Run(ctx context.Context) error
+	Start() error
+	Stop()
+}
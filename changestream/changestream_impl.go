@@ -0,0 +1,342 @@
+/**
+ * @Author KYIMH
+ * @Description
+ * @Date 2026/10/20 02:20
+ **/
+
+package changestream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/KYIMH/CCS_Utils/mongo"
+	"github.com/KYIMH/CCS_Utils/share/enum/staict_const"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	driver "go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"sync"
+	"time"
+)
+
+var _ Watcher = (*WatcherImpl)(nil)
+
+//server error codes of a resume token that can not be used again
+//BadValue, FailedToParse: malformed token, InvalidResumeToken, ChangeStreamFatalError, ChangeStreamHistoryLost: token aged out of the oplog
+var nonResumableCodes = []int{2, 9, 260, 280, 286}
+
+//handlerError -> error returned by the handler, stops Run instead of reopening the stream
+type handlerError struct {
+	err error
+}
+
+func (e *handlerError) Error() string {
+
+	return "changestream handler: " + e.err.Error()
+}
+
+func (e *handlerError) Unwrap() error {
+
+	return e.err
+}
+
+//WatcherImpl -> change stream consumer of one pooled collection
+//events are handed to Handler one by one, the token of an event is saved once Handler returned nil
+type WatcherImpl struct {
+	Mongo   mongo.MogClient
+	Store   TokenStore
+	Config  Config
+	Handler Handler
+
+	mu     sync.Mutex
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+//create new change stream watcher, zero fields of config take the defaults
+//example: w := NewWatcher(mongoCli, NewRedisStore(redisCli, "ccs", ""), Config{Name: "push", Operations: []OperationType{Insert}}, onMsg)
+func NewWatcher(mongoCli mongo.MogClient, store TokenStore, config Config, handler Handler) *WatcherImpl {
+
+	if "" == config.DbName {
+		config.DbName = staict_const.Chat
+	}
+	if config.SaveEvery <= 0 {
+		config.SaveEvery = 1
+	}
+	if 0 == config.RetryInterval {
+		config.RetryInterval = time.Second
+	}
+
+	return &WatcherImpl{
+		Mongo:   mongoCli,
+		Store:   store,
+		Config:  config,
+		Handler: handler,
+	}
+}
+
+//watch until ctx is done (nil returned), the handler fails, the stream is invalidated or can not be resumed
+//the stream resumes after the saved token, a failed stream is reopened after RetryInterval
+func (w *WatcherImpl) Run(ctx context.Context) error {
+
+	err := w.check()
+	if nil != err {
+		return err
+	}
+
+	token, err := w.Store.Load(w.Config.Name)
+	if nil != err {
+		return err
+	}
+	if nil != token {
+		if vErr := token.Validate(); nil != vErr {
+			err = fmt.Errorf("%w: saved token of %s: %s", ErrNotResumable, w.Config.Name, vErr.Error())
+			token, err = w.restart(token, err)
+			if nil != err {
+				return err
+			}
+		}
+	}
+
+	for {
+		token, err = w.watch(ctx, token)
+		if nil != ctx.Err() {
+			return nil
+		}
+
+		var hErr *handlerError
+		if errors.As(err, &hErr) || errors.Is(err, ErrInvalidated) {
+			return err
+		}
+		//reopening the stream repeats the error, only dropping the token can help
+		if notResumable(err) {
+			if nil == token {
+				return err
+			}
+			token, err = w.restart(token, fmt.Errorf("%w: %s", ErrNotResumable, err.Error()))
+			if nil != err {
+				return err
+			}
+			continue
+		}
+		if nil != err {
+			logrus.Error("changestream Error! name:", w.Config.Name, "Details:", err.Error())
+		}
+
+		select {
+		case <-time.After(w.Config.RetryInterval):
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+//drop token and start from now if Config.RestartIfLost, err otherwise
+func (w *WatcherImpl) restart(token bson.Raw, err error) (bson.Raw, error) {
+
+	if !w.Config.RestartIfLost {
+		return token, err
+	}
+
+	logrus.Warn("changestream restarts from now, events since the saved token are skipped! name:", w.Config.Name, "Details:", err.Error())
+
+	return nil, nil
+}
+
+//true when err is a server error that reopening the stream after the same token can not fix
+func notResumable(err error) bool {
+
+	var cmdErr driver.CommandError
+	if !errors.As(err, &cmdErr) {
+		return false
+	}
+	if cmdErr.HasErrorLabel("NonResumableChangeStreamError") {
+		return true
+	}
+
+	for _, code := range nonResumableCodes {
+		if cmdErr.HasErrorCode(code) {
+			return true
+		}
+	}
+
+	return false
+}
+
+//run in the background until Stop, calling Start twice is a no-op
+//the error that ends the background run is logged
+func (w *WatcherImpl) Start() error {
+
+	err := w.check()
+	if nil != err {
+		return err
+	}
+
+	w.mu.Lock()
+	if nil != w.cancel {
+		w.mu.Unlock()
+		return nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	w.cancel = cancel
+	w.done = make(chan struct{})
+	done := w.done
+	w.mu.Unlock()
+
+	go func() {
+		defer close(done)
+
+		err := w.Run(ctx)
+		if nil != err {
+			logrus.Error("changestream stopped! name:", w.Config.Name, "Details:", err.Error())
+		}
+	}()
+
+	return nil
+}
+
+//stop the background run and wait for it, the token of the last handled event is saved
+func (w *WatcherImpl) Stop() {
+
+	w.mu.Lock()
+	cancel, done := w.cancel, w.done
+	w.cancel, w.done = nil, nil
+	w.mu.Unlock()
+
+	if nil == cancel {
+		return
+	}
+	cancel()
+	<-done
+}
+
+func (w *WatcherImpl) check() error {
+
+	if "" == w.Config.Name {
+		return errors.New("changestream: empty watcher name")
+	}
+	if nil == w.Handler {
+		return errors.New("changestream: nil handler of " + w.Config.Name)
+	}
+	if nil == w.Mongo || nil == w.Store {
+		return errors.New("changestream: nil mongo client or token store of " + w.Config.Name)
+	}
+
+	return nil
+}
+
+//Operations filter followed by Config.Pipeline, invalidate events always pass the $match stages so Run can stop on them
+func (w *WatcherImpl) pipeline() []bson.M {
+
+	pipeline := make([]bson.M, 0, len(w.Config.Pipeline)+1)
+	if len(w.Config.Operations) > 0 {
+		ops := make([]string, 0, len(w.Config.Operations)+1)
+		for _, op := range w.Config.Operations {
+			ops = append(ops, string(op))
+		}
+		ops = append(ops, string(Invalidate))
+		pipeline = append(pipeline, bson.M{"$match": bson.M{"operationType": bson.M{"$in": ops}}})
+	}
+
+	for _, stage := range w.Config.Pipeline {
+		if match, ok := stage["$match"]; ok && len(stage) == 1 {
+			stage = bson.M{"$match": bson.M{"$or": bson.A{bson.M{"operationType": string(Invalidate)}, match}}}
+		}
+		pipeline = append(pipeline, stage)
+	}
+
+	return pipeline
+}
+
+//open one stream after token and hand its events to the handler, returns the last saved token
+func (w *WatcherImpl) watch(ctx context.Context, token bson.Raw) (bson.Raw, error) {
+
+	opts := options.ChangeStream()
+	if w.Config.FullDocument {
+		opts.SetFullDocument(options.UpdateLookup)
+	}
+	if nil != token {
+		opts.SetResumeAfter(token)
+	}
+
+	cs, err := w.Mongo.Watch(ctx, w.Config.DbName, w.pipeline(), opts)
+	if nil != err {
+		return token, err
+	}
+	defer cs.Close(context.Background())
+
+	//last handled token not saved yet
+	var last bson.Raw
+	pending := 0
+	save := func() error {
+
+		if 0 == pending {
+			return nil
+		}
+		err := w.Store.Save(w.Config.Name, last)
+		if nil != err {
+			return err
+		}
+		token, pending = last, 0
+		return nil
+	}
+
+	for cs.Next(ctx) {
+		var event Event
+		err = cs.Decode(&event)
+		if nil != err {
+			break
+		}
+
+		if Invalidate == event.OperationType {
+			err = ErrInvalidated
+			break
+		}
+
+		hErr := w.Handler(event)
+		if nil != hErr {
+			err = &handlerError{err: hErr}
+			break
+		}
+
+		last = event.Token
+		pending++
+		if pending >= w.Config.SaveEvery {
+			if sErr := save(); nil != sErr {
+				return token, sErr
+			}
+		}
+	}
+	if nil == err {
+		err = cs.Err()
+	}
+
+	if sErr := save(); nil != sErr {
+		logrus.Error("changestream save token Error! name:", w.Config.Name, "Details:", sErr.Error())
+	}
+
+	return token, err
+}
+
+//decode the full document of the event into v, a pointer
+func (e Event) Decode(v interface{}) error {
+
+	if len(e.FullDocument) == 0 {
+		return ErrNoFullDocument
+	}
+
+	return bson.Unmarshal(e.FullDocument, v)
+}
+
+//full document of the event as a chat message, for watchers of the chat collection
+func (e Event) ChatMsg() (*staict_const.ChatMsg, error) {
+
+	var msg staict_const.ChatMsg
+	err := e.Decode(&msg)
+	if nil != err {
+		return nil, err
+	}
+
+	return &msg, nil
+}
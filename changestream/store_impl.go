@@ -0,0 +1,116 @@
+/**
+ * @Author KYIMH
+ * @Description
+ * @Date 2026/10/20 02:30
+ **/
+
+package changestream
+
+import (
+	"errors"
+	"fmt"
+	"github.com/KYIMH/CCS_Utils/mongo"
+	"github.com/KYIMH/CCS_Utils/redis"
+	"go.mongodb.org/mongo-driver/bson"
+	"time"
+)
+
+const defaultPrefix = "changestream:"
+
+var (
+	_ TokenStore = (*RedisTokenStore)(nil)
+	_ TokenStore = (*MongoTokenStore)(nil)
+)
+
+//RedisTokenStore -> resume tokens as plain redis strings under Prefix + name, without expiry
+type RedisTokenStore struct {
+	Redis    redis.Dal
+	RedisTag string
+	Prefix   string
+}
+
+//create new redis token store, prefix is "changestream:" if empty
+func NewRedisStore(redisDal redis.Dal, redisTag string, prefix string) *RedisTokenStore {
+
+	if "" == prefix {
+		prefix = defaultPrefix
+	}
+
+	return &RedisTokenStore{
+		Redis:    redisDal,
+		RedisTag: redisTag,
+		Prefix:   prefix,
+	}
+}
+
+//token saved under name, read from the primary so a token saved just before a restart is seen
+func (s *RedisTokenStore) Load(name string) (bson.Raw, error) {
+
+	v, err := redis.PrimaryOf(s.Redis).RedisGetResult(s.RedisTag, s.Prefix+name)
+	if nil != err || nil == v {
+		return nil, err
+	}
+
+	str, ok := v.(string)
+	if !ok {
+		return nil, fmt.Errorf("changestream: token of %s is %T", name, v)
+	}
+
+	token := bson.Raw(str)
+	err = token.Validate()
+	if nil != err {
+		return nil, fmt.Errorf("changestream: token of %s: %w", name, err)
+	}
+
+	return token, nil
+}
+
+func (s *RedisTokenStore) Save(name string, token bson.Raw) error {
+
+	return s.Redis.RedisSet(s.RedisTag, s.Prefix+name, string(token), 0)
+}
+
+//tokenDoc -> one document of the token collection
+type tokenDoc struct {
+	Id        string    `bson:"_id"`
+	Token     bson.Raw  `bson:"token"`
+	UpdatedAt time.Time `bson:"updated_at"`
+}
+
+//MongoTokenStore -> resume tokens as documents {_id: name, token, updated_at} of the collection registered as DbName
+//keep the token collection out of the watched one, saving a token would emit another event
+type MongoTokenStore struct {
+	Mongo  mongo.MogDal
+	DbName string
+}
+
+//create new mongo token store on the collection registered as dbName
+func NewMongoStore(dal mongo.MogDal, dbName string) *MongoTokenStore {
+
+	return &MongoTokenStore{
+		Mongo:  dal,
+		DbName: dbName,
+	}
+}
+
+func (s *MongoTokenStore) Load(name string) (bson.Raw, error) {
+
+	var doc tokenDoc
+	err := s.Mongo.GetDoc(s.DbName, bson.M{"_id": name}, &doc)
+	if errors.Is(err, mongo.ErrNotFound) {
+		return nil, nil
+	}
+	if nil != err {
+		return nil, err
+	}
+
+	return doc.Token, nil
+}
+
+func (s *MongoTokenStore) Save(name string, token bson.Raw) error {
+
+	_, err := s.Mongo.Upsert(s.DbName, bson.M{"_id": name},
+		bson.M{"$set": bson.M{"token": token, "updated_at": time.Now()}})
+
+	return err
+}
@@ -0,0 +1,290 @@
+/**
+ * @Author KYIMH
+ * @Description
+ * @Date 2026/10/19 21:40
+ **/
+
+package main
+
+import (
+	"github.com/go-redis/redis"
+	"sort"
+	"strings"
+	"time"
+)
+
+//KeyInfo -> size facts of one key
+//Bytes: MEMORY USAGE, -1 if the server does not support it
+//Length: element count, string length for strings
+//TTL: remaining seconds, -1 if the key never expires
+type KeyInfo struct {
+	Key    string `json:"key"`
+	Type   string `json:"type"`
+	Bytes  int64  `json:"bytes"`
+	Length int64  `json:"length"`
+	TTL    int64  `json:"ttl"`
+}
+
+//PrefixStat -> keys and memory aggregated by key prefix
+type PrefixStat struct {
+	Prefix string `json:"prefix"`
+	Keys   int64  `json:"keys"`
+	Bytes  int64  `json:"bytes"`
+	NoTTL  int64  `json:"no_ttl"`
+}
+
+//Report -> result of one inspection
+type Report struct {
+	Tag         string       `json:"tag"`
+	Scanned     int64        `json:"scanned"`
+	TotalBytes  int64        `json:"total_bytes"`
+	NoTTLCount  int64        `json:"no_ttl_count"`
+	Elapsed     string       `json:"elapsed"`
+	ByMemory    []KeyInfo    `json:"by_memory"`
+	ByLength    []KeyInfo    `json:"by_length"`
+	NoTTL       []KeyInfo    `json:"no_ttl"`
+	Prefixes    []PrefixStat `json:"prefixes"`
+	MemoryUsage bool         `json:"memory_usage"`
+	prefixes    map[string]*PrefixStat
+}
+
+//Options -> inspection options
+//Match: SCAN MATCH pattern
+//Count: SCAN COUNT hint, also the pipeline batch size
+//Rate: max keys inspected per second, 0 means unthrottled
+//Limit: stop after Limit keys, 0 scans everything
+//Top: entries of every top list
+//Samples: MEMORY USAGE SAMPLES for collections
+//Separator, Depth: prefix of a key is its first Depth parts split by Separator
+type Options struct {
+	Match     string
+	Count     int64
+	Rate      int
+	Limit     int64
+	Top       int
+	Samples   int
+	Separator string
+	Depth     int
+}
+
+//scan the keyspace of cli in throttled batches and build the report
+func Inspect(cli *redis.Client, tag string, opts Options) (*Report, error) {
+
+	start := time.Now()
+	report := &Report{
+		Tag:         tag,
+		MemoryUsage: true,
+		prefixes:    make(map[string]*PrefixStat),
+	}
+
+	var cursor uint64
+	for {
+		keys, next, err := cli.Scan(cursor, opts.Match, opts.Count).Result()
+		if nil != err {
+			return nil, err
+		}
+
+		if opts.Limit > 0 && report.Scanned+int64(len(keys)) > opts.Limit {
+			keys = keys[:opts.Limit-report.Scanned]
+		}
+
+		infos, err := inspectBatch(cli, keys, opts.Samples)
+		if nil != err {
+			return nil, err
+		}
+		for _, info := range infos {
+			report.add(info, opts)
+		}
+
+		throttle(start, report.Scanned, opts.Rate)
+
+		cursor = next
+		if 0 == cursor || (opts.Limit > 0 && report.Scanned >= opts.Limit) {
+			break
+		}
+	}
+
+	report.finish()
+	report.Elapsed = time.Since(start).Round(time.Millisecond).String()
+
+	return report, nil
+}
+
+//sleep until done keys fit in rate keys per second since start
+func throttle(start time.Time, done int64, rate int) {
+
+	if rate <= 0 {
+		return
+	}
+
+	due := start.Add(time.Duration(done) * time.Second / time.Duration(rate))
+	if wait := time.Until(due); wait > 0 {
+		time.Sleep(wait)
+	}
+}
+
+//type, memory and ttl of keys in one pipeline, element counts in a second one
+func inspectBatch(cli *redis.Client, keys []string, samples int) ([]KeyInfo, error) {
+
+	if len(keys) == 0 {
+		return nil, nil
+	}
+
+	pipe := cli.Pipeline()
+	types := make([]*redis.StatusCmd, len(keys))
+	memory := make([]*redis.IntCmd, len(keys))
+	ttls := make([]*redis.DurationCmd, len(keys))
+	for i, key := range keys {
+		types[i] = pipe.Type(key)
+		memory[i] = pipe.MemoryUsage(key, samples)
+		ttls[i] = pipe.TTL(key)
+	}
+	//per command errors are read below, MEMORY USAGE fails on old servers
+	_, _ = pipe.Exec()
+
+	infos := make([]KeyInfo, 0, len(keys))
+	lengths := make([]*redis.IntCmd, 0, len(keys))
+	pipe = cli.Pipeline()
+	for i, key := range keys {
+		typ, err := types[i].Result()
+		if nil != err {
+			return nil, err
+		}
+		//expired or deleted between SCAN and TYPE
+		if "none" == typ {
+			continue
+		}
+
+		info := KeyInfo{Key: key, Type: typ, Bytes: -1, TTL: -1}
+		if bytes, err := memory[i].Result(); nil == err {
+			info.Bytes = bytes
+		}
+		if ttl, err := ttls[i].Result(); nil == err && ttl >= 0 {
+			info.TTL = int64(ttl / time.Second)
+		}
+		infos = append(infos, info)
+
+		switch typ {
+		case "string":
+			lengths = append(lengths, pipe.StrLen(key))
+		case "list":
+			lengths = append(lengths, pipe.LLen(key))
+		case "hash":
+			lengths = append(lengths, pipe.HLen(key))
+		case "set":
+			lengths = append(lengths, pipe.SCard(key))
+		case "zset":
+			lengths = append(lengths, pipe.ZCard(key))
+		case "stream":
+			lengths = append(lengths, pipe.XLen(key))
+		default:
+			lengths = append(lengths, nil)
+		}
+	}
+	_, _ = pipe.Exec()
+
+	for i := range infos {
+		if nil != lengths[i] {
+			infos[i].Length, _ = lengths[i].Result()
+		}
+	}
+
+	return infos, nil
+}
+
+func (r *Report) add(info KeyInfo, opts Options) {
+
+	r.Scanned++
+	if info.Bytes < 0 {
+		r.MemoryUsage = false
+	} else {
+		r.TotalBytes += info.Bytes
+	}
+
+	prefix := keyPrefix(info.Key, opts.Separator, opts.Depth)
+	stat, ok := r.prefixes[prefix]
+	if !ok {
+		stat = &PrefixStat{Prefix: prefix}
+		r.prefixes[prefix] = stat
+	}
+	stat.Keys++
+	if info.Bytes > 0 {
+		stat.Bytes += info.Bytes
+	}
+
+	if info.TTL < 0 {
+		r.NoTTLCount++
+		stat.NoTTL++
+		r.NoTTL = pushTop(r.NoTTL, info, opts.Top, byBytes)
+	}
+	r.ByMemory = pushTop(r.ByMemory, info, opts.Top, byBytes)
+	r.ByLength = pushTop(r.ByLength, info, opts.Top, byLength)
+}
+
+//sorted prefix list, biggest first
+func (r *Report) finish() {
+
+	r.Prefixes = make([]PrefixStat, 0, len(r.prefixes))
+	for _, stat := range r.prefixes {
+		r.Prefixes = append(r.Prefixes, *stat)
+	}
+	sort.Slice(r.Prefixes, func(i, j int) bool {
+		if r.Prefixes[i].Bytes != r.Prefixes[j].Bytes {
+			return r.Prefixes[i].Bytes > r.Prefixes[j].Bytes
+		}
+		if r.Prefixes[i].Keys != r.Prefixes[j].Keys {
+			return r.Prefixes[i].Keys > r.Prefixes[j].Keys
+		}
+		return r.Prefixes[i].Prefix < r.Prefixes[j].Prefix
+	})
+}
+
+//first depth parts of key split by sep, (none) when key has no separator
+func keyPrefix(key string, sep string, depth int) string {
+
+	parts := strings.Split(key, sep)
+	if len(parts) <= 1 {
+		return "(none)"
+	}
+	if depth > len(parts)-1 {
+		depth = len(parts) - 1
+	}
+
+	return strings.Join(parts[:depth], sep) + sep
+}
+
+func byBytes(a KeyInfo, b KeyInfo) bool {
+
+	if a.Bytes != b.Bytes {
+		return a.Bytes > b.Bytes
+	}
+
+	return a.Key < b.Key
+}
+
+func byLength(a KeyInfo, b KeyInfo) bool {
+
+	if a.Length != b.Length {
+		return a.Length > b.Length
+	}
+
+	return a.Key < b.Key
+}
+
+//insert info into the sorted top list, keeping at most n entries
+func pushTop(top []KeyInfo, info KeyInfo, n int, less func(a KeyInfo, b KeyInfo) bool) []KeyInfo {
+
+	i := sort.Search(len(top), func(i int) bool { return less(info, top[i]) })
+	if i >= n {
+		return top
+	}
+
+	top = append(top, KeyInfo{})
+	copy(top[i+1:], top[i:])
+	top[i] = info
+	if len(top) > n {
+		top = top[:n]
+	}
+
+	return top
+}
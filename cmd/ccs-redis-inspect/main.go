@@ -0,0 +1,165 @@
+/**
+ * @Author KYIMH
+ * @Description
+ * @Date 2026/10/19 21:40
+ **/
+
+//ccs-redis-inspect scans one redis tag and reports the biggest keys,
+//keys without TTL and memory by key prefix.
+//
+//	ccs-redis-inspect -config redis.json -tag chat -rate 500 -format table
+//	ccs-redis-inspect -addr 127.0.0.1:6379 -match 'ccs:*' -format json
+//
+//redis.json holds a list of redis.RedisConfig like the zk data of the services.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/KYIMH/CCS_Utils/redis"
+	"io"
+	"os"
+	"text/tabwriter"
+)
+
+func main() {
+
+	configPath := flag.String("config", "", "json file with a list of redis configs")
+	tag := flag.String("tag", "", "redis tag to inspect, required with -config")
+	addr := flag.String("addr", "127.0.0.1:6379", "redis address when -config is not set")
+	password := flag.String("password", "", "redis password when -config is not set")
+	db := flag.Int("db", 0, "redis db when -config is not set")
+
+	opts := Options{}
+	flag.StringVar(&opts.Match, "match", "", "SCAN MATCH pattern, every key if empty")
+	flag.Int64Var(&opts.Count, "count", 100, "SCAN COUNT hint and pipeline batch size")
+	flag.IntVar(&opts.Rate, "rate", 1000, "max keys per second, 0 disables throttling")
+	flag.Int64Var(&opts.Limit, "limit", 0, "stop after this many keys, 0 scans everything")
+	flag.IntVar(&opts.Top, "top", 20, "entries of every top list")
+	flag.IntVar(&opts.Samples, "samples", 5, "MEMORY USAGE SAMPLES for collections")
+	flag.StringVar(&opts.Separator, "sep", ":", "key prefix separator")
+	flag.IntVar(&opts.Depth, "depth", 2, "separator parts that make a prefix")
+	format := flag.String("format", "table", "output format: table or json")
+	flag.Parse()
+
+	if "table" != *format && "json" != *format {
+		fatal(errors.New("unknown -format " + *format))
+	}
+
+	config := redis.RedisConfig{RedisTag: "default", Addr: *addr, Password: *password, DB: *db, PoolSize: 1}
+	if "" != *configPath {
+		var err error
+		config, err = loadConfig(*configPath, *tag)
+		if nil != err {
+			fatal(err)
+		}
+		//one connection is enough and keeps the load on the server low
+		config.PoolSize = 1
+	}
+
+	cli := redis.NewClientWithConfig(config)
+	defer cli.Close()
+
+	err := cli.Ping().Err()
+	if nil != err {
+		fatal(err)
+	}
+
+	report, err := Inspect(cli, config.RedisTag, opts)
+	if nil != err {
+		fatal(err)
+	}
+
+	if "json" == *format {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(report)
+	} else {
+		err = writeTable(os.Stdout, report)
+	}
+	if nil != err {
+		fatal(err)
+	}
+}
+
+func fatal(err error) {
+
+	fmt.Fprintln(os.Stderr, "ccs-redis-inspect:", err.Error())
+	os.Exit(1)
+}
+
+//read a list of redis configs and pick tag
+func loadConfig(path string, tag string) (redis.RedisConfig, error) {
+
+	configs, err := redis.ReadConfigFile(path)
+	if nil != err {
+		return redis.RedisConfig{}, err
+	}
+
+	for _, config := range configs {
+		if config.RedisTag == tag {
+			return config, nil
+		}
+	}
+
+	return redis.RedisConfig{}, errors.New("no redis tag " + tag + " in " + path)
+}
+
+func writeTable(out io.Writer, r *Report) error {
+
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintf(w, "tag: %s\tscanned: %d\tno ttl: %d\telapsed: %s\n", r.Tag, r.Scanned, r.NoTTLCount, r.Elapsed)
+	if r.MemoryUsage {
+		fmt.Fprintf(w, "total memory: %s\n", humanBytes(r.TotalBytes))
+	} else {
+		fmt.Fprintln(w, "MEMORY USAGE not supported, memory columns are empty")
+	}
+
+	writeKeys(w, "biggest keys by memory", r.ByMemory)
+	writeKeys(w, "biggest keys by element count", r.ByLength)
+	writeKeys(w, "keys without ttl, biggest first", r.NoTTL)
+
+	fmt.Fprintln(w, "\nmemory by prefix")
+	fmt.Fprintln(w, "PREFIX\tKEYS\tMEMORY\tNO TTL")
+	for _, stat := range r.Prefixes {
+		fmt.Fprintf(w, "%s\t%d\t%s\t%d\n", stat.Prefix, stat.Keys, humanBytes(stat.Bytes), stat.NoTTL)
+	}
+
+	return w.Flush()
+}
+
+func writeKeys(w io.Writer, title string, keys []KeyInfo) {
+
+	fmt.Fprintln(w, "\n"+title)
+	fmt.Fprintln(w, "KEY\tTYPE\tMEMORY\tLENGTH\tTTL")
+	for _, info := range keys {
+		ttl := "-"
+		if info.TTL >= 0 {
+			ttl = fmt.Sprintf("%ds", info.TTL)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\n", info.Key, info.Type, humanBytes(info.Bytes), info.Length, ttl)
+	}
+}
+
+func humanBytes(n int64) string {
+
+	if n < 0 {
+		return "-"
+	}
+
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+
+	div, exp := int64(unit), 0
+	for v := n / unit; v >= unit; v /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f%ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,171 @@
+/**
+ * @Author KYIMH
+ * @Description
+ * @Date 2026/10/19 22:10
+ **/
+
+//ccs-redis-migrate copies keys matching a pattern from one redis tag to another
+//with DUMP/RESTORE, keeping TTLs.
+//
+//	ccs-redis-migrate -config redis.json -from chat -to chat2 -match 'ccs:sess:*' -verify -state sess.state
+//
+//with -state the cursor is saved after every batch and a rerun resumes from it,
+//the state file is removed once the scan finished.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/KYIMH/CCS_Utils/redis"
+	"io/ioutil"
+	"os"
+)
+
+//errUsage -> bad flags, the usage was printed
+var errUsage = errors.New("usage")
+
+func main() {
+
+	err := run()
+	if err == errUsage {
+		os.Exit(2)
+	}
+	if nil != err {
+		fmt.Fprintln(os.Stderr, "ccs-redis-migrate:", err.Error())
+		os.Exit(1)
+	}
+}
+
+//run the migration, errors are returned so the deferred Close runs before main exits
+func run() error {
+
+	configPath := flag.String("config", "", "json file with a list of redis configs")
+	from := flag.String("from", "", "source redis tag")
+	to := flag.String("to", "", "destination redis tag")
+	mode := flag.String("mode", "skip", "existing destination keys: skip or replace")
+	statePath := flag.String("state", "", "file keeping the cursor to resume an interrupted run")
+
+	options := redis.MigrateOptions{}
+	flag.StringVar(&options.Match, "match", "", "SCAN MATCH pattern, every key if empty")
+	flag.Int64Var(&options.Count, "count", 100, "SCAN COUNT hint and batch size")
+	flag.IntVar(&options.Rate, "rate", 1000, "max keys per second, 0 disables throttling")
+	flag.BoolVar(&options.Verify, "verify", false, "compare type and element count of every copied key")
+	flag.BoolVar(&options.DeleteSource, "delete", false, "delete copied keys from the source")
+	flag.Parse()
+
+	if "" == *configPath || "" == *from || "" == *to {
+		flag.Usage()
+		return errUsage
+	}
+	if *from == *to {
+		return errors.New("-from and -to must differ")
+	}
+
+	switch *mode {
+	case "skip":
+		options.Mode = redis.MigrateSkipExisting
+	case "replace":
+		options.Mode = redis.MigrateReplace
+	default:
+		return errors.New("unknown -mode " + *mode)
+	}
+
+	configs, err := redis.ReadConfigFile(*configPath)
+	if nil != err {
+		return err
+	}
+	selected := make([]redis.RedisConfig, 0, 2)
+	for _, config := range configs {
+		if config.RedisTag == *from || config.RedisTag == *to {
+			selected = append(selected, config)
+		}
+	}
+	cli := redis.NewClientImpl(selected...)
+	defer cli.Close()
+
+	previous := redis.MigrateResult{}
+	if "" != *statePath {
+		previous, err = readState(*statePath)
+		if nil != err {
+			return err
+		}
+		options.Cursor = previous.Cursor
+		if previous.Cursor != 0 {
+			fmt.Fprintf(os.Stderr, "resuming at cursor %d\n", previous.Cursor)
+		}
+		options.OnBatch = func(result redis.MigrateResult) {
+			err := writeState(*statePath, merge(previous, result))
+			if nil != err {
+				fmt.Fprintln(os.Stderr, "ccs-redis-migrate: save state:", err.Error())
+			}
+		}
+	}
+
+	result, err := redis.Migrate(cli, *from, *to, options)
+	total := merge(previous, result)
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(total)
+
+	if nil != err {
+		return err
+	}
+	if "" != *statePath {
+		_ = os.Remove(*statePath)
+	}
+	if len(total.Mismatched) > 0 {
+		return fmt.Errorf("%d keys failed verification", len(total.Mismatched))
+	}
+
+	return nil
+}
+
+//counters of an earlier run plus the current one
+func merge(previous redis.MigrateResult, current redis.MigrateResult) redis.MigrateResult {
+
+	current.Scanned += previous.Scanned
+	current.Copied += previous.Copied
+	current.Skipped += previous.Skipped
+	current.Verified += previous.Verified
+	current.Deleted += previous.Deleted
+	current.Mismatched = append(append([]string{}, previous.Mismatched...), current.Mismatched...)
+
+	return current
+}
+
+//saved state, an empty result if the file does not exist
+func readState(path string) (redis.MigrateResult, error) {
+
+	result := redis.MigrateResult{}
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return result, nil
+	}
+	if nil != err {
+		return result, err
+	}
+
+	err = json.Unmarshal(data, &result)
+
+	return result, err
+}
+
+//write through a temp file so a crash never leaves a torn state
+func writeState(path string, result redis.MigrateResult) error {
+
+	data, err := json.Marshal(result)
+	if nil != err {
+		return err
+	}
+
+	err = ioutil.WriteFile(path+".tmp", data, 0644)
+	if nil != err {
+		return err
+	}
+
+	return os.Rename(path+".tmp", path)
+}
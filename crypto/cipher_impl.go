@@ -0,0 +1,249 @@
+/**
+ * @Author KYIMH
+ * @Description
+ * @Date 2026/10/19 23:10
+ **/
+
+package crypto
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+)
+
+//envelope layout:
+//magic(4) | key id length(1) | key id | wrap nonce(12) | wrapped data key(32+16) | data nonce(12) | sealed payload
+//the header up to the wrapped data key is the associated data of the payload, so a swapped key id fails to open
+const (
+	envelopeMagic = "CCE1"
+	nonceSize     = 12
+	wrappedSize   = keySize + 16
+)
+
+//EnvelopeCipher -> AES-256-GCM envelope cipher over a KeyProvider
+type EnvelopeCipher struct {
+	Keys KeyProvider
+}
+
+//create envelope cipher
+func NewCipher(keys KeyProvider) *EnvelopeCipher {
+
+	return &EnvelopeCipher{Keys: keys}
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+
+	block, err := aes.NewCipher(key)
+	if nil != err {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+func randomBytes(n int) ([]byte, error) {
+
+	buf := make([]byte, n)
+	_, err := rand.Read(buf)
+	if nil != err {
+		return nil, err
+	}
+
+	return buf, nil
+}
+
+//seal dataKey with the current key, returns the envelope header
+func (c *EnvelopeCipher) wrap(dataKey []byte) ([]byte, error) {
+
+	id, kek, err := c.Keys.CurrentKey()
+	if nil != err {
+		return nil, err
+	}
+
+	gcm, err := newGCM(kek)
+	if nil != err {
+		return nil, err
+	}
+	nonce, err := randomBytes(nonceSize)
+	if nil != err {
+		return nil, err
+	}
+
+	var header bytes.Buffer
+	header.WriteString(envelopeMagic)
+	header.WriteByte(byte(len(id)))
+	header.WriteString(id)
+	header.Write(nonce)
+
+	//the key id is bound to the wrapped key as associated data
+	return gcm.Seal(header.Bytes(), nonce, dataKey, []byte(id)), nil
+}
+
+//envelope -> parsed envelope, slices point into the input
+type envelope struct {
+	keyId     string
+	wrapNonce []byte
+	wrapped   []byte
+	header    []byte
+	dataNonce []byte
+	sealed    []byte
+}
+
+func parseEnvelope(data []byte) (*envelope, error) {
+
+	if !bytes.HasPrefix(data, []byte(envelopeMagic)) || len(data) < len(envelopeMagic)+1 {
+		return nil, ErrInvalidEnvelope
+	}
+
+	pos := len(envelopeMagic)
+	idLen := int(data[pos])
+	pos++
+	if len(data) < pos+idLen+nonceSize+wrappedSize+nonceSize {
+		return nil, ErrInvalidEnvelope
+	}
+
+	e := &envelope{keyId: string(data[pos : pos+idLen])}
+	pos += idLen
+	e.wrapNonce = data[pos : pos+nonceSize]
+	pos += nonceSize
+	e.wrapped = data[pos : pos+wrappedSize]
+	pos += wrappedSize
+	e.header = data[:pos]
+	e.dataNonce = data[pos : pos+nonceSize]
+	e.sealed = data[pos+nonceSize:]
+
+	return e, nil
+}
+
+//open the data key of e
+func (c *EnvelopeCipher) unwrap(e *envelope) ([]byte, error) {
+
+	kek, err := c.Keys.Key(e.keyId)
+	if nil != err {
+		return nil, err
+	}
+
+	gcm, err := newGCM(kek)
+	if nil != err {
+		return nil, err
+	}
+
+	dataKey, err := gcm.Open(nil, e.wrapNonce, e.wrapped, []byte(e.keyId))
+	if nil != err {
+		return nil, ErrInvalidEnvelope
+	}
+
+	return dataKey, nil
+}
+
+//encrypt plain into a new envelope
+func (c *EnvelopeCipher) Encrypt(plain []byte) ([]byte, error) {
+
+	dataKey, err := randomBytes(keySize)
+	if nil != err {
+		return nil, err
+	}
+
+	header, err := c.wrap(dataKey)
+	if nil != err {
+		return nil, err
+	}
+
+	return seal(header, dataKey, plain)
+}
+
+//append the data nonce and the sealed payload to header
+func seal(header []byte, dataKey []byte, plain []byte) ([]byte, error) {
+
+	gcm, err := newGCM(dataKey)
+	if nil != err {
+		return nil, err
+	}
+	nonce, err := randomBytes(nonceSize)
+	if nil != err {
+		return nil, err
+	}
+
+	out := make([]byte, 0, len(header)+nonceSize+len(plain)+gcm.Overhead())
+	out = append(out, header...)
+	out = append(out, nonce...)
+
+	return gcm.Seal(out, nonce, plain, header), nil
+}
+
+//decrypt an envelope, ErrInvalidEnvelope if data is not one or was modified
+func (c *EnvelopeCipher) Decrypt(data []byte) ([]byte, error) {
+
+	e, err := parseEnvelope(data)
+	if nil != err {
+		return nil, err
+	}
+
+	dataKey, err := c.unwrap(e)
+	if nil != err {
+		return nil, err
+	}
+
+	gcm, err := newGCM(dataKey)
+	if nil != err {
+		return nil, err
+	}
+
+	plain, err := gcm.Open(nil, e.dataNonce, e.sealed, e.header)
+	if nil != err {
+		return nil, ErrInvalidEnvelope
+	}
+
+	return plain, nil
+}
+
+//true if data starts like an envelope, legacy plaintext does not
+func (c *EnvelopeCipher) IsEncrypted(data []byte) bool {
+
+	_, err := parseEnvelope(data)
+
+	return nil == err
+}
+
+//id of the key data is wrapped with
+func (c *EnvelopeCipher) KeyId(data []byte) (string, error) {
+
+	e, err := parseEnvelope(data)
+	if nil != err {
+		return "", err
+	}
+
+	return e.keyId, nil
+}
+
+//re-wrap data with the current key, changed is false when it already uses it
+//the payload is decrypted and sealed again because the header is its associated data
+func (c *EnvelopeCipher) Rewrap(data []byte) ([]byte, bool, error) {
+
+	e, err := parseEnvelope(data)
+	if nil != err {
+		return nil, false, err
+	}
+
+	current, _, err := c.Keys.CurrentKey()
+	if nil != err {
+		return nil, false, err
+	}
+	if current == e.keyId {
+		return data, false, nil
+	}
+
+	plain, err := c.Decrypt(data)
+	if nil != err {
+		return nil, false, err
+	}
+
+	rewrapped, err := c.Encrypt(plain)
+	if nil != err {
+		return nil, false, err
+	}
+
+	return rewrapped, true, nil
+}
@@ -0,0 +1,57 @@
+/**
+ * @Author KYIMH
+ * @Description
+ * @Date 2026/10/19 23:10
+ **/
+
+package crypto
+
+import (
+	"github.com/KYIMH/CCS_Utils/redis"
+	"github.com/KYIMH/CCS_Utils/share/codec"
+)
+
+//plain redis values are sealed by the client itself, example: redisCli.SetCipher(tag, cipher)
+var _ redis.ValueCipher = (Cipher)(nil)
+
+//EncryptedCodec -> codec.Codec sealing what Codec marshals, plug it into redis backed stores
+//example: session.Config{Codec: crypto.NewCodec(codec.JSON{}, cipher)}
+//values written before encryption was turned on are still readable
+type EncryptedCodec struct {
+	Codec  codec.Codec
+	Cipher Cipher
+}
+
+//create encrypted codec, inner codec.JSON if nil
+func NewCodec(inner codec.Codec, cipher Cipher) *EncryptedCodec {
+
+	if nil == inner {
+		inner = codec.JSON{}
+	}
+
+	return &EncryptedCodec{Codec: inner, Cipher: cipher}
+}
+
+func (e *EncryptedCodec) Marshal(v interface{}) ([]byte, error) {
+
+	data, err := e.Codec.Marshal(v)
+	if nil != err {
+		return nil, err
+	}
+
+	return e.Cipher.Encrypt(data)
+}
+
+func (e *EncryptedCodec) Unmarshal(data []byte, v interface{}) error {
+
+	if !e.Cipher.IsEncrypted(data) {
+		return e.Codec.Unmarshal(data, v)
+	}
+
+	plain, err := e.Cipher.Decrypt(data)
+	if nil != err {
+		return err
+	}
+
+	return e.Codec.Unmarshal(plain, v)
+}
@@ -0,0 +1,40 @@
+/**
+ * @Author KYIMH
+ * @Description
+ * @Date 2026/10/19 23:10
+ **/
+
+package crypto
+
+import (
+	"errors"
+)
+
+var (
+	//ErrKeyNotFound -> the key provider has no key of the requested id
+	ErrKeyNotFound = errors.New("crypto: key not found")
+	//ErrInvalidEnvelope -> data is not an envelope or was tampered with
+	ErrInvalidEnvelope = errors.New("crypto: invalid envelope")
+	//ErrMsgOperator -> an update operator would write msg in a way MongoDal cannot encrypt, like $push or $rename
+	ErrMsgOperator = errors.New("crypto: update operator on msg cannot be encrypted")
+)
+
+//KeyProvider -> source of key encryption keys
+//CurrentKey: id and 32 byte AES-256 key new envelopes are wrapped with
+//Key: key of id, ErrKeyNotFound if it is unknown, old keys must stay available until rotated away
+type KeyProvider interface {
+	CurrentKey() (id string, key []byte, err error)
+	Key(id string) ([]byte, error)
+}
+
+//envelope encryption operators
+//every Encrypt draws a fresh data key, seals the payload with it and wraps the data key
+//with the current key of the provider, Rewrap decrypts an envelope of an older key and
+//encrypts the payload again with a fresh data key under the current key
+type Cipher interface {
+	Encrypt(plain []byte) ([]byte, error)
+	Decrypt(data []byte) ([]byte, error)
+	IsEncrypted(data []byte) bool
+	KeyId(data []byte) (string, error)
+	Rewrap(data []byte) (rewrapped []byte, changed bool, err error)
+}
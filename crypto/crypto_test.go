@@ -0,0 +1,78 @@
+/**
+ * @Author KYIMH
+ * @Description
+ * @Date 2026/10/20 05:40
+ **/
+
+package crypto
+
+import (
+	"bytes"
+	"github.com/KYIMH/CCS_Utils/share/enum/staict_const"
+	"go.mongodb.org/mongo-driver/bson"
+	"testing"
+)
+
+func newTestDal(t *testing.T) *MongoDal {
+
+	keys, err := NewStaticKeys("k1", map[string][]byte{"k1": bytes.Repeat([]byte{7}, 32)})
+	if nil != err {
+		t.Fatal(err)
+	}
+
+	return &MongoDal{Cipher: NewCipher(keys)}
+}
+
+func TestEncryptOperator(t *testing.T) {
+
+	m := newTestDal(t)
+
+	plain := []byte("hello")
+	operator := bson.M{
+		"$set":         bson.M{"msg": plain, "status": 1},
+		"$setOnInsert": bson.D{{Key: "msg", Value: "hi"}},
+		"$inc":         bson.M{"seq": 1},
+		"$unset":       bson.M{"draft": ""},
+	}
+	encrypted, err := m.encryptOperator(operator)
+	if nil != err {
+		t.Fatal(err)
+	}
+
+	set := encrypted["$set"].(bson.M)
+	sealed, _ := set["msg"].([]byte)
+	if !m.Cipher.IsEncrypted(sealed) || 1 != set["status"] {
+		t.Fatalf("$set = %v", set)
+	}
+	if opened, err := m.Cipher.Decrypt(sealed); nil != err || "hello" != string(opened) {
+		t.Fatalf("Decrypt = %q, %v", opened, err)
+	}
+	onInsert := encrypted["$setOnInsert"].(bson.D)
+	if sealed, _ := onInsert[0].Value.([]byte); !m.Cipher.IsEncrypted(sealed) {
+		t.Fatalf("$setOnInsert = %v", onInsert)
+	}
+	//the caller's operator is left alone
+	if !bytes.Equal(plain, operator["$set"].(bson.M)["msg"].([]byte)) {
+		t.Fatal("operator modified")
+	}
+
+	msg := &staict_const.ChatMsg{ChatId: 1, Msg: []byte("struct")}
+	encrypted, err = m.encryptOperator(bson.M{"$set": msg})
+	if nil != err || !m.Cipher.IsEncrypted(encrypted["$set"].(*staict_const.ChatMsg).Msg) || "struct" != string(msg.Msg) {
+		t.Fatalf("$set of a ChatMsg = %v, %v", encrypted, err)
+	}
+
+	for _, rejected := range []bson.M{
+		{"$push": bson.M{"msg": "x"}},
+		{"$rename": bson.M{"draft": "msg"}},
+		{"$set": bson.M{"msg.0": 1}},
+		{"$set": bson.M{"msg": 42}},
+		{"$set": struct {
+			Msg string `bson:"msg"`
+		}{"x"}},
+	} {
+		if _, err := m.encryptOperator(rejected); err != ErrMsgOperator {
+			t.Fatalf("encryptOperator(%v) = %v", rejected, err)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+/**
+ * @Author KYIMH
+ * @Description
+ * @Date 2026/10/19 23:10
+ **/
+
+package crypto
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"sync"
+)
+
+const keySize = 32
+
+//StaticKeys -> KeyProvider over keys fixed at start
+//Current: id of the key new envelopes use
+//Keys: key id -> 32 byte key
+type StaticKeys struct {
+	Current string
+	Keys    map[string][]byte
+}
+
+//create static key provider, every key must be 32 bytes and current must be one of them
+func NewStaticKeys(current string, keys map[string][]byte) (*StaticKeys, error) {
+
+	err := checkKeys(current, keys)
+	if nil != err {
+		return nil, err
+	}
+
+	return &StaticKeys{Current: current, Keys: keys}, nil
+}
+
+func (s *StaticKeys) CurrentKey() (string, []byte, error) {
+
+	key, err := s.Key(s.Current)
+
+	return s.Current, key, err
+}
+
+func (s *StaticKeys) Key(id string) ([]byte, error) {
+
+	key, ok := s.Keys[id]
+	if !ok {
+		return nil, ErrKeyNotFound
+	}
+
+	return key, nil
+}
+
+func checkKeys(current string, keys map[string][]byte) error {
+
+	if _, ok := keys[current]; !ok {
+		return errors.New("crypto: current key " + current + " not in keys")
+	}
+
+	for id, key := range keys {
+		if "" == id || len(id) > 255 {
+			return errors.New("crypto: key id must be 1 to 255 bytes")
+		}
+		if len(key) != keySize {
+			return errors.New("crypto: key " + id + " is not 32 bytes")
+		}
+	}
+
+	return nil
+}
+
+//keyringFile -> json layout of a keyring file, keys are base64 std encoded
+//example: {"current": "2026-10", "keys": {"2026-04": "...", "2026-10": "..."}}
+type keyringFile struct {
+	Current string            `json:"current"`
+	Keys    map[string]string `json:"keys"`
+}
+
+//FileKeyring -> KeyProvider reading keys from a json file, Reload picks up a rotated file
+type FileKeyring struct {
+	Path string
+
+	mu   sync.RWMutex
+	keys *StaticKeys
+}
+
+//load keyring from path
+func NewFileKeyring(path string) (*FileKeyring, error) {
+
+	k := &FileKeyring{Path: path}
+	err := k.Reload()
+	if nil != err {
+		return nil, err
+	}
+
+	return k, nil
+}
+
+//read the file again, the old keys stay in use if the file is invalid
+func (k *FileKeyring) Reload() error {
+
+	data, err := ioutil.ReadFile(k.Path)
+	if nil != err {
+		return err
+	}
+
+	var file keyringFile
+	err = json.Unmarshal(data, &file)
+	if nil != err {
+		return err
+	}
+
+	keys := make(map[string][]byte, len(file.Keys))
+	for id, encoded := range file.Keys {
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if nil != err {
+			return errors.New("crypto: key " + id + " is not base64")
+		}
+		keys[id] = key
+	}
+
+	static, err := NewStaticKeys(file.Current, keys)
+	if nil != err {
+		return err
+	}
+
+	k.mu.Lock()
+	k.keys = static
+	k.mu.Unlock()
+
+	return nil
+}
+
+func (k *FileKeyring) CurrentKey() (string, []byte, error) {
+
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	return k.keys.CurrentKey()
+}
+
+func (k *FileKeyring) Key(id string) ([]byte, error) {
+
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	return k.keys.Key(id)
+}
@@ -0,0 +1,536 @@
+/**
+ * @Author KYIMH
+ * @Description
+ * @Date 2026/10/19 23:10
+ **/
+
+package crypto
+
+import (
+	"errors"
+	"github.com/KYIMH/CCS_Utils/mongo"
+	"github.com/KYIMH/CCS_Utils/share/enum/staict_const"
+	"github.com/qiniu/qmgo"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"reflect"
+	"strings"
+)
+
+//encrypt msg.Msg in place, an already encrypted Msg is left alone
+func EncryptChatMsg(c Cipher, msg *staict_const.ChatMsg) error {
+
+	if len(msg.Msg) == 0 || c.IsEncrypted(msg.Msg) {
+		return nil
+	}
+
+	sealed, err := c.Encrypt(msg.Msg)
+	if nil != err {
+		return err
+	}
+	msg.Msg = sealed
+
+	return nil
+}
+
+//decrypt msg.Msg in place, legacy plaintext is left alone
+func DecryptChatMsg(c Cipher, msg *staict_const.ChatMsg) error {
+
+	if !c.IsEncrypted(msg.Msg) {
+		return nil
+	}
+
+	plain, err := c.Decrypt(msg.Msg)
+	if nil != err {
+		return err
+	}
+	msg.Msg = plain
+
+	return nil
+}
+
+var _ mongo.MogDal = (*MongoDal)(nil)
+
+//MongoDal -> mongo.MogDal encrypting ChatMsg.Msg on insert and update and decrypting it on GetDoc / FindMany
+type MongoDal struct {
+	Mongo  *mongo.MogClientImpl
+	Cipher Cipher
+}
+
+//create encrypting mongo dal
+func NewMongoDal(mongoCli *mongo.MogClientImpl, cipher Cipher) *MongoDal {
+
+	return &MongoDal{Mongo: mongoCli, Cipher: cipher}
+}
+
+//insert one document, ChatMsg and *ChatMsg are inserted with Msg encrypted, the caller's message is not modified
+func (m *MongoDal) InsertDoc(dbName string, data interface{}) (*qmgo.InsertOneResult, error) {
+
+	doc, err := m.encryptDoc(data)
+	if nil != err {
+		return nil, err
+	}
+
+	return m.Mongo.InsertDoc(dbName, doc)
+}
+
+//encrypted copy of a ChatMsg or *ChatMsg, any other document is returned as is
+func (m *MongoDal) encryptDoc(data interface{}) (interface{}, error) {
+
+	var msg staict_const.ChatMsg
+	switch v := data.(type) {
+	case staict_const.ChatMsg:
+		msg = v
+	case *staict_const.ChatMsg:
+		msg = *v
+	default:
+		return data, nil
+	}
+
+	err := EncryptChatMsg(m.Cipher, &msg)
+	if nil != err {
+		return nil, err
+	}
+
+	return &msg, nil
+}
+
+//get one document, a *ChatMsg res is returned with Msg decrypted
+func (m *MongoDal) GetDoc(dbName string, condition bson.M, res interface{}) error {
+
+	err := m.Mongo.GetDoc(dbName, condition, res)
+	if nil != err {
+		return err
+	}
+
+	return m.decryptRes(res)
+}
+
+//get documents, a *[]ChatMsg or *[]*ChatMsg res is returned with every Msg decrypted
+func (m *MongoDal) FindMany(dbName string, condition bson.M, opts mongo.FindOptions, res interface{}) error {
+
+	err := m.Mongo.FindMany(dbName, condition, opts, res)
+	if nil != err {
+		return err
+	}
+
+	switch msgs := res.(type) {
+	case *[]staict_const.ChatMsg:
+		for i := range *msgs {
+			err = DecryptChatMsg(m.Cipher, &(*msgs)[i])
+			if nil != err {
+				return err
+			}
+		}
+	case *[]*staict_const.ChatMsg:
+		for _, msg := range *msgs {
+			if nil == msg {
+				continue
+			}
+			err = DecryptChatMsg(m.Cipher, msg)
+			if nil != err {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (m *MongoDal) Count(dbName string, condition bson.M) (int64, error) {
+
+	return m.Mongo.Count(dbName, condition)
+}
+
+func (m *MongoDal) Distinct(dbName string, field string, condition bson.M, res interface{}) error {
+
+	return m.Mongo.Distinct(dbName, field, condition, res)
+}
+
+//update one document, msg inside $set and $setOnInsert is encrypted, see encryptOperator
+func (m *MongoDal) UpdateDoc(dbName string, condition bson.M, operator bson.M) error {
+
+	operator, err := m.encryptOperator(operator)
+	if nil != err {
+		return err
+	}
+
+	return m.Mongo.UpdateDoc(dbName, condition, operator)
+}
+
+func (m *MongoDal) RemoveDoc(dbName string, condition bson.M) error {
+
+	return m.Mongo.RemoveDoc(dbName, condition)
+}
+
+//upsert one document, msg inside $set and $setOnInsert is encrypted, see encryptOperator
+func (m *MongoDal) Upsert(dbName string, condition bson.M, operator bson.M) (*qmgo.UpdateResult, error) {
+
+	operator, err := m.encryptOperator(operator)
+	if nil != err {
+		return nil, err
+	}
+
+	return m.Mongo.Upsert(dbName, condition, operator)
+}
+
+//find and update with msg inside $set and $setOnInsert encrypted, a *ChatMsg res is returned with Msg decrypted
+func (m *MongoDal) FindOneAndUpdate(dbName string, condition bson.M, operator bson.M, opts mongo.FindAndModifyOptions, res interface{}) error {
+
+	operator, err := m.encryptOperator(operator)
+	if nil != err {
+		return err
+	}
+
+	err = m.Mongo.FindOneAndUpdate(dbName, condition, operator, opts, res)
+	if nil != err {
+		return err
+	}
+
+	return m.decryptRes(res)
+}
+
+//find and replace with a ChatMsg replacement encrypted, a *ChatMsg res is returned with Msg decrypted
+func (m *MongoDal) FindOneAndReplace(dbName string, condition bson.M, replacement interface{}, opts mongo.FindAndModifyOptions, res interface{}) error {
+
+	doc, err := m.encryptDoc(replacement)
+	if nil != err {
+		return err
+	}
+
+	err = m.Mongo.FindOneAndReplace(dbName, condition, doc, opts, res)
+	if nil != err {
+		return err
+	}
+
+	return m.decryptRes(res)
+}
+
+//find and delete, a *ChatMsg res is returned with Msg decrypted
+func (m *MongoDal) FindOneAndDelete(dbName string, condition bson.M, opts mongo.FindAndModifyOptions, res interface{}) error {
+
+	err := m.Mongo.FindOneAndDelete(dbName, condition, opts, res)
+	if nil != err {
+		return err
+	}
+
+	return m.decryptRes(res)
+}
+
+//decrypt res in place when it is a *ChatMsg
+func (m *MongoDal) decryptRes(res interface{}) error {
+
+	if msg, ok := res.(*staict_const.ChatMsg); ok {
+		return DecryptChatMsg(m.Cipher, msg)
+	}
+
+	return nil
+}
+
+//insert docs with every ChatMsg encrypted, the caller's messages are not modified
+func (m *MongoDal) InsertMany(dbName string, docs interface{}) (*qmgo.InsertManyResult, error) {
+
+	rv := reflect.ValueOf(docs)
+	if rv.Kind() != reflect.Slice {
+		return m.Mongo.InsertMany(dbName, docs)
+	}
+
+	encrypted := make([]interface{}, 0, rv.Len())
+	for i := 0; i < rv.Len(); i++ {
+		doc, err := m.encryptDoc(rv.Index(i).Interface())
+		if nil != err {
+			return nil, err
+		}
+		encrypted = append(encrypted, doc)
+	}
+
+	return m.Mongo.InsertMany(dbName, encrypted)
+}
+
+//update documents, msg inside $set and $setOnInsert is encrypted, see encryptOperator
+func (m *MongoDal) UpdateMany(dbName string, condition bson.M, operator bson.M) (*qmgo.UpdateResult, error) {
+
+	operator, err := m.encryptOperator(operator)
+	if nil != err {
+		return nil, err
+	}
+
+	return m.Mongo.UpdateMany(dbName, condition, operator)
+}
+
+//copy of operator with msg encrypted inside $set and $setOnInsert, the caller's operator is not modified
+//$unset of msg passes, any other operator writing msg or renaming onto it returns ErrMsgOperator
+func (m *MongoDal) encryptOperator(operator bson.M) (bson.M, error) {
+
+	if nil == operator {
+		return nil, nil
+	}
+
+	encrypted := make(bson.M, len(operator))
+	for op, fields := range operator {
+		switch op {
+		case "$set", "$setOnInsert":
+			doc, err := m.encryptFields(fields)
+			if nil != err {
+				return nil, err
+			}
+			encrypted[op] = doc
+		case "$unset":
+			encrypted[op] = fields
+		default:
+			touches, err := touchesMsg(op, fields)
+			if nil != err {
+				return nil, err
+			}
+			if touches {
+				return nil, ErrMsgOperator
+			}
+			encrypted[op] = fields
+		}
+	}
+
+	return encrypted, nil
+}
+
+//copy of the fields of $set or $setOnInsert with msg encrypted
+func (m *MongoDal) encryptFields(fields interface{}) (interface{}, error) {
+
+	switch doc := fields.(type) {
+	case bson.M:
+		return m.encryptFieldMap(doc)
+	case map[string]interface{}:
+		return m.encryptFieldMap(doc)
+	case bson.D:
+		encrypted := make(bson.D, len(doc))
+		for i, e := range doc {
+			if "msg" == e.Key {
+				value, err := m.encryptMsgValue(e.Value)
+				if nil != err {
+					return nil, err
+				}
+				e.Value = value
+			} else if isMsgPath(e.Key) {
+				return nil, ErrMsgOperator
+			}
+			encrypted[i] = e
+		}
+		return encrypted, nil
+	case staict_const.ChatMsg, *staict_const.ChatMsg:
+		return m.encryptDoc(doc)
+	}
+
+	//a struct of another type, refuse it if it carries a msg field
+	touches, err := touchesMsg("$set", fields)
+	if nil != err {
+		return nil, err
+	}
+	if touches {
+		return nil, ErrMsgOperator
+	}
+
+	return fields, nil
+}
+
+func (m *MongoDal) encryptFieldMap(doc map[string]interface{}) (bson.M, error) {
+
+	encrypted := make(bson.M, len(doc))
+	for key, value := range doc {
+		if "msg" == key {
+			sealed, err := m.encryptMsgValue(value)
+			if nil != err {
+				return nil, err
+			}
+			value = sealed
+		} else if isMsgPath(key) {
+			return nil, ErrMsgOperator
+		}
+		encrypted[key] = value
+	}
+
+	return encrypted, nil
+}
+
+//encrypted msg value, nil and already encrypted values are left alone
+func (m *MongoDal) encryptMsgValue(value interface{}) (interface{}, error) {
+
+	var plain []byte
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case []byte:
+		plain = v
+	case string:
+		plain = []byte(v)
+	default:
+		return nil, ErrMsgOperator
+	}
+
+	if len(plain) == 0 || m.Cipher.IsEncrypted(plain) {
+		return plain, nil
+	}
+
+	return m.Cipher.Encrypt(plain)
+}
+
+//path inside msg, msg is binary so such a path cannot be encrypted
+func isMsgPath(key string) bool {
+
+	return strings.HasPrefix(key, "msg.")
+}
+
+//true if the fields of operator op write msg, for $rename also when a field is renamed onto msg
+func touchesMsg(op string, fields interface{}) (bool, error) {
+
+	raw, err := bson.Marshal(fields)
+	if nil != err {
+		return false, err
+	}
+	elements, err := bson.Raw(raw).Elements()
+	if nil != err {
+		return false, err
+	}
+
+	for _, e := range elements {
+		if "msg" == e.Key() || isMsgPath(e.Key()) {
+			return true, nil
+		}
+		if "$rename" == op {
+			if to, ok := e.Value().StringValueOK(); ok && ("msg" == to || isMsgPath(to)) {
+				return true, nil
+			}
+		}
+	}
+
+	return false, nil
+}
+
+func (m *MongoDal) RemoveAll(dbName string, condition bson.M) (*qmgo.DeleteResult, error) {
+
+	return m.Mongo.RemoveAll(dbName, condition)
+}
+
+//bulk write with the ChatMsg of insert and replace models and msg of update models encrypted
+func (m *MongoDal) BulkWrite(dbName string, models []mongo.WriteModel, ordered bool) (*mongo.BulkResult, error) {
+
+	encrypted := make([]mongo.WriteModel, len(models))
+	for i, model := range models {
+		switch model.Op {
+		case mongo.OpInsert, mongo.OpReplaceOne:
+			doc, err := m.encryptDoc(model.Doc)
+			if nil != err {
+				return nil, err
+			}
+			model.Doc = doc
+		case mongo.OpUpdateOne, mongo.OpUpdateMany:
+			update, err := m.encryptOperator(model.Update)
+			if nil != err {
+				return nil, err
+			}
+			model.Update = update
+		}
+		encrypted[i] = model
+	}
+
+	return m.Mongo.BulkWrite(dbName, encrypted, ordered)
+}
+
+//get one chat message with Msg decrypted
+func (m *MongoDal) GetChatMsg(dbName string, condition bson.M) (*staict_const.ChatMsg, error) {
+
+	msg := new(staict_const.ChatMsg)
+	err := m.GetDoc(dbName, condition, msg)
+	if nil != err {
+		return nil, err
+	}
+
+	return msg, nil
+}
+
+//RotateResult -> counters of Rotate
+//Rewrapped: envelopes moved to the current key
+//Encrypted: legacy plaintext messages encrypted
+//Failed: messages that could not be opened, the key of their envelope may be gone
+type RotateResult struct {
+	Scanned   int64
+	Rewrapped int64
+	Encrypted int64
+	Failed    int64
+}
+
+//rotateDoc -> fields Rotate reads
+type rotateDoc struct {
+	Id  interface{} `bson:"_id"`
+	Msg []byte      `bson:"msg"`
+}
+
+//move messages matching filter to the current key, encryptPlain also encrypts legacy plaintext
+//each update is conditional on the old msg, so messages changed meanwhile are skipped, run again to catch them
+func (m *MongoDal) Rotate(dbName string, filter bson.M, encryptPlain bool) (RotateResult, error) {
+
+	result := RotateResult{}
+
+	if "" == dbName {
+		dbName = staict_const.Chat
+	}
+	//hold the client so a replace waits for the scan instead of closing it underneath
+	cli, release, err := m.Mongo.Acquire(dbName)
+	if nil != err {
+		return result, err
+	}
+	defer release()
+	coll := cli.Coll
+	if nil == filter {
+		filter = bson.M{}
+	}
+
+	ctx := m.Mongo.GetCtx()
+	cursor := coll.Find(ctx, filter).Select(bson.M{"_id": 1, "msg": 1}).Cursor()
+	defer cursor.Close()
+
+	for {
+		var doc rotateDoc
+		if !cursor.Next(&doc) {
+			break
+		}
+		result.Scanned++
+
+		var next []byte
+		rewrap := m.Cipher.IsEncrypted(doc.Msg)
+		if rewrap {
+			rewrapped, changed, err := m.Cipher.Rewrap(doc.Msg)
+			if nil != err {
+				logrus.Error("crypto rotate Error! id:", doc.Id, "Details:", err.Error())
+				result.Failed++
+				continue
+			}
+			if !changed {
+				continue
+			}
+			next = rewrapped
+		} else {
+			if !encryptPlain || len(doc.Msg) == 0 {
+				continue
+			}
+			next, err = m.Cipher.Encrypt(doc.Msg)
+			if nil != err {
+				return result, err
+			}
+		}
+
+		err = coll.UpdateOne(ctx, bson.M{"_id": doc.Id, "msg": doc.Msg}, bson.M{"$set": bson.M{"msg": next}})
+		if errors.Is(err, qmgo.ErrNoSuchDocuments) {
+			continue
+		}
+		if nil != err {
+			return result, err
+		}
+
+		if rewrap {
+			result.Rewrapped++
+		} else {
+			result.Encrypted++
+		}
+	}
+
+	return result, cursor.Err()
+}
@@ -0,0 +1,118 @@
+/**
+ * @Author KYIMH
+ * @Description
+ * @Date 2026/10/19 17:05
+ **/
+
+package dedup
+
+import (
+	"github.com/go-redis/redis"
+	"hash/fnv"
+	"strconv"
+	"time"
+)
+
+//set the bits of an id in the current filter and report whether it is new
+//new means some bit was 0 in the current filter and some bit is 0 in the previous one
+//KEYS[1]: current filter, KEYS[2]: previous filter, ARGV[1]: expire ms, ARGV[2...]: bit offsets
+var bloomAddScript = redis.NewScript(`
+local fresh = 0
+for i = 2, #ARGV do
+	if redis.call('SETBIT', KEYS[1], ARGV[i], 1) == 0 then
+		fresh = 1
+	end
+end
+redis.call('PEXPIRE', KEYS[1], ARGV[1])
+if fresh == 0 then
+	return 0
+end
+for i = 2, #ARGV do
+	if redis.call('GETBIT', KEYS[2], ARGV[i]) == 0 then
+		return 1
+	end
+end
+return 0
+`)
+
+//report whether every bit of an id is set in the current or the previous filter
+//KEYS[1]: current filter, KEYS[2]: previous filter, ARGV: bit offsets
+var bloomSeenScript = redis.NewScript(`
+for k = 1, 2 do
+	local all = 1
+	for i = 1, #ARGV do
+		if redis.call('GETBIT', KEYS[k], ARGV[i]) == 0 then
+			all = 0
+			break
+		end
+	end
+	if all == 1 then
+		return 1
+	end
+end
+return 0
+`)
+
+//the filter of the current window and of the previous one, an id stays visible for one to two windows
+func (d *DeduperImpl) bloomKeys(now time.Time) []string {
+
+	bucket := now.UnixNano() / int64(d.Config.Window)
+
+	return []string{
+		d.Config.Prefix + "bloom:" + strconv.FormatInt(bucket, 10),
+		d.Config.Prefix + "bloom:" + strconv.FormatInt(bucket-1, 10),
+	}
+}
+
+//bit offsets of id by double hashing
+func (d *DeduperImpl) bloomOffsets(id string) []interface{} {
+
+	h1 := fnv.New64a()
+	h1.Write([]byte(id))
+	a := h1.Sum64()
+
+	h2 := fnv.New64()
+	h2.Write([]byte(id))
+	b := h2.Sum64() | 1
+
+	offsets := make([]interface{}, 0, d.Config.BloomHashes)
+	for i := 0; i < d.Config.BloomHashes; i++ {
+		offsets = append(offsets, (a+uint64(i)*b)%d.Config.BloomBits)
+	}
+
+	return offsets
+}
+
+func (d *DeduperImpl) bloomFirstSeen(id string) (bool, error) {
+
+	cli, err := d.Redis.GetClient(d.Config.RedisTag)
+	if nil != err {
+		return false, err
+	}
+
+	//keep a filter alive while it may still serve as the previous one
+	expire := int64(2 * d.Config.Window / time.Millisecond)
+	args := append([]interface{}{expire}, d.bloomOffsets(id)...)
+
+	fresh, err := bloomAddScript.Run(cli, d.bloomKeys(time.Now()), args...).Int64()
+	if nil != err {
+		return false, err
+	}
+
+	return fresh == 1, nil
+}
+
+func (d *DeduperImpl) bloomSeen(id string) (bool, error) {
+
+	cli, err := d.Redis.GetClient(d.Config.RedisTag)
+	if nil != err {
+		return false, err
+	}
+
+	seen, err := bloomSeenScript.Run(cli, d.bloomKeys(time.Now()), d.bloomOffsets(id)...).Int64()
+	if nil != err {
+		return false, err
+	}
+
+	return seen == 1, nil
+}
@@ -0,0 +1,51 @@
+/**
+ * @Author KYIMH
+ * @Description
+ * @Date 2026/10/19 17:05
+ **/
+
+package dedup
+
+import (
+	"errors"
+	"github.com/KYIMH/CCS_Utils/share/enum/staict_const"
+	"github.com/qiniu/qmgo"
+	"time"
+)
+
+//ErrDuplicate -> the message has already been seen inside the window
+var ErrDuplicate = errors.New("duplicate message")
+
+//Mode -> how ids are recorded in redis
+type Mode int
+
+const (
+	//one key per id, exact and can be forgotten
+	ModeExact Mode = iota
+	//bloom filter on redis bitmaps, constant memory, false positives possible and ids cannot be forgotten
+	ModeBloom
+)
+
+//Config -> dedup config
+//RedisTag: redis tag of the pool holding the records
+//Prefix: key prefix, example: ccs:dedup:
+//Window: how long an id is remembered
+//Mode: ModeExact or ModeBloom
+//BloomBits: bits of one bloom filter, only for ModeBloom
+//BloomHashes: hash functions of the bloom filter, only for ModeBloom
+type Config struct {
+	RedisTag    string
+	Prefix      string
+	Window      time.Duration
+	Mode        Mode
+	BloomBits   uint64
+	BloomHashes int
+}
+
+//dedup operators
+type Deduper interface {
+	FirstSeen(id string) (bool, error)
+	Seen(id string) (bool, error)
+	Forget(id string) error
+	InsertChatMsg(dbName string, msg *staict_const.ChatMsg) (*qmgo.InsertOneResult, error)
+}
@@ -0,0 +1,171 @@
+/**
+ * @Author KYIMH
+ * @Description
+ * @Date 2026/10/19 17:05
+ **/
+
+package dedup
+
+import (
+	"errors"
+	"github.com/KYIMH/CCS_Utils/mongo"
+	"github.com/KYIMH/CCS_Utils/redis"
+	"github.com/KYIMH/CCS_Utils/share/enum/staict_const"
+	"github.com/qiniu/qmgo"
+	"github.com/sirupsen/logrus"
+	driver "go.mongodb.org/mongo-driver/mongo"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultPrefix      = "ccs:dedup:"
+	defaultWindow      = 10 * time.Minute
+	defaultBloomBits   = 1 << 24 // 2MB per filter
+	defaultBloomHashes = 7
+)
+
+//DeduperImpl -> dedup implement on the redis pool
+//Redis: redis dal holding the records
+//Mongo: mongo dal used by InsertChatMsg, may be nil if InsertChatMsg is not used
+type DeduperImpl struct {
+	Redis  redis.Dal
+	Mongo  mongo.MogDal
+	Config Config
+}
+
+//create new deduper, zero fields of config take the defaults
+func NewDeduper(redisDal redis.Dal, mongoDal mongo.MogDal, config Config) *DeduperImpl {
+
+	if "" == config.Prefix {
+		config.Prefix = defaultPrefix
+	}
+	if config.Window <= 0 {
+		config.Window = defaultWindow
+	}
+	if 0 == config.BloomBits {
+		config.BloomBits = defaultBloomBits
+	}
+	if config.BloomHashes <= 0 {
+		config.BloomHashes = defaultBloomHashes
+	}
+
+	return &DeduperImpl{
+		Redis:  redisDal,
+		Mongo:  mongoDal,
+		Config: config,
+	}
+}
+
+//dedup id of a chat message
+func ChatMsgId(msg *staict_const.ChatMsg) string {
+
+	return strconv.FormatUint(uint64(msg.ChatId), 10)
+}
+
+func (d *DeduperImpl) exactKey(id string) string {
+
+	return d.Config.Prefix + id
+}
+
+//record id and report whether it is the first time inside the window, atomic between callers
+func (d *DeduperImpl) FirstSeen(id string) (bool, error) {
+
+	if d.Config.Mode == ModeBloom {
+		return d.bloomFirstSeen(id)
+	}
+
+	nx, err := redis.AsNXSetter(d.Redis)
+	if nil != err {
+		return false, err
+	}
+
+	return nx.RedisSetNX(d.Config.RedisTag, d.exactKey(id), 1, int(d.Config.Window/time.Millisecond))
+}
+
+//report whether id has been recorded inside the window without recording it
+func (d *DeduperImpl) Seen(id string) (bool, error) {
+
+	if d.Config.Mode == ModeBloom {
+		return d.bloomSeen(id)
+	}
+
+	return d.Redis.RedisKeyExists(d.Config.RedisTag, d.exactKey(id))
+}
+
+//drop the record of id so it can be accepted again, only for ModeExact
+func (d *DeduperImpl) Forget(id string) error {
+
+	if d.Config.Mode == ModeBloom {
+		return errors.New("dedup: bloom mode cannot forget an id")
+	}
+
+	return d.Redis.RedisDel(d.Config.RedisTag, d.exactKey(id))
+}
+
+//insert msg through Mongo unless its ChatId has been seen inside the window
+//returns ErrDuplicate for a retried message, in ModeExact the record is dropped again when the insert fails
+//a bloom filter cannot forget, so in ModeBloom the id is only checked before the insert and recorded after it succeeded,
+//concurrent copies of one message both pass the check there and need the unique index on chat_id
+func (d *DeduperImpl) InsertChatMsg(dbName string, msg *staict_const.ChatMsg) (*qmgo.InsertOneResult, error) {
+
+	if nil == d.Mongo {
+		return nil, errors.New("dedup: no mongo dal")
+	}
+
+	id := ChatMsgId(msg)
+
+	if d.Config.Mode == ModeBloom {
+		return d.bloomInsertChatMsg(dbName, id, msg)
+	}
+
+	first, err := d.FirstSeen(id)
+	if nil != err {
+		return nil, err
+	}
+	if !first {
+		return nil, ErrDuplicate
+	}
+
+	result, err := d.Mongo.InsertDoc(dbName, msg)
+	if nil == err {
+		return result, nil
+	}
+
+	//a unique index on chat_id caught a duplicate older than the window
+	if driver.IsDuplicateKeyError(err) {
+		return nil, ErrDuplicate
+	}
+
+	if forgetErr := d.Forget(id); nil != forgetErr {
+		logrus.Error("dedup Forget Error! id:", id, "Details:", forgetErr.Error())
+	}
+
+	return nil, err
+}
+
+func (d *DeduperImpl) bloomInsertChatMsg(dbName string, id string, msg *staict_const.ChatMsg) (*qmgo.InsertOneResult, error) {
+
+	seen, err := d.Seen(id)
+	if nil != err {
+		return nil, err
+	}
+	if seen {
+		return nil, ErrDuplicate
+	}
+
+	result, err := d.Mongo.InsertDoc(dbName, msg)
+	if driver.IsDuplicateKeyError(err) {
+		return nil, ErrDuplicate
+	}
+	if nil != err {
+		return nil, err
+	}
+
+	//the message is stored, a failed record only lets a retry reach the unique index
+	if _, err := d.FirstSeen(id); nil != err {
+		logrus.Error("dedup FirstSeen Error! id:", id, "Details:", err.Error())
+	}
+
+	return result, nil
+}
@@ -0,0 +1,103 @@
+/**
+ * @Author KYIMH
+ * @Description
+ * @Date 2026/10/20 03:20
+ **/
+
+package dedup_test
+
+import (
+	"errors"
+	"github.com/KYIMH/CCS_Utils/dedup"
+	"github.com/KYIMH/CCS_Utils/fakes"
+	"github.com/KYIMH/CCS_Utils/redis"
+	"github.com/KYIMH/CCS_Utils/redis/redistest"
+	"github.com/KYIMH/CCS_Utils/share/enum/staict_const"
+	"github.com/qiniu/qmgo"
+	"testing"
+	"time"
+)
+
+const tag = "ccs"
+
+func newClient(t *testing.T) *redis.ClientImpl {
+
+	srv, cli, err := redistest.NewClientImpl(tag)
+	if nil != err {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		cli.Close()
+		srv.Close()
+	})
+
+	return cli
+}
+
+func TestDeduperBloom(t *testing.T) {
+
+	cli := newClient(t)
+
+	d := dedup.NewDeduper(cli, nil, dedup.Config{
+		RedisTag:    tag,
+		Prefix:      "dedup:",
+		Window:      time.Minute,
+		Mode:        dedup.ModeBloom,
+		BloomBits:   1 << 12,
+		BloomHashes: 4,
+	})
+
+	first, err := d.FirstSeen("msg-1")
+	if nil != err || !first {
+		t.Fatalf("FirstSeen = %v, %v", first, err)
+	}
+	first, err = d.FirstSeen("msg-1")
+	if nil != err || first {
+		t.Fatalf("FirstSeen again = %v, %v", first, err)
+	}
+	if seen, err := d.Seen("msg-2"); nil != err || seen {
+		t.Fatalf("Seen of a new id = %v, %v", seen, err)
+	}
+}
+
+//flakyMongo -> mongo fake failing the next fail inserts
+type flakyMongo struct {
+	*fakes.MongoFake
+	fail int
+}
+
+func (m *flakyMongo) InsertDoc(dbName string, data interface{}) (*qmgo.InsertOneResult, error) {
+
+	if m.fail > 0 {
+		m.fail--
+		return nil, errors.New("insert failed")
+	}
+
+	return m.MongoFake.InsertDoc(dbName, data)
+}
+
+func TestDeduperBloomInsertFails(t *testing.T) {
+
+	mongoDal := &flakyMongo{MongoFake: fakes.NewMongoFake(), fail: 1}
+	d := dedup.NewDeduper(newClient(t), mongoDal, dedup.Config{
+		RedisTag:  tag,
+		Mode:      dedup.ModeBloom,
+		BloomBits: 1 << 12,
+	})
+	msg := &staict_const.ChatMsg{ChatId: 1, FromId: 7, ToId: 8}
+
+	if _, err := d.InsertChatMsg("", msg); nil == err || err == dedup.ErrDuplicate {
+		t.Fatalf("InsertChatMsg of a failing insert = %v", err)
+	}
+	//a bloom filter cannot forget, the failed insert must not have recorded the id
+	if seen, err := d.Seen(dedup.ChatMsgId(msg)); nil != err || seen {
+		t.Fatalf("Seen after a failed insert = %v, %v", seen, err)
+	}
+
+	if _, err := d.InsertChatMsg("", msg); nil != err {
+		t.Fatalf("InsertChatMsg retry = %v", err)
+	}
+	if _, err := d.InsertChatMsg("", msg); err != dedup.ErrDuplicate {
+		t.Fatalf("InsertChatMsg of a stored message = %v", err)
+	}
+}
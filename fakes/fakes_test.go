@@ -0,0 +1,131 @@
+/**
+ * @Author KYIMH
+ * @Description
+ * @Date 2026/10/20 03:20
+ **/
+
+package fakes_test
+
+import (
+	"errors"
+	"github.com/KYIMH/CCS_Utils/changestream"
+	"github.com/KYIMH/CCS_Utils/dedup"
+	"github.com/KYIMH/CCS_Utils/fakes"
+	"github.com/KYIMH/CCS_Utils/mongo"
+	"github.com/KYIMH/CCS_Utils/redis"
+	"github.com/KYIMH/CCS_Utils/session"
+	"github.com/KYIMH/CCS_Utils/share/enum/staict_const"
+	"go.mongodb.org/mongo-driver/bson"
+	"testing"
+	"time"
+)
+
+const tag = "ccs"
+
+func TestRedisFakeDal(t *testing.T) {
+
+	var dal redis.Dal = fakes.NewRedisFake()
+
+	counters, err := redis.AsCounters(dal)
+	if nil != err {
+		t.Fatal(err)
+	}
+	v, err := counters.RedisIncrWithExpire(tag, "c", 2, 30000)
+	if nil != err || 2 != v {
+		t.Fatalf("RedisIncrWithExpire = %d, %v", v, err)
+	}
+
+	store := session.NewStore(dal, session.Config{RedisTag: tag, TTL: time.Minute})
+	sess, err := store.Create("u1", map[string]string{"lang": "en"})
+	if nil != err {
+		t.Fatal(err)
+	}
+	list, err := store.ListByUser("u1")
+	if nil != err || len(list) != 1 || list[0].Id != sess.Id {
+		t.Fatalf("ListByUser = %v, %v", list, err)
+	}
+
+	d := dedup.NewDeduper(dal, nil, dedup.Config{RedisTag: tag, Prefix: "dedup:", Window: time.Minute})
+	first, err := d.FirstSeen("msg-1")
+	if nil != err || !first {
+		t.Fatalf("FirstSeen = %v, %v", first, err)
+	}
+	if err := d.Forget("msg-1"); nil != err {
+		t.Fatal(err)
+	}
+	if seen, err := d.Seen("msg-1"); nil != err || seen {
+		t.Fatalf("Seen after Forget = %v, %v", seen, err)
+	}
+}
+
+//basicDal -> a Dal implementing none of the optional interfaces
+type basicDal struct {
+	redis.Dal
+}
+
+func TestRedisOptionalInterfaces(t *testing.T) {
+
+	var dal redis.Dal = basicDal{Dal: fakes.NewRedisFake()}
+
+	if _, err := redis.AsCounters(dal); !errors.Is(err, redis.ErrUnsupported) {
+		t.Fatalf("AsCounters of a basic Dal = %v", err)
+	}
+	if got := redis.PrimaryOf(dal); got != dal {
+		t.Fatalf("PrimaryOf of a basic Dal = %v", got)
+	}
+
+	d := dedup.NewDeduper(dal, nil, dedup.Config{RedisTag: tag, Window: time.Minute})
+	if _, err := d.FirstSeen("msg-1"); !errors.Is(err, redis.ErrUnsupported) {
+		t.Fatalf("FirstSeen without NXSetter = %v", err)
+	}
+}
+
+func TestMongoFakeDal(t *testing.T) {
+
+	var dal mongo.MogDal = fakes.NewMongoFake()
+
+	for i := uint32(1); i <= 3; i++ {
+		_, err := dal.InsertDoc("", staict_const.ChatMsg{ChatId: i, FromId: 7, ToId: i % 2})
+		if nil != err {
+			t.Fatal(err)
+		}
+	}
+
+	query, err := mongo.NewQuery().Eq("from_id", 7).Gte("chat_id", 2).Build()
+	if nil != err {
+		t.Fatal(err)
+	}
+	var msgs []staict_const.ChatMsg
+	err = dal.FindMany("", query, mongo.FindOptions{Sort: []string{"-chat_id"}}, &msgs)
+	if nil != err || len(msgs) != 2 || 3 != msgs[0].ChatId {
+		t.Fatalf("FindMany = %+v, %v", msgs, err)
+	}
+
+	result, err := dal.BulkWrite("", []mongo.WriteModel{
+		mongo.UpdateModel(bson.M{"chat_id": 1}, bson.M{"$set": bson.M{"to_id": 9}}),
+		mongo.DeleteModel(bson.M{"chat_id": 2}),
+	}, true)
+	if nil != err || 1 != result.ModifiedCount || 1 != result.DeletedCount {
+		t.Fatalf("BulkWrite = %+v, %v", result, err)
+	}
+
+	seq := mongo.NewSequence(dal, "counters", 10)
+	for want := uint64(1); want <= 12; want++ {
+		v, err := seq.Next("chat_id")
+		if nil != err || want != v {
+			t.Fatalf("Next = %d, %v, want %d", v, err, want)
+		}
+	}
+
+	store := changestream.NewMongoStore(dal, "tokens")
+	if token, err := store.Load("push"); nil != err || nil != token {
+		t.Fatalf("Load of a new name = %v, %v", token, err)
+	}
+	token, _ := bson.Marshal(bson.M{"_data": "8263"})
+	if err := store.Save("push", token); nil != err {
+		t.Fatal(err)
+	}
+	if got, err := store.Load("push"); nil != err || string(got) != string(token) {
+		t.Fatalf("Load = %v, %v", got, err)
+	}
+}
@@ -0,0 +1,67 @@
+/**
+ * @Author KYIMH
+ * @Description
+ * @Date 2026/10/19 13:40
+ **/
+
+package fakes
+
+import (
+	"errors"
+	"github.com/KYIMH/CCS_Utils/share/glob"
+	"math"
+	"strconv"
+	"strings"
+)
+
+//MatchPattern -> redis style glob match used by KEYS and SCAN, see glob.Match
+func MatchPattern(pattern string, s string) bool {
+
+	return glob.Match(pattern, s)
+}
+
+//ParseScoreRange -> parse redis score bounds like 1, (1, -inf, +inf into a predicate
+func ParseScoreRange(min string, max string) (func(score float64) bool, error) {
+
+	lo, loOpen, err := parseScoreBound(min)
+	if nil != err {
+		return nil, err
+	}
+	hi, hiOpen, err := parseScoreBound(max)
+	if nil != err {
+		return nil, err
+	}
+
+	return func(score float64) bool {
+		if score < lo || (loOpen && score == lo) {
+			return false
+		}
+		if score > hi || (hiOpen && score == hi) {
+			return false
+		}
+		return true
+	}, nil
+}
+
+func parseScoreBound(bound string) (float64, bool, error) {
+
+	open := false
+	if strings.HasPrefix(bound, "(") {
+		open = true
+		bound = bound[1:]
+	}
+
+	switch strings.ToLower(bound) {
+	case "-inf":
+		return math.Inf(-1), open, nil
+	case "+inf", "inf":
+		return math.Inf(1), open, nil
+	}
+
+	v, err := strconv.ParseFloat(bound, 64)
+	if nil != err {
+		return 0, false, errors.New("ERR min or max is not a float")
+	}
+
+	return v, open, nil
+}
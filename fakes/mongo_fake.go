@@ -0,0 +1,740 @@
+/**
+ * @Author KYIMH
+ * @Description
+ * @Date 2026/10/19 13:40
+ **/
+
+package fakes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/KYIMH/CCS_Utils/mongo"
+	"github.com/KYIMH/CCS_Utils/share/enum/staict_const"
+	"github.com/qiniu/qmgo"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	driver "go.mongodb.org/mongo-driver/mongo"
+	"reflect"
+	"sync"
+)
+
+var _ mongo.MogDal = (*MongoFake)(nil)
+
+//same error a mongo server returns for a duplicate _id, so driver.IsDuplicateKeyError works with the fake
+var errDuplicateKey = driver.WriteException{
+	WriteErrors: driver.WriteErrors{{Code: 11000, Message: "E11000 duplicate key error"}},
+}
+
+//MongoFake -> thread-safe in-memory mongo.MogDal
+//documents are kept per dbName in insert order, an empty dbName means staict_const.Chat like MogClientImpl
+type MongoFake struct {
+	mu    sync.Mutex
+	colls map[string][]bson.M
+}
+
+//create new in-memory mongo
+func NewMongoFake() *MongoFake {
+
+	return &MongoFake{
+		colls: make(map[string][]bson.M),
+	}
+}
+
+func fakeDbName(dbName string) string {
+
+	if "" == dbName {
+		return staict_const.Chat
+	}
+
+	return dbName
+}
+
+//copy of every document stored under dbName
+func (f *MongoFake) Docs(dbName string) []bson.M {
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	docs := make([]bson.M, 0, len(f.colls[fakeDbName(dbName)]))
+	for _, doc := range f.colls[fakeDbName(dbName)] {
+		docs = append(docs, copyDoc(doc))
+	}
+
+	return docs
+}
+
+//the fake has no sessions, every context runs on the same store
+func (f *MongoFake) WithContext(ctx context.Context) mongo.MogDal {
+
+	return f
+}
+
+//run fn and roll every collection back if it fails, fn is run again while it returns mongo.ErrTransactionRetry
+//not isolated: writes of other goroutines while fn runs are rolled back too
+func (f *MongoFake) WithTransaction(ctx context.Context, dbName string, fn func(sessCtx context.Context) error) error {
+
+	for {
+		f.mu.Lock()
+		snapshot := make(map[string][]bson.M, len(f.colls))
+		for name, docs := range f.colls {
+			snapshot[name] = append([]bson.M(nil), docs...)
+		}
+		f.mu.Unlock()
+
+		err := fn(ctx)
+		if nil == err {
+			return nil
+		}
+
+		f.mu.Lock()
+		f.colls = snapshot
+		f.mu.Unlock()
+
+		if !errors.Is(err, mongo.ErrTransactionRetry) {
+			return err
+		}
+	}
+}
+
+//drop every document of every db
+func (f *MongoFake) Reset() {
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.colls = make(map[string][]bson.M)
+}
+
+//index of the first document matching condition, -1 if none, caller holds mu
+func (f *MongoFake) findIndex(dbName string, condition bson.M) (int, error) {
+
+	for i, doc := range f.colls[dbName] {
+		ok, err := matchDoc(doc, condition)
+		if nil != err {
+			return -1, err
+		}
+		if ok {
+			return i, nil
+		}
+	}
+
+	return -1, nil
+}
+
+//insert one document, _id is generated when missing
+func (f *MongoFake) InsertDoc(dbName string, data interface{}) (*qmgo.InsertOneResult, error) {
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	id, err := f.insert(fakeDbName(dbName), data)
+	if nil != err {
+		return nil, err
+	}
+
+	return &qmgo.InsertOneResult{InsertedID: id}, nil
+}
+
+//insert data, returns its _id, caller holds mu
+func (f *MongoFake) insert(dbName string, data interface{}) (interface{}, error) {
+
+	doc, err := toDoc(data)
+	if nil != err {
+		return nil, err
+	}
+
+	if _, ok := doc["_id"]; !ok {
+		doc["_id"] = primitive.NewObjectID()
+	}
+
+	return f.insertDoc(dbName, doc)
+}
+
+//insert doc which has an _id, caller holds mu
+func (f *MongoFake) insertDoc(dbName string, doc bson.M) (interface{}, error) {
+
+	idx, err := f.findIndex(dbName, bson.M{"_id": doc["_id"]})
+	if nil != err {
+		return nil, err
+	}
+	if idx >= 0 {
+		return nil, errDuplicateKey
+	}
+
+	f.colls[dbName] = append(f.colls[dbName], doc)
+
+	return doc["_id"], nil
+}
+
+//get one document decoded into res, res must be a pointer
+func (f *MongoFake) GetDoc(dbName string, condition bson.M, res interface{}) error {
+
+	if !isPointer(res) {
+		return mongo.ErrNotPointer
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	dbName = fakeDbName(dbName)
+	idx, err := f.findIndex(dbName, condition)
+	if nil != err {
+		return err
+	}
+	if idx < 0 {
+		return mongo.ErrNotFound
+	}
+
+	return decodeDoc(f.colls[dbName][idx], res)
+}
+
+//documents matching condition in insert order, caller holds mu
+func (f *MongoFake) findAll(dbName string, condition bson.M) ([]bson.M, error) {
+
+	docs := make([]bson.M, 0)
+	for _, doc := range f.colls[dbName] {
+		ok, err := matchDoc(doc, condition)
+		if nil != err {
+			return nil, err
+		}
+		if ok {
+			docs = append(docs, doc)
+		}
+	}
+
+	return docs, nil
+}
+
+//get every document matching condition decoded into res, res must be a pointer to a slice
+func (f *MongoFake) FindMany(dbName string, condition bson.M, opts mongo.FindOptions, res interface{}) error {
+
+	if !isPointer(res) {
+		return mongo.ErrNotPointer
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	docs, err := f.findAll(fakeDbName(dbName), condition)
+	if nil != err {
+		return err
+	}
+
+	sortDocs(docs, opts.Sort)
+
+	if opts.Skip > 0 {
+		if opts.Skip >= int64(len(docs)) {
+			docs = docs[:0]
+		} else {
+			docs = docs[opts.Skip:]
+		}
+	}
+	if opts.Limit > 0 && opts.Limit < int64(len(docs)) {
+		docs = docs[:opts.Limit]
+	}
+
+	values := make([]interface{}, 0, len(docs))
+	for _, doc := range docs {
+		values = append(values, projectDoc(doc, opts.Projection))
+	}
+
+	return decodeList(values, res)
+}
+
+//number of documents matching condition
+func (f *MongoFake) Count(dbName string, condition bson.M) (int64, error) {
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	docs, err := f.findAll(fakeDbName(dbName), condition)
+	if nil != err {
+		return 0, err
+	}
+
+	return int64(len(docs)), nil
+}
+
+//distinct values of field among documents matching condition, array fields are unwound like mongo does
+func (f *MongoFake) Distinct(dbName string, field string, condition bson.M, res interface{}) error {
+
+	if !isPointer(res) {
+		return mongo.ErrNotPointer
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	docs, err := f.findAll(fakeDbName(dbName), condition)
+	if nil != err {
+		return err
+	}
+
+	values := make([]interface{}, 0)
+	add := func(v interface{}) {
+		for _, seen := range values {
+			if equalValues(seen, v) {
+				return
+			}
+		}
+		values = append(values, v)
+	}
+	for _, doc := range docs {
+		v, ok := lookupPath(doc, field)
+		if !ok {
+			continue
+		}
+		if arr, isArr := v.(bson.A); isArr {
+			for _, item := range arr {
+				add(item)
+			}
+			continue
+		}
+		add(v)
+	}
+
+	return decodeList(values, res)
+}
+
+//update one document
+func (f *MongoFake) UpdateDoc(dbName string, condition bson.M, operator bson.M) error {
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	res, err := f.update(fakeDbName(dbName), condition, operator, false, false)
+	if nil != err {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return qmgo.ErrNoSuchDocuments
+	}
+
+	return nil
+}
+
+//update the first or every (multi) document matching condition, upsert inserts one if none matches, caller holds mu
+func (f *MongoFake) update(dbName string, condition bson.M, operator bson.M, multi bool, upsert bool) (*qmgo.UpdateResult, error) {
+
+	res := &qmgo.UpdateResult{}
+	for i, stored := range f.colls[dbName] {
+		ok, err := matchDoc(stored, condition)
+		if nil != err {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+
+		//update a copy so a failing operator leaves the stored document untouched
+		doc := copyDoc(stored)
+		err = applyUpdate(doc, operator)
+		if nil != err {
+			return nil, err
+		}
+		res.MatchedCount++
+		if !reflect.DeepEqual(doc, stored) {
+			res.ModifiedCount++
+		}
+		f.colls[dbName][i] = doc
+
+		if !multi {
+			break
+		}
+	}
+
+	if res.MatchedCount > 0 || !upsert {
+		return res, nil
+	}
+
+	doc := upsertBase(condition)
+	err := applyUpdate(doc, operator)
+	if nil != err {
+		return nil, err
+	}
+	if onInsert, ok := operator["$setOnInsert"]; ok {
+		fields, err := toFilter(onInsert)
+		if nil != err {
+			return nil, err
+		}
+		for path, value := range fields {
+			setPath(doc, path, value)
+		}
+	}
+	if _, ok := doc["_id"]; !ok {
+		doc["_id"] = primitive.NewObjectID()
+	}
+
+	id, err := f.insertDoc(dbName, doc)
+	if nil != err {
+		return nil, err
+	}
+	res.UpsertedCount = 1
+	res.UpsertedID = id
+
+	return res, nil
+}
+
+//replace the first document matching condition by replacement, keeping its _id, caller holds mu
+func (f *MongoFake) replace(dbName string, condition bson.M, replacement interface{}, upsert bool) (*qmgo.UpdateResult, error) {
+
+	doc, err := toDoc(replacement)
+	if nil != err {
+		return nil, err
+	}
+
+	res := &qmgo.UpdateResult{}
+	idx, err := f.findIndex(dbName, condition)
+	if nil != err {
+		return nil, err
+	}
+	if idx >= 0 {
+		modified, err := f.replaceAt(dbName, idx, doc)
+		if nil != err {
+			return nil, err
+		}
+		res.MatchedCount = 1
+		if modified {
+			res.ModifiedCount = 1
+		}
+		return res, nil
+	}
+
+	if !upsert {
+		return res, nil
+	}
+	if _, ok := doc["_id"]; !ok {
+		if id, ok := upsertBase(condition)["_id"]; ok {
+			doc["_id"] = id
+		} else {
+			doc["_id"] = primitive.NewObjectID()
+		}
+	}
+
+	id, err := f.insertDoc(dbName, doc)
+	if nil != err {
+		return nil, err
+	}
+	res.UpsertedCount = 1
+	res.UpsertedID = id
+
+	return res, nil
+}
+
+//replace the document at idx by doc keeping its _id, caller holds mu
+func (f *MongoFake) replaceAt(dbName string, idx int, doc bson.M) (bool, error) {
+
+	stored := f.colls[dbName][idx]
+	if id, ok := doc["_id"]; ok && !equalValues(id, stored["_id"]) {
+		return false, errors.New("the (immutable) field '_id' was found to have been altered")
+	}
+	doc["_id"] = stored["_id"]
+	f.colls[dbName][idx] = doc
+
+	return !reflect.DeepEqual(doc, stored), nil
+}
+
+//update the first document matching condition, insert one if none
+func (f *MongoFake) Upsert(dbName string, condition bson.M, operator bson.M) (*qmgo.UpdateResult, error) {
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.update(fakeDbName(dbName), condition, operator, false, true)
+}
+
+//index of the first document matching condition in sort order, -1 if none, caller holds mu
+func (f *MongoFake) findFirst(dbName string, condition bson.M, sort []string) (int, error) {
+
+	docs, err := f.findAll(dbName, condition)
+	if nil != err || len(docs) == 0 {
+		return -1, err
+	}
+	sortDocs(docs, sort)
+
+	first := reflect.ValueOf(docs[0]).Pointer()
+	for i, doc := range f.colls[dbName] {
+		if reflect.ValueOf(doc).Pointer() == first {
+			return i, nil
+		}
+	}
+
+	return -1, nil
+}
+
+//update the first document matching condition and decode it into res, before or after the update
+func (f *MongoFake) FindOneAndUpdate(dbName string, condition bson.M, operator bson.M, opts mongo.FindAndModifyOptions, res interface{}) error {
+
+	dbName = fakeDbName(dbName)
+
+	return f.findAndModify(dbName, condition, opts, res, func(idx int) (bson.M, error) {
+		doc := copyDoc(f.colls[dbName][idx])
+		err := applyUpdate(doc, operator)
+		if nil != err {
+			return nil, err
+		}
+		f.colls[dbName][idx] = doc
+		return doc, nil
+	}, func() (interface{}, error) {
+		r, err := f.update(dbName, condition, operator, false, true)
+		if nil != err {
+			return nil, err
+		}
+		return r.UpsertedID, nil
+	})
+}
+
+//replace the first document matching condition and decode it into res, before or after the replace
+func (f *MongoFake) FindOneAndReplace(dbName string, condition bson.M, replacement interface{}, opts mongo.FindAndModifyOptions, res interface{}) error {
+
+	dbName = fakeDbName(dbName)
+
+	return f.findAndModify(dbName, condition, opts, res, func(idx int) (bson.M, error) {
+		doc, err := toDoc(replacement)
+		if nil != err {
+			return nil, err
+		}
+		_, err = f.replaceAt(dbName, idx, doc)
+		if nil != err {
+			return nil, err
+		}
+		return doc, nil
+	}, func() (interface{}, error) {
+		r, err := f.replace(dbName, condition, replacement, true)
+		if nil != err {
+			return nil, err
+		}
+		return r.UpsertedID, nil
+	})
+}
+
+//delete the first document matching condition and decode it into res
+func (f *MongoFake) FindOneAndDelete(dbName string, condition bson.M, opts mongo.FindAndModifyOptions, res interface{}) error {
+
+	dbName = fakeDbName(dbName)
+
+	opts.Upsert = false
+	opts.Return = mongo.ReturnBefore
+
+	return f.findAndModify(dbName, condition, opts, res, func(idx int) (bson.M, error) {
+		docs := f.colls[dbName]
+		f.colls[dbName] = append(docs[:idx:idx], docs[idx+1:]...)
+		return nil, nil
+	}, nil)
+}
+
+//find the first document, change it with modify or insert one with upsert, decode the chosen version into res
+//same results as qmgo's Query.Apply: an upsert returning the document before decodes nothing
+func (f *MongoFake) findAndModify(dbName string, condition bson.M, opts mongo.FindAndModifyOptions, res interface{},
+	modify func(idx int) (bson.M, error), upsert func() (interface{}, error)) error {
+
+	if !isPointer(res) {
+		return mongo.ErrNotPointer
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	dbName = fakeDbName(dbName)
+	idx, err := f.findFirst(dbName, condition, opts.Sort)
+	if nil != err {
+		return err
+	}
+
+	if idx < 0 {
+		if !opts.Upsert || nil == upsert {
+			return mongo.ErrNotFound
+		}
+		id, err := upsert()
+		if nil != err {
+			return err
+		}
+		if mongo.ReturnAfter != opts.Return {
+			return nil
+		}
+		idx, err = f.findIndex(dbName, bson.M{"_id": id})
+		if nil != err {
+			return err
+		}
+		return decodeDoc(projectDoc(f.colls[dbName][idx], opts.Projection), res)
+	}
+
+	before := f.colls[dbName][idx]
+	after, err := modify(idx)
+	if nil != err {
+		return err
+	}
+	if mongo.ReturnAfter == opts.Return {
+		return decodeDoc(projectDoc(after, opts.Projection), res)
+	}
+
+	return decodeDoc(projectDoc(before, opts.Projection), res)
+}
+
+//remove one doc
+func (f *MongoFake) RemoveDoc(dbName string, condition bson.M) error {
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	n, err := f.remove(fakeDbName(dbName), condition, false)
+	if nil != err {
+		return err
+	}
+	if n == 0 {
+		return qmgo.ErrNoSuchDocuments
+	}
+
+	return nil
+}
+
+//remove the first or every (multi) document matching condition, caller holds mu
+func (f *MongoFake) remove(dbName string, condition bson.M, multi bool) (int64, error) {
+
+	kept := make([]bson.M, 0, len(f.colls[dbName]))
+	removed := int64(0)
+	for _, doc := range f.colls[dbName] {
+		if removed > 0 && !multi {
+			kept = append(kept, doc)
+			continue
+		}
+		ok, err := matchDoc(doc, condition)
+		if nil != err {
+			return 0, err
+		}
+		if ok {
+			removed++
+			continue
+		}
+		kept = append(kept, doc)
+	}
+	f.colls[dbName] = kept
+
+	return removed, nil
+}
+
+//insert every document of docs, stops at the first failure like an ordered insert
+func (f *MongoFake) InsertMany(dbName string, docs interface{}) (*qmgo.InsertManyResult, error) {
+
+	rv := reflect.ValueOf(docs)
+	if rv.Kind() != reflect.Slice || rv.Len() == 0 {
+		return nil, qmgo.ErrNotValidSliceToInsert
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	dbName = fakeDbName(dbName)
+	res := &qmgo.InsertManyResult{}
+	for i := 0; i < rv.Len(); i++ {
+		id, err := f.insert(dbName, rv.Index(i).Interface())
+		if nil != err {
+			return res, err
+		}
+		res.InsertedIDs = append(res.InsertedIDs, id)
+	}
+
+	return res, nil
+}
+
+//update every document matching condition
+func (f *MongoFake) UpdateMany(dbName string, condition bson.M, operator bson.M) (*qmgo.UpdateResult, error) {
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.update(fakeDbName(dbName), condition, operator, true, false)
+}
+
+//remove every document matching condition
+func (f *MongoFake) RemoveAll(dbName string, condition bson.M) (*qmgo.DeleteResult, error) {
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	n, err := f.remove(fakeDbName(dbName), condition, true)
+	if nil != err {
+		return nil, err
+	}
+
+	return &qmgo.DeleteResult{DeletedCount: n}, nil
+}
+
+//run models one after the other, failures are reported like the driver's mongo.BulkWriteException
+func (f *MongoFake) BulkWrite(dbName string, models []mongo.WriteModel, ordered bool) (*mongo.BulkResult, error) {
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	dbName = fakeDbName(dbName)
+	result := &mongo.BulkResult{Ops: make([]mongo.OpResult, len(models))}
+	bulkErr := driver.BulkWriteException{}
+
+	stopped := false
+	for i, model := range models {
+		op := &result.Ops[i]
+		op.Index, op.Op = i, model.Op
+		if stopped {
+			op.Err = mongo.ErrNotExecuted
+			continue
+		}
+
+		err := f.bulkOp(dbName, model, op, result)
+		if nil == err {
+			continue
+		}
+
+		writeErr := driver.WriteError{Index: i, Code: 2, Message: err.Error()}
+		if driver.IsDuplicateKeyError(err) {
+			writeErr = errDuplicateKey.WriteErrors[0]
+			writeErr.Index = i
+		}
+		op.Err = writeErr
+		bulkErr.WriteErrors = append(bulkErr.WriteErrors, driver.BulkWriteError{WriteError: writeErr})
+		stopped = ordered
+	}
+
+	if len(bulkErr.WriteErrors) > 0 {
+		return result, bulkErr
+	}
+
+	return result, nil
+}
+
+//apply one bulk model and count it in result, caller holds mu
+func (f *MongoFake) bulkOp(dbName string, model mongo.WriteModel, op *mongo.OpResult, result *mongo.BulkResult) error {
+
+	var res *qmgo.UpdateResult
+	var err error
+
+	switch model.Op {
+	case mongo.OpInsert:
+		op.InsertedID, err = f.insert(dbName, model.Doc)
+		if nil == err {
+			result.InsertedCount++
+		}
+		return err
+	case mongo.OpUpdateOne, mongo.OpUpdateMany:
+		res, err = f.update(dbName, model.Filter, model.Update, model.Op == mongo.OpUpdateMany, model.Upsert)
+	case mongo.OpReplaceOne:
+		res, err = f.replace(dbName, model.Filter, model.Doc, model.Upsert)
+	case mongo.OpDeleteOne, mongo.OpDeleteMany:
+		n, err := f.remove(dbName, model.Filter, model.Op == mongo.OpDeleteMany)
+		result.DeletedCount += n
+		return err
+	default:
+		return fmt.Errorf("unknown write op %d", model.Op)
+	}
+
+	if nil != err {
+		return err
+	}
+	result.MatchedCount += res.MatchedCount
+	result.ModifiedCount += res.ModifiedCount
+	result.UpsertedCount += res.UpsertedCount
+	op.UpsertedID = res.UpsertedID
+
+	return nil
+}
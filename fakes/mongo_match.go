@@ -0,0 +1,785 @@
+/**
+ * @Author KYIMH
+ * @Description
+ * @Date 2026/10/19 13:40
+ **/
+
+package fakes
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+//convert any document (struct, bson.M, bson.D, pointer) into bson.M
+func toDoc(v interface{}) (bson.M, error) {
+
+	if nil == v {
+		return bson.M{}, nil
+	}
+	if m, ok := v.(bson.M); ok {
+		v = map[string]interface{}(m)
+	}
+
+	raw, err := bson.Marshal(v)
+	if nil != err {
+		return nil, err
+	}
+
+	doc := bson.M{}
+	err = bson.Unmarshal(raw, &doc)
+	if nil != err {
+		return nil, err
+	}
+
+	return doc, nil
+}
+
+//decode doc into a caller provided pointer
+func decodeDoc(doc bson.M, res interface{}) error {
+
+	raw, err := bson.Marshal(doc)
+	if nil != err {
+		return err
+	}
+
+	return bson.Unmarshal(raw, res)
+}
+
+//decode values into a caller provided pointer to a slice, like a cursor's All
+func decodeList(values []interface{}, res interface{}) error {
+
+	t, data, err := bson.MarshalValue(bson.A(values))
+	if nil != err {
+		return err
+	}
+
+	return bson.RawValue{Type: t, Value: data}.Unmarshal(res)
+}
+
+func isPointer(res interface{}) bool {
+
+	v := reflect.ValueOf(res)
+
+	return v.Kind() == reflect.Ptr && !v.IsNil()
+}
+
+//stable sort of docs by qmgo sort fields, '-' prefix for descending, missing fields sort first
+func sortDocs(docs []bson.M, fields []string) {
+
+	if len(fields) == 0 {
+		return
+	}
+
+	sort.SliceStable(docs, func(i, j int) bool {
+		for _, field := range fields {
+			desc := strings.HasPrefix(field, "-")
+			path := strings.TrimLeft(field, "+-")
+
+			a, okA := lookupPath(docs[i], path)
+			b, okB := lookupPath(docs[j], path)
+			c := 0
+			switch {
+			case !okA && !okB:
+			case !okA:
+				c = -1
+			case !okB:
+				c = 1
+			default:
+				c, _ = compareValues(a, b)
+			}
+			if c == 0 {
+				continue
+			}
+			if desc {
+				return c > 0
+			}
+			return c < 0
+		}
+		return false
+	})
+}
+
+//copy of doc with the projection applied, inclusion keeps _id unless it is excluded
+func projectDoc(doc bson.M, projection bson.M) bson.M {
+
+	cp := copyDoc(doc)
+	if len(projection) == 0 {
+		return cp
+	}
+
+	include := false
+	for field, v := range projection {
+		if field != "_id" && truthy(v) {
+			include = true
+			break
+		}
+	}
+
+	if !include {
+		for field, v := range projection {
+			if !truthy(v) {
+				unsetPath(cp, field)
+			}
+		}
+		return cp
+	}
+
+	out := bson.M{}
+	if v, ok := projection["_id"]; !ok || truthy(v) {
+		if id, has := cp["_id"]; has {
+			out["_id"] = id
+		}
+	}
+	for field, v := range projection {
+		if field == "_id" || !truthy(v) {
+			continue
+		}
+		if value, ok := lookupPath(cp, field); ok {
+			setPath(out, field, value)
+		}
+	}
+
+	return out
+}
+
+//document an upsert starts from: the equality conditions of filter
+func upsertBase(filter bson.M) bson.M {
+
+	doc := bson.M{}
+	for path, cond := range filter {
+		if strings.HasPrefix(path, "$") {
+			continue
+		}
+		if ops := operatorDoc(cond); nil != ops {
+			if v, ok := ops["$eq"]; ok {
+				setPath(doc, path, v)
+			}
+			continue
+		}
+		setPath(doc, path, cond)
+	}
+
+	return doc
+}
+
+//deep copy a document so callers never share state with the store
+func copyDoc(doc bson.M) bson.M {
+
+	cp, err := toDoc(doc)
+	if nil != err {
+		return bson.M{}
+	}
+
+	return cp
+}
+
+//get the value at a dotted path, nested documents may be bson.M or bson.D
+func lookupPath(doc bson.M, path string) (interface{}, bool) {
+
+	var cur interface{} = doc
+	for _, part := range strings.Split(path, ".") {
+		switch v := cur.(type) {
+		case bson.M:
+			val, ok := v[part]
+			if !ok {
+				return nil, false
+			}
+			cur = val
+		case map[string]interface{}:
+			val, ok := v[part]
+			if !ok {
+				return nil, false
+			}
+			cur = val
+		case bson.D:
+			found := false
+			for _, e := range v {
+				if e.Key == part {
+					cur, found = e.Value, true
+					break
+				}
+			}
+			if !found {
+				return nil, false
+			}
+		default:
+			return nil, false
+		}
+	}
+
+	return cur, true
+}
+
+//set the value at a dotted path, missing parents are created as bson.M
+func setPath(doc bson.M, path string, value interface{}) {
+
+	parts := strings.Split(path, ".")
+	cur := doc
+	for _, part := range parts[:len(parts)-1] {
+		next, ok := cur[part].(bson.M)
+		if !ok {
+			if d, isD := cur[part].(bson.D); isD {
+				next = bson.M(d.Map())
+			} else {
+				next = bson.M{}
+			}
+			cur[part] = next
+		}
+		cur = next
+	}
+	cur[parts[len(parts)-1]] = value
+}
+
+//remove the value at a dotted path
+func unsetPath(doc bson.M, path string) {
+
+	parts := strings.Split(path, ".")
+	cur := doc
+	for _, part := range parts[:len(parts)-1] {
+		next, ok := cur[part].(bson.M)
+		if !ok {
+			return
+		}
+		cur = next
+	}
+	delete(cur, parts[len(parts)-1])
+}
+
+//filter as bson.M, accepts bson.M, bson.D, map and nil
+func toFilter(filter interface{}) (bson.M, error) {
+
+	switch f := filter.(type) {
+	case nil:
+		return bson.M{}, nil
+	case bson.M:
+		return f, nil
+	case map[string]interface{}:
+		return bson.M(f), nil
+	case bson.D:
+		return bson.M(f.Map()), nil
+	}
+
+	return toDoc(filter)
+}
+
+//match doc against a mongo query filter
+//supports field equality, dotted paths, $and $or $nor and the common field operators
+func matchDoc(doc bson.M, filter bson.M) (bool, error) {
+
+	for key, cond := range filter {
+		switch key {
+		case "$and", "$or", "$nor":
+			subs, err := toFilterList(cond)
+			if nil != err {
+				return false, err
+			}
+			matched := 0
+			for _, sub := range subs {
+				ok, err := matchDoc(doc, sub)
+				if nil != err {
+					return false, err
+				}
+				if ok {
+					matched++
+				}
+			}
+			switch key {
+			case "$and":
+				if matched != len(subs) {
+					return false, nil
+				}
+			case "$or":
+				if matched == 0 {
+					return false, nil
+				}
+			case "$nor":
+				if matched != 0 {
+					return false, nil
+				}
+			}
+		default:
+			value, exists := lookupPath(doc, key)
+			ok, err := matchField(value, exists, cond)
+			if nil != err || !ok {
+				return false, err
+			}
+		}
+	}
+
+	return true, nil
+}
+
+func toFilterList(v interface{}) ([]bson.M, error) {
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return nil, errors.New("logical operator needs an array")
+	}
+
+	subs := make([]bson.M, 0, rv.Len())
+	for i := 0; i < rv.Len(); i++ {
+		sub, err := toFilter(rv.Index(i).Interface())
+		if nil != err {
+			return nil, err
+		}
+		subs = append(subs, sub)
+	}
+
+	return subs, nil
+}
+
+//operator document like {"$gt": 1}, or nil when cond is a plain value
+func operatorDoc(cond interface{}) bson.M {
+
+	var m bson.M
+	switch c := cond.(type) {
+	case bson.M:
+		m = c
+	case map[string]interface{}:
+		m = bson.M(c)
+	case bson.D:
+		m = bson.M(c.Map())
+	default:
+		return nil
+	}
+
+	if len(m) == 0 {
+		return nil
+	}
+	for k := range m {
+		if !strings.HasPrefix(k, "$") {
+			return nil
+		}
+	}
+
+	return m
+}
+
+func matchField(value interface{}, exists bool, cond interface{}) (bool, error) {
+
+	ops := operatorDoc(cond)
+	if nil == ops {
+		return exists && equalOrContains(value, cond), nil
+	}
+
+	for op, arg := range ops {
+		var ok bool
+		switch op {
+		case "$eq":
+			ok = exists && equalOrContains(value, arg)
+		case "$ne":
+			ok = !exists || !equalOrContains(value, arg)
+		case "$gt", "$gte", "$lt", "$lte":
+			if !exists {
+				return false, nil
+			}
+			c, comparable := compareValues(value, arg)
+			if !comparable {
+				return false, nil
+			}
+			switch op {
+			case "$gt":
+				ok = c > 0
+			case "$gte":
+				ok = c >= 0
+			case "$lt":
+				ok = c < 0
+			case "$lte":
+				ok = c <= 0
+			}
+		case "$in", "$nin":
+			list := toList(arg)
+			in := false
+			for _, item := range list {
+				if exists && equalOrContains(value, item) {
+					in = true
+					break
+				}
+			}
+			ok = in == (op == "$in")
+		case "$exists":
+			ok = exists == truthy(arg)
+		case "$regex":
+			re, err := toRegexp(arg, ops["$options"])
+			if nil != err {
+				return false, err
+			}
+			s, isString := value.(string)
+			ok = exists && isString && re.MatchString(s)
+		case "$options":
+			ok = true
+		case "$not":
+			sub, err := matchField(value, exists, arg)
+			if nil != err {
+				return false, err
+			}
+			ok = !sub
+		case "$size":
+			list, isList := value.(primitive.A)
+			n, _ := toFloat(arg)
+			ok = isList && float64(len(list)) == n
+		case "$all":
+			ok = exists
+			for _, item := range toList(arg) {
+				if !equalOrContains(value, item) {
+					ok = false
+					break
+				}
+			}
+		default:
+			return false, fmt.Errorf("unsupported query operator %s", op)
+		}
+		if !ok {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+func toRegexp(pattern interface{}, options interface{}) (*regexp.Regexp, error) {
+
+	switch p := pattern.(type) {
+	case primitive.Regex:
+		return regexp.Compile(regexFlags(p.Options) + p.Pattern)
+	case *regexp.Regexp:
+		return p, nil
+	case string:
+		opts, _ := options.(string)
+		return regexp.Compile(regexFlags(opts) + p)
+	}
+
+	return nil, errors.New("$regex needs a string")
+}
+
+func regexFlags(options string) string {
+
+	flags := ""
+	for _, o := range options {
+		switch o {
+		case 'i', 'm', 's':
+			flags += string(o)
+		}
+	}
+	if "" == flags {
+		return ""
+	}
+
+	return "(?" + flags + ")"
+}
+
+func truthy(v interface{}) bool {
+
+	switch b := v.(type) {
+	case bool:
+		return b
+	case nil:
+		return false
+	}
+
+	n, ok := toFloat(v)
+	return !ok || n != 0
+}
+
+//list value of an array argument
+func toList(v interface{}) []interface{} {
+
+	rv := reflect.ValueOf(v)
+	if !rv.IsValid() || (rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array) {
+		return []interface{}{v}
+	}
+	if _, isBytes := v.([]byte); isBytes {
+		return []interface{}{v}
+	}
+
+	list := make([]interface{}, 0, rv.Len())
+	for i := 0; i < rv.Len(); i++ {
+		list = append(list, rv.Index(i).Interface())
+	}
+
+	return list
+}
+
+//value equals target, or value is an array containing target
+func equalOrContains(value interface{}, target interface{}) bool {
+
+	if equalValues(value, target) {
+		return true
+	}
+
+	if arr, ok := value.(primitive.A); ok {
+		for _, item := range arr {
+			if equalValues(item, target) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func equalValues(a interface{}, b interface{}) bool {
+
+	if c, ok := compareValues(a, b); ok {
+		return c == 0
+	}
+
+	na, errA := toDoc(bson.M{"v": a})
+	nb, errB := toDoc(bson.M{"v": b})
+	if nil != errA || nil != errB {
+		return reflect.DeepEqual(a, b)
+	}
+
+	return reflect.DeepEqual(na, nb)
+}
+
+func toFloat(v interface{}) (float64, bool) {
+
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int8:
+		return float64(n), true
+	case int16:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint:
+		return float64(n), true
+	case uint8:
+		return float64(n), true
+	case uint16:
+		return float64(n), true
+	case uint32:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case float32:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+
+	return 0, false
+}
+
+//compare two scalar values of the same family, ok is false when they are not comparable
+func compareValues(a interface{}, b interface{}) (int, bool) {
+
+	if fa, ok := toFloat(a); ok {
+		fb, ok := toFloat(b)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case fa < fb:
+			return -1, true
+		case fa > fb:
+			return 1, true
+		}
+		return 0, true
+	}
+
+	switch va := a.(type) {
+	case string:
+		vb, ok := b.(string)
+		if !ok {
+			return 0, false
+		}
+		return strings.Compare(va, vb), true
+	case bool:
+		vb, ok := b.(bool)
+		if !ok {
+			return 0, false
+		}
+		if va == vb {
+			return 0, true
+		}
+		if !va {
+			return -1, true
+		}
+		return 1, true
+	case primitive.ObjectID:
+		vb, ok := b.(primitive.ObjectID)
+		if !ok {
+			return 0, false
+		}
+		return bytes.Compare(va[:], vb[:]), true
+	case primitive.DateTime:
+		return compareTimes(va.Time(), b)
+	case time.Time:
+		return compareTimes(va, b)
+	case primitive.Binary:
+		vb, ok := b.(primitive.Binary)
+		if !ok {
+			if raw, isBytes := b.([]byte); isBytes {
+				return bytes.Compare(va.Data, raw), true
+			}
+			return 0, false
+		}
+		return bytes.Compare(va.Data, vb.Data), true
+	case []byte:
+		switch vb := b.(type) {
+		case []byte:
+			return bytes.Compare(va, vb), true
+		case primitive.Binary:
+			return bytes.Compare(va, vb.Data), true
+		}
+		return 0, false
+	}
+
+	return 0, false
+}
+
+func compareTimes(a time.Time, b interface{}) (int, bool) {
+
+	var tb time.Time
+	switch v := b.(type) {
+	case time.Time:
+		tb = v
+	case primitive.DateTime:
+		tb = v.Time()
+	default:
+		return 0, false
+	}
+
+	switch {
+	case a.Before(tb):
+		return -1, true
+	case a.After(tb):
+		return 1, true
+	}
+
+	return 0, true
+}
+
+//apply an update document ($set $unset $inc $push $addToSet $pull ...) to doc
+func applyUpdate(doc bson.M, update interface{}) error {
+
+	ops, err := toFilter(update)
+	if nil != err {
+		return err
+	}
+
+	for op, arg := range ops {
+		fields, err := toFilter(arg)
+		if nil != err {
+			return err
+		}
+		fields, err = toDoc(fields)
+		if nil != err {
+			return err
+		}
+
+		for path, value := range fields {
+			current, exists := lookupPath(doc, path)
+			switch op {
+			case "$set":
+				setPath(doc, path, value)
+			case "$setOnInsert":
+				//only used by upserts, which apply it themselves
+			case "$unset":
+				unsetPath(doc, path)
+			case "$inc":
+				setPath(doc, path, addNumbers(current, value))
+			case "$mul":
+				if !exists {
+					current = 0
+				}
+				a, _ := toFloat(current)
+				b, _ := toFloat(value)
+				setPath(doc, path, a*b)
+			case "$min", "$max":
+				c, ok := compareValues(value, current)
+				if !exists || (ok && ((op == "$min" && c < 0) || (op == "$max" && c > 0))) {
+					setPath(doc, path, value)
+				}
+			case "$push", "$addToSet":
+				arr, _ := current.(primitive.A)
+				items := []interface{}{value}
+				if each := operatorDoc(value); nil != each {
+					if list, ok := each["$each"]; ok {
+						items = toList(list)
+					}
+				}
+				for _, item := range items {
+					if op == "$addToSet" && equalOrContains(arr, item) {
+						continue
+					}
+					arr = append(arr, item)
+				}
+				setPath(doc, path, arr)
+			case "$pull":
+				arr, _ := current.(primitive.A)
+				kept := primitive.A{}
+				for _, item := range arr {
+					if !pullMatches(item, value) {
+						kept = append(kept, item)
+					}
+				}
+				setPath(doc, path, kept)
+			default:
+				return fmt.Errorf("unsupported update operator %s", op)
+			}
+		}
+	}
+
+	return nil
+}
+
+func pullMatches(item interface{}, cond interface{}) bool {
+
+	if nil != operatorDoc(cond) {
+		ok, _ := matchField(item, true, cond)
+		return ok
+	}
+
+	if sub, ok := cond.(bson.M); ok {
+		if itemDoc, isDoc := item.(bson.M); isDoc {
+			matched, _ := matchDoc(itemDoc, sub)
+			return matched
+		}
+	}
+
+	return equalValues(item, cond)
+}
+
+//sum of two bson numbers keeping integer types when possible
+func addNumbers(a interface{}, b interface{}) interface{} {
+
+	if nil == a {
+		return b
+	}
+
+	switch x := a.(type) {
+	case int32:
+		switch y := b.(type) {
+		case int32:
+			return x + y
+		case int64:
+			return int64(x) + y
+		}
+	case int64:
+		switch y := b.(type) {
+		case int32:
+			return x + int64(y)
+		case int64:
+			return x + y
+		}
+	}
+
+	fa, _ := toFloat(a)
+	fb, _ := toFloat(b)
+	return fa + fb
+}
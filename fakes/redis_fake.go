@@ -0,0 +1,1075 @@
+/**
+ * @Author KYIMH
+ * @Description
+ * @Date 2026/10/19 13:40
+ **/
+
+package fakes
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"github.com/KYIMH/CCS_Utils/redis"
+	goredis "github.com/go-redis/redis"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+//ErrWrongType -> same message redis returns for an operation against a key holding the wrong kind of value
+var ErrWrongType = errors.New("WRONGTYPE Operation against a key holding the wrong kind of value")
+
+var (
+	_ redis.Dal           = (*RedisFake)(nil)
+	_ redis.PrimaryReader = (*RedisFake)(nil)
+	_ redis.ReplicaReader = (*RedisFake)(nil)
+	_ redis.NXSetter      = (*RedisFake)(nil)
+	_ redis.XXSetter      = (*RedisFake)(nil)
+	_ redis.SortedSets    = (*RedisFake)(nil)
+	_ redis.Publisher     = (*RedisFake)(nil)
+	_ redis.Counters      = (*RedisFake)(nil)
+)
+
+type redisKind int
+
+const (
+	kindString redisKind = iota
+	kindHash
+	kindList
+	kindZSet
+)
+
+type redisEntry struct {
+	kind     redisKind
+	str      string
+	hash     map[string]string
+	list     []string
+	zset     map[string]float64
+	expireAt time.Time
+}
+
+type redisDb struct {
+	data map[string]*redisEntry
+	//closed and replaced on every push so blocked pops wake up
+	pushed chan struct{}
+}
+
+//RedisFake -> thread-safe in-memory redis.Dal
+//every redis tag is an independent keyspace, unknown tags are created on first use
+//Now: clock used for TTLs, replace it to control expiry in tests
+type RedisFake struct {
+	Now func() time.Time
+
+	mu   sync.Mutex
+	dbs  map[string]*redisDb
+	subs map[string][]*fakeSub
+	seq  int
+}
+
+//fakeSub -> subscriber registered by Subscribe
+type fakeSub struct {
+	id      int
+	pattern string
+	fn      func(channel string, payload string)
+}
+
+//create new in-memory redis
+func NewRedisFake() *RedisFake {
+
+	return &RedisFake{
+		Now:  time.Now,
+		dbs:  make(map[string]*redisDb),
+		subs: make(map[string][]*fakeSub),
+	}
+}
+
+//fake has no *redis.Client behind it
+func (f *RedisFake) GetClient(redisTag string) (*goredis.Client, error) {
+
+	return nil, errors.New("no connection " + redisTag + " in fake")
+}
+
+func (f *RedisFake) Close() error {
+
+	return nil
+}
+
+//fake has no replicas, reads are always consistent
+func (f *RedisFake) Primary() redis.Dal {
+
+	return f
+}
+
+//fake has no replicas, the replica view reads the same data
+func (f *RedisFake) Replica() redis.Dal {
+
+	return f
+}
+
+//drop every key of every tag
+func (f *RedisFake) FlushAll() {
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.dbs = make(map[string]*redisDb)
+}
+
+//caller holds mu
+func (f *RedisFake) db(redisTag string) *redisDb {
+
+	db, ok := f.dbs[redisTag]
+	if !ok {
+		db = &redisDb{
+			data:   make(map[string]*redisEntry),
+			pushed: make(chan struct{}),
+		}
+		f.dbs[redisTag] = db
+	}
+
+	return db
+}
+
+//get a live entry, expired entries are removed, caller holds mu
+func (f *RedisFake) lookup(redisTag string, key string) *redisEntry {
+
+	db := f.db(redisTag)
+	entry, ok := db.data[key]
+	if !ok {
+		return nil
+	}
+
+	if !entry.expireAt.IsZero() && !f.Now().Before(entry.expireAt) {
+		delete(db.data, key)
+		return nil
+	}
+
+	return entry
+}
+
+//get a live entry of kind, or create an empty one, caller holds mu
+func (f *RedisFake) lookupOrCreate(redisTag string, key string, kind redisKind) (*redisEntry, error) {
+
+	entry := f.lookup(redisTag, key)
+	if nil == entry {
+		entry = &redisEntry{kind: kind}
+		switch kind {
+		case kindHash:
+			entry.hash = make(map[string]string)
+		case kindZSet:
+			entry.zset = make(map[string]float64)
+		}
+		f.db(redisTag).data[key] = entry
+		return entry, nil
+	}
+
+	if entry.kind != kind {
+		return nil, ErrWrongType
+	}
+
+	return entry, nil
+}
+
+//get the string value of key, ok is false when key does not exist, caller holds mu
+func (f *RedisFake) getString(redisTag string, key string) (value string, ok bool, err error) {
+
+	entry := f.lookup(redisTag, key)
+	if nil == entry {
+		return "", false, nil
+	}
+
+	if entry.kind != kindString {
+		return "", false, ErrWrongType
+	}
+
+	return entry.str, true, nil
+}
+
+//redis String set, expire in milliseconds like ClientImpl.RedisSet
+func (f *RedisFake) RedisSet(redisTag string, key string, value interface{}, expire int) error {
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	entry := &redisEntry{kind: kindString, str: toString(value)}
+	if expire > 0 {
+		entry.expireAt = f.Now().Add(time.Duration(expire) * time.Millisecond)
+	}
+	f.db(redisTag).data[key] = entry
+
+	return nil
+}
+
+//redis String set if not exists, expire in milliseconds like ClientImpl.RedisSetNX
+func (f *RedisFake) RedisSetNX(redisTag string, key string, value interface{}, expire int) (bool, error) {
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if nil != f.lookup(redisTag, key) {
+		return false, nil
+	}
+
+	entry := &redisEntry{kind: kindString, str: toString(value)}
+	if expire > 0 {
+		entry.expireAt = f.Now().Add(time.Duration(expire) * time.Millisecond)
+	}
+	f.db(redisTag).data[key] = entry
+
+	return true, nil
+}
+
+//redis String set only if exists, expire in milliseconds like ClientImpl.RedisSetXX
+func (f *RedisFake) RedisSetXX(redisTag string, key string, value interface{}, expire int) (bool, error) {
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if nil == f.lookup(redisTag, key) {
+		return false, nil
+	}
+
+	entry := &redisEntry{kind: kindString, str: toString(value)}
+	if expire > 0 {
+		entry.expireAt = f.Now().Add(time.Duration(expire) * time.Millisecond)
+	}
+	f.db(redisTag).data[key] = entry
+
+	return true, nil
+}
+
+func (f *RedisFake) RedisKeyExists(redisTag string, key string) (bool, error) {
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return nil != f.lookup(redisTag, key), nil
+}
+
+func (f *RedisFake) RedisGet(redisTag string, key string) (string, error) {
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	value, _, _ := f.getString(redisTag, key)
+
+	return value, nil
+}
+
+func (f *RedisFake) RedisGetResult(redisTag string, key string) (interface{}, error) {
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	value, ok, err := f.getString(redisTag, key)
+	if nil != err {
+		return nil, err
+	}
+	if !ok {
+		return nil, nil
+	}
+
+	return value, nil
+}
+
+func (f *RedisFake) RedisGetInt(redisTag string, key string) (int, error) {
+
+	v, err := f.RedisGetInt64(redisTag, key)
+
+	return int(v), err
+}
+
+func (f *RedisFake) RedisGetInt64(redisTag string, key string) (int64, error) {
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	value, ok, err := f.getString(redisTag, key)
+	if nil != err || !ok {
+		return 0, err
+	}
+
+	return strconv.ParseInt(value, 10, 64)
+}
+
+func (f *RedisFake) RedisGetUint64(redisTag string, key string) (uint64, error) {
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	value, ok, err := f.getString(redisTag, key)
+	if nil != err || !ok {
+		return 0, err
+	}
+
+	return strconv.ParseUint(value, 10, 64)
+}
+
+func (f *RedisFake) RedisGetFloat64(redisTag string, key string) (float64, error) {
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	value, ok, err := f.getString(redisTag, key)
+	if nil != err || !ok {
+		return 0.0, err
+	}
+
+	return strconv.ParseFloat(value, 64)
+}
+
+//set expire of key in seconds, expire <= 0 deletes the key like redis does
+func (f *RedisFake) RedisExpire(redisTag string, key string, expire int) error {
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.expire(redisTag, key, time.Duration(expire)*time.Second)
+
+	return nil
+}
+
+//set expire of key in milliseconds like ClientImpl.RedisPExpire, returns false if key does not exist
+func (f *RedisFake) RedisPExpire(redisTag string, key string, expire int) (bool, error) {
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.expire(redisTag, key, time.Duration(expire)*time.Millisecond), nil
+}
+
+//set expire of key, ttl <= 0 deletes the key, false if key does not exist, caller holds mu
+func (f *RedisFake) expire(redisTag string, key string, ttl time.Duration) bool {
+
+	entry := f.lookup(redisTag, key)
+	if nil == entry {
+		return false
+	}
+
+	if ttl <= 0 {
+		delete(f.db(redisTag).data, key)
+		return true
+	}
+	entry.expireAt = f.Now().Add(ttl)
+
+	return true
+}
+
+//remaining time to live in milliseconds, -2 if key does not exist, -1 if key has no expire
+func (f *RedisFake) RedisPTTL(redisTag string, key string) (int, error) {
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return int(f.ttl(redisTag, key, time.Millisecond)), nil
+}
+
+//remaining time to live in seconds, -2 if key does not exist, -1 if key has no expire
+func (f *RedisFake) RedisTTL(redisTag string, key string) (int, error) {
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return int(f.ttl(redisTag, key, time.Second)), nil
+}
+
+//caller holds mu
+func (f *RedisFake) ttl(redisTag string, key string, unit time.Duration) int64 {
+
+	entry := f.lookup(redisTag, key)
+	if nil == entry {
+		return -2
+	}
+	if entry.expireAt.IsZero() {
+		return -1
+	}
+
+	//round up like redis so a live key never reports 0
+	left := entry.expireAt.Sub(f.Now())
+	return int64((left + unit - 1) / unit)
+}
+
+func (f *RedisFake) RedisDel(redisTag string, key string) error {
+
+	return f.RedisBatchDel(redisTag, key)
+}
+
+func (f *RedisFake) RedisHGet(redisTag, key, field string) (string, error) {
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	entry := f.lookup(redisTag, key)
+	if nil == entry || entry.kind != kindHash {
+		return "", nil
+	}
+
+	return entry.hash[field], nil
+}
+
+func (f *RedisFake) RedisHSet(redisTag, key, field, value string) error {
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	entry, err := f.lookupOrCreate(redisTag, key, kindHash)
+	if nil != err {
+		return err
+	}
+	entry.hash[field] = value
+
+	return nil
+}
+
+func (f *RedisFake) RedisHDel(redisTag, key, field string) error {
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	entry := f.lookup(redisTag, key)
+	if nil == entry {
+		return nil
+	}
+	if entry.kind != kindHash {
+		return ErrWrongType
+	}
+
+	delete(entry.hash, field)
+	if len(entry.hash) == 0 {
+		delete(f.db(redisTag).data, key)
+	}
+
+	return nil
+}
+
+func (f *RedisFake) RedisZAdd(redisTag, key, member, score string) error {
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	s, err := strconv.ParseFloat(score, 64)
+	if nil != err {
+		return errors.New("ERR value is not a valid float")
+	}
+
+	entry, err := f.lookupOrCreate(redisTag, key, kindZSet)
+	if nil != err {
+		return err
+	}
+	entry.zset[member] = s
+
+	return nil
+}
+
+func (f *RedisFake) RedisZRank(redisTag, key, member string) (int, error) {
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	entry := f.lookup(redisTag, key)
+	if nil == entry || entry.kind != kindZSet {
+		return -1, nil
+	}
+
+	for i, z := range sortedZSet(entry.zset) {
+		if z.Member == member {
+			return i, nil
+		}
+	}
+
+	return -1, nil
+}
+
+func (f *RedisFake) RedisZRange(redisTag string, key string, start, stop int) (values []string, err error) {
+
+	zs, err := f.RedisZRangeWithScores(redisTag, key, start, stop)
+	if nil != err {
+		return []string{}, err
+	}
+
+	values = make([]string, 0, len(zs))
+	for _, z := range zs {
+		values = append(values, z.Member.(string))
+	}
+
+	return values, nil
+}
+
+func (f *RedisFake) RedisZRangeWithScores(redisTag string, key string, start, stop int) (values []goredis.Z, err error) {
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	entry := f.lookup(redisTag, key)
+	if nil == entry {
+		return []goredis.Z{}, nil
+	}
+	if entry.kind != kindZSet {
+		return []goredis.Z{}, ErrWrongType
+	}
+
+	all := sortedZSet(entry.zset)
+	from, to, ok := rangeIndex(len(all), start, stop)
+	if !ok {
+		return []goredis.Z{}, nil
+	}
+
+	return append([]goredis.Z{}, all[from:to+1]...), nil
+}
+
+func (f *RedisFake) RedisZRem(redisTag, key, member string) error {
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	entry := f.lookup(redisTag, key)
+	if nil == entry {
+		return nil
+	}
+	if entry.kind != kindZSet {
+		return ErrWrongType
+	}
+
+	delete(entry.zset, member)
+	if len(entry.zset) == 0 {
+		delete(f.db(redisTag).data, key)
+	}
+
+	return nil
+}
+
+func (f *RedisFake) RedisZScore(redisTag, key, member string) (score float64, exists bool, err error) {
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	entry := f.lookup(redisTag, key)
+	if nil == entry {
+		return 0, false, nil
+	}
+	if entry.kind != kindZSet {
+		return 0, false, ErrWrongType
+	}
+
+	score, exists = entry.zset[member]
+
+	return score, exists, nil
+}
+
+//members of key with score in [min, max], sorted, caller holds mu
+func (f *RedisFake) zrangeByScore(redisTag, key, min, max string) ([]goredis.Z, error) {
+
+	inRange, err := ParseScoreRange(min, max)
+	if nil != err {
+		return nil, err
+	}
+
+	entry := f.lookup(redisTag, key)
+	if nil == entry {
+		return []goredis.Z{}, nil
+	}
+	if entry.kind != kindZSet {
+		return nil, ErrWrongType
+	}
+
+	values := []goredis.Z{}
+	for _, z := range sortedZSet(entry.zset) {
+		if inRange(z.Score) {
+			values = append(values, z)
+		}
+	}
+
+	return values, nil
+}
+
+func (f *RedisFake) RedisZCount(redisTag, key, min, max string) (int64, error) {
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	values, err := f.zrangeByScore(redisTag, key, min, max)
+	if nil != err {
+		return 0, err
+	}
+
+	return int64(len(values)), nil
+}
+
+func (f *RedisFake) RedisZRangeByScoreWithScores(redisTag, key, min, max string, offset, count int64) (values []goredis.Z, err error) {
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	values, err = f.zrangeByScore(redisTag, key, min, max)
+	if nil != err {
+		return []goredis.Z{}, err
+	}
+
+	if count > 0 {
+		if offset >= int64(len(values)) {
+			return []goredis.Z{}, nil
+		}
+		end := offset + count
+		if end > int64(len(values)) {
+			end = int64(len(values))
+		}
+		values = values[offset:end]
+	}
+
+	return values, nil
+}
+
+func (f *RedisFake) RedisZRemRangeByScore(redisTag, key, min, max string) (int64, error) {
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	values, err := f.zrangeByScore(redisTag, key, min, max)
+	if nil != err || len(values) == 0 {
+		return 0, err
+	}
+
+	entry := f.lookup(redisTag, key)
+	for _, z := range values {
+		delete(entry.zset, z.Member.(string))
+	}
+	if len(entry.zset) == 0 {
+		delete(f.db(redisTag).data, key)
+	}
+
+	return int64(len(values)), nil
+}
+
+func (f *RedisFake) RedisRPUSH(redisTag string, key string, member string) (err error) {
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	entry, err := f.lookupOrCreate(redisTag, key, kindList)
+	if nil != err {
+		return err
+	}
+	entry.list = append(entry.list, member)
+
+	db := f.db(redisTag)
+	close(db.pushed)
+	db.pushed = make(chan struct{})
+
+	return nil
+}
+
+//pop from the first non empty list of keys, block up to timeout, 0 blocks forever
+//returns [key, value], or an empty result when timeout is reached
+func (f *RedisFake) RedisBLPOP(redisTag string, timeout time.Duration, keys ...string) (value []string, err error) {
+
+	var deadline <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+
+	for {
+		f.mu.Lock()
+		for _, key := range keys {
+			entry := f.lookup(redisTag, key)
+			if nil == entry {
+				continue
+			}
+			if entry.kind != kindList {
+				f.mu.Unlock()
+				return []string{}, ErrWrongType
+			}
+
+			head := entry.list[0]
+			entry.list = entry.list[1:]
+			if len(entry.list) == 0 {
+				delete(f.db(redisTag).data, key)
+			}
+			f.mu.Unlock()
+
+			return []string{key, head}, nil
+		}
+		pushed := f.db(redisTag).pushed
+		f.mu.Unlock()
+
+		select {
+		case <-pushed:
+		case <-deadline:
+			return nil, nil
+		}
+	}
+}
+
+func (f *RedisFake) RedisLLEN(redisTag string, key string) (value int64, err error) {
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	entry := f.lookup(redisTag, key)
+	if nil == entry {
+		return 0, nil
+	}
+	if entry.kind != kindList {
+		return 0, ErrWrongType
+	}
+
+	return int64(len(entry.list)), nil
+}
+
+func (f *RedisFake) RedisLRange(redisTag string, key string, start, stop int) (values []string, err error) {
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	entry := f.lookup(redisTag, key)
+	if nil == entry {
+		return []string{}, nil
+	}
+	if entry.kind != kindList {
+		return []string{}, ErrWrongType
+	}
+
+	from, to, ok := rangeIndex(len(entry.list), start, stop)
+	if !ok {
+		return []string{}, nil
+	}
+
+	return append([]string{}, entry.list[from:to+1]...), nil
+}
+
+func (f *RedisFake) RedisKeys(redisTag string, pattern string) (keys []string, err error) {
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	keys = []string{}
+	for key := range f.db(redisTag).data {
+		if nil != f.lookup(redisTag, key) && MatchPattern(pattern, key) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	return keys, nil
+}
+
+func (f *RedisFake) RedisListAllValuesWithPrefix(redisTag string, prefix string) (map[string]string, error) {
+
+	keys, err := f.RedisKeys(redisTag, fmt.Sprintf("%s*", prefix))
+	if nil != err {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	values := make(map[string]string)
+	for _, key := range keys {
+		value, _, _ := f.getString(redisTag, key)
+		values[strings.TrimPrefix(key, prefix)] = value
+	}
+
+	return values, nil
+}
+
+func (f *RedisFake) RedisBatchDel(redisTag string, key ...string) error {
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	db := f.db(redisTag)
+	for _, k := range key {
+		delete(db.data, k)
+	}
+
+	return nil
+}
+
+func (f *RedisFake) RedisMset(redisTag string, pairs ...interface{}) error {
+
+	if len(pairs) == 0 || len(pairs)%2 != 0 {
+		return errors.New("ERR wrong number of arguments for 'mset' command")
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	db := f.db(redisTag)
+	for i := 0; i < len(pairs); i += 2 {
+		db.data[toString(pairs[i])] = &redisEntry{kind: kindString, str: toString(pairs[i+1])}
+	}
+
+	return nil
+}
+
+//deliver message to the subscribers of channel on redisTag
+func (f *RedisFake) RedisPublish(redisTag string, channel string, message interface{}) error {
+
+	f.mu.Lock()
+	var subs []*fakeSub
+	for _, sub := range f.subs[redisTag] {
+		if MatchPattern(sub.pattern, channel) {
+			subs = append(subs, sub)
+		}
+	}
+	f.mu.Unlock()
+
+	payload := toString(message)
+	for _, sub := range subs {
+		sub.fn(channel, payload)
+	}
+
+	return nil
+}
+
+//call fn for every message published on redisTag to a channel matching pattern
+//fn runs in the publishing goroutine, the returned func unsubscribes
+func (f *RedisFake) Subscribe(redisTag string, pattern string, fn func(channel string, payload string)) func() {
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.seq++
+	sub := &fakeSub{id: f.seq, pattern: pattern, fn: fn}
+	f.subs[redisTag] = append(f.subs[redisTag], sub)
+
+	return func() {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+
+		subs := f.subs[redisTag]
+		for i, s := range subs {
+			if s.id == sub.id {
+				f.subs[redisTag] = append(subs[:i:i], subs[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+//sort members by score, then by member like a redis sorted set
+func sortedZSet(zset map[string]float64) []goredis.Z {
+
+	all := make([]goredis.Z, 0, len(zset))
+	for member, score := range zset {
+		all = append(all, goredis.Z{Score: score, Member: member})
+	}
+
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].Score != all[j].Score {
+			return all[i].Score < all[j].Score
+		}
+		return all[i].Member.(string) < all[j].Member.(string)
+	})
+
+	return all
+}
+
+//translate redis start/stop (negative from the end, stop inclusive) into slice bounds
+func rangeIndex(length int, start int, stop int) (from int, to int, ok bool) {
+
+	if start < 0 {
+		start += length
+	}
+	if stop < 0 {
+		stop += length
+	}
+	if start < 0 {
+		start = 0
+	}
+	if stop >= length {
+		stop = length - 1
+	}
+	if start > stop || start >= length {
+		return 0, 0, false
+	}
+
+	return start, stop, true
+}
+
+//format a value the way go-redis writes command arguments
+func toString(value interface{}) string {
+
+	switch v := value.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	case nil:
+		return ""
+	case bool:
+		if v {
+			return "1"
+		}
+		return "0"
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case encoding.BinaryMarshaler:
+		//go-redis writes BinaryMarshaler values like redis.Compress wrappers by their bytes
+		if raw, err := v.MarshalBinary(); nil == err {
+			return string(raw)
+		}
+	case fmt.Stringer:
+		return v.String()
+	}
+
+	return fmt.Sprint(value)
+}
+
+//add delta to the integer value of key, caller holds mu
+func (f *RedisFake) incrBy(redisTag string, key string, delta int64) (int64, error) {
+
+	value, ok, err := f.getString(redisTag, key)
+	if nil != err {
+		return 0, err
+	}
+
+	var cur int64
+	if ok {
+		cur, err = strconv.ParseInt(value, 10, 64)
+		if nil != err {
+			return 0, errors.New("ERR value is not an integer or out of range")
+		}
+	}
+
+	cur += delta
+	entry := f.lookup(redisTag, key)
+	if nil == entry {
+		entry = &redisEntry{kind: kindString}
+		f.db(redisTag).data[key] = entry
+	}
+	entry.str = strconv.FormatInt(cur, 10)
+
+	return cur, nil
+}
+
+//set expire in milliseconds if key has none, caller holds mu
+func (f *RedisFake) expireIfPersistent(redisTag string, key string, expire int) {
+
+	entry := f.lookup(redisTag, key)
+	if nil != entry && expire > 0 && entry.expireAt.IsZero() {
+		entry.expireAt = f.Now().Add(time.Duration(expire) * time.Millisecond)
+	}
+}
+
+func (f *RedisFake) RedisIncr(redisTag string, key string) (int64, error) {
+
+	return f.RedisIncrBy(redisTag, key, 1)
+}
+
+func (f *RedisFake) RedisIncrBy(redisTag string, key string, delta int64) (int64, error) {
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.incrBy(redisTag, key, delta)
+}
+
+func (f *RedisFake) RedisIncrByFloat(redisTag string, key string, delta float64) (float64, error) {
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	value, ok, err := f.getString(redisTag, key)
+	if nil != err {
+		return 0.0, err
+	}
+
+	var cur float64
+	if ok {
+		cur, err = strconv.ParseFloat(value, 64)
+		if nil != err {
+			return 0.0, errors.New("ERR value is not a valid float")
+		}
+	}
+
+	cur += delta
+	entry := f.lookup(redisTag, key)
+	if nil == entry {
+		entry = &redisEntry{kind: kindString}
+		f.db(redisTag).data[key] = entry
+	}
+	entry.str = strconv.FormatFloat(cur, 'f', -1, 64)
+
+	return cur, nil
+}
+
+func (f *RedisFake) RedisDecr(redisTag string, key string) (int64, error) {
+
+	return f.RedisIncrBy(redisTag, key, -1)
+}
+
+func (f *RedisFake) RedisDecrBy(redisTag string, key string, delta int64) (int64, error) {
+
+	return f.RedisIncrBy(redisTag, key, -delta)
+}
+
+func (f *RedisFake) RedisIncrWithExpire(redisTag string, key string, delta int64, expire int) (int64, error) {
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	v, err := f.incrBy(redisTag, key, delta)
+	if nil != err {
+		return 0, err
+	}
+	f.expireIfPersistent(redisTag, key, expire)
+
+	return v, nil
+}
+
+func (f *RedisFake) RedisIncrCapped(redisTag string, key string, delta int64, limit int64, expire int) (value int64, capped bool, err error) {
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	cur, ok, err := f.getString(redisTag, key)
+	if nil != err {
+		return 0, false, err
+	}
+
+	var n int64
+	if ok {
+		n, err = strconv.ParseInt(cur, 10, 64)
+		if nil != err {
+			return 0, false, errors.New("ERR value is not an integer or out of range")
+		}
+	}
+	if n+delta > limit {
+		return n, true, nil
+	}
+
+	v, err := f.incrBy(redisTag, key, delta)
+	if nil != err {
+		return 0, false, err
+	}
+	f.expireIfPersistent(redisTag, key, expire)
+
+	return v, false, nil
+}
+
+func (f *RedisFake) RedisMGetInt64(redisTag string, keys ...string) ([]int64, error) {
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	counters := make([]int64, len(keys))
+	for i, key := range keys {
+		value, ok, err := f.getString(redisTag, key)
+		if nil != err || !ok {
+			continue
+		}
+		counters[i], err = strconv.ParseInt(value, 10, 64)
+		if nil != err {
+			return nil, err
+		}
+	}
+
+	return counters, nil
+}
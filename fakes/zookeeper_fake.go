@@ -0,0 +1,146 @@
+/**
+ * @Author KYIMH
+ * @Description
+ * @Date 2026/10/19 13:40
+ **/
+
+package fakes
+
+import (
+	"github.com/KYIMH/CCS_Utils/share/enum/staict_const"
+	"github.com/KYIMH/CCS_Utils/zookeeper"
+	"github.com/samuel/go-zookeeper/zk"
+	"sync"
+)
+
+var (
+	_ zookeeper.ZkClient = (*ZkFake)(nil)
+	_ zookeeper.ZkDal    = (*ZkFake)(nil)
+)
+
+//ZkFake -> thread-safe in-memory zookeeper.ZkClient and zookeeper.ZkDal
+//WatchPath: paths watched by SetWatch, like ZkClientImpl.WatchPath
+//ConfigChan: receives the data of staict_const.ConfigPath on change, like ZkClientImpl.ConfigChan
+type ZkFake struct {
+	WatchPath  []string
+	ConfigChan chan []byte
+
+	mu        sync.Mutex
+	connected bool
+	nodes     map[string][]byte
+	watchers  map[string][]func(data []byte, err error)
+}
+
+//create new in-memory zookeeper
+func NewZkFake(watchPath []string) *ZkFake {
+
+	return &ZkFake{
+		WatchPath:  watchPath,
+		ConfigChan: make(chan []byte, 1),
+		nodes:      make(map[string][]byte),
+		watchers:   make(map[string][]func(data []byte, err error)),
+	}
+}
+
+func (f *ZkFake) Connect() (err error) {
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.connected = true
+
+	return nil
+}
+
+func (f *ZkFake) Close() {
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.connected = false
+}
+
+//connected between Connect and Close
+func (f *ZkFake) Connected() bool {
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.connected
+}
+
+//register the same watcher ZkClientImpl.SetWatch does on every WatchPath
+func (f *ZkFake) SetWatch() {
+
+	for _, path := range f.WatchPath {
+		var tmpPath = path
+		f.WatchData(tmpPath, func(data []byte, err error) {
+			if nil != err || tmpPath != staict_const.ConfigPath {
+				return
+			}
+			//keep only the latest config so a slow reader never blocks the writer
+			select {
+			case <-f.ConfigChan:
+			default:
+			}
+			f.ConfigChan <- data
+		})
+	}
+}
+
+//call fn with the current data of path, then again on every change
+func (f *ZkFake) WatchData(path string, fn func(data []byte, err error)) {
+
+	f.mu.Lock()
+	f.watchers[path] = append(f.watchers[path], fn)
+	data, ok := f.nodes[path]
+	f.mu.Unlock()
+
+	if ok {
+		fn(data, nil)
+	}
+}
+
+func (f *ZkFake) GetNodeData(path string) ([]byte, error) {
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	data, ok := f.nodes[path]
+	if !ok {
+		return nil, zk.ErrNoNode
+	}
+
+	return append([]byte{}, data...), nil
+}
+
+//create or update a node and fire its watchers
+func (f *ZkFake) SetNodeData(path string, data []byte) {
+
+	f.mu.Lock()
+	f.nodes[path] = append([]byte{}, data...)
+	watchers := append([]func(data []byte, err error){}, f.watchers[path]...)
+	f.mu.Unlock()
+
+	for _, fn := range watchers {
+		fn(append([]byte{}, data...), nil)
+	}
+}
+
+//delete a node, watchers receive zk.ErrNoNode
+func (f *ZkFake) DeleteNode(path string) {
+
+	f.mu.Lock()
+	delete(f.nodes, path)
+	watchers := append([]func(data []byte, err error){}, f.watchers[path]...)
+	f.mu.Unlock()
+
+	for _, fn := range watchers {
+		fn(nil, zk.ErrNoNode)
+	}
+}
+
+func (f *ZkFake) Watch() {
+
+	f.SetWatch()
+}
@@ -0,0 +1,60 @@
+/**
+ * @Author KYIMH
+ * @Description
+ * @Date 2026/10/19 10:12
+ **/
+
+package health
+
+import (
+	"net/http"
+	"time"
+)
+
+//Status -> health status of a dependency or of the whole report
+type Status string
+
+const (
+	StatusUp   Status = "up"   // dependency answered the probe in time
+	StatusDown Status = "down" // dependency failed or timed out
+)
+
+//kind of dependency a probe belongs to
+const (
+	KindRedis     = "redis"
+	KindMongo     = "mongo"
+	KindZookeeper = "zookeeper"
+)
+
+//DependencyReport -> result of one probe
+//Name: unique name of the dependency, example: redis:cache, mongo:ccs
+//Kind: redis, mongo, zookeeper or any custom kind
+//Latency: time spent on the probe
+//Error: reason of failure, empty when Status is up
+type DependencyReport struct {
+	Name      string        `json:"name"`
+	Kind      string        `json:"kind"`
+	Status    Status        `json:"status"`
+	Latency   time.Duration `json:"latency_ns"`
+	Error     string        `json:"error,omitempty"`
+	CheckedAt time.Time     `json:"checked_at"`
+}
+
+//Report -> aggregated result of all probes
+//Status is up only when every dependency is up
+type Report struct {
+	Status       Status             `json:"status"`
+	Dependencies []DependencyReport `json:"dependencies"`
+	CheckedAt    time.Time          `json:"checked_at"`
+}
+
+//health check operators
+type Checker interface {
+	AddCheck(name string, kind string, probe func() error)
+	Check() *Report
+	LastReport() *Report
+	Start()
+	Stop()
+	RegisterHandlers(mux *http.ServeMux)
+	ListenAndServe(addr string) error
+}
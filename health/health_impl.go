@@ -0,0 +1,344 @@
+/**
+ * @Author KYIMH
+ * @Description
+ * @Date 2026/10/19 10:12
+ **/
+
+package health
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/KYIMH/CCS_Utils/mongo"
+	"github.com/KYIMH/CCS_Utils/redis"
+	"github.com/KYIMH/CCS_Utils/zookeeper"
+	"github.com/samuel/go-zookeeper/zk"
+	"github.com/sirupsen/logrus"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	defaultInterval = 10 * time.Second
+	defaultTimeout  = 3 * time.Second
+)
+
+type probe struct {
+	name string
+	kind string
+	fn   func() error
+}
+
+//CheckerImpl -> health checker implement
+//Redis: every tag in Redis.Clients() is PINGed
+//Mongo: every Cli in Mongo.Clients() is pinged
+//Zk: the session state of Zk.Conn is checked
+//Interval: period of the background check
+//Timeout: max time of a single probe, 3s if 0
+type CheckerImpl struct {
+	Redis    *redis.ClientImpl
+	Mongo    *mongo.MogClientImpl
+	Zk       *zookeeper.ZkClientImpl
+	Interval time.Duration
+	Timeout  time.Duration
+
+	mu     sync.RWMutex
+	probes []probe
+	last   *Report
+	stop   chan struct{}
+	done   chan struct{}
+}
+
+//create new health checker, nil dependencies are skipped
+func NewChecker(redisCli *redis.ClientImpl, mongoCli *mongo.MogClientImpl, zkCli *zookeeper.ZkClientImpl) *CheckerImpl {
+
+	return &CheckerImpl{
+		Redis:    redisCli,
+		Mongo:    mongoCli,
+		Zk:       zkCli,
+		Interval: defaultInterval,
+		Timeout:  defaultTimeout,
+	}
+}
+
+//add a custom probe, probe returns nil when the dependency is healthy
+func (h *CheckerImpl) AddCheck(name string, kind string, fn func() error) {
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.probes = append(h.probes, probe{name: name, kind: kind, fn: fn})
+}
+
+//collect probes of all configured dependencies
+func (h *CheckerImpl) collectProbes() []probe {
+
+	var probes []probe
+
+	if nil != h.Redis {
+		for tag, cli := range h.Redis.Clients() {
+			cli := cli
+			probes = append(probes, probe{
+				name: KindRedis + ":" + tag,
+				kind: KindRedis,
+				fn: func() error {
+					return cli.Ping().Err()
+				},
+			})
+		}
+	}
+
+	if nil != h.Mongo {
+		for name, cli := range h.Mongo.Clients() {
+			cli := cli
+			probes = append(probes, probe{
+				name: KindMongo + ":" + name,
+				kind: KindMongo,
+				fn: func() error {
+					return cli.Client.Ping(h.mongoTimeout(cli))
+				},
+			})
+		}
+	}
+
+	if nil != h.Zk {
+		probes = append(probes, probe{
+			name: KindZookeeper,
+			kind: KindZookeeper,
+			fn:   h.zkProbe,
+		})
+	}
+
+	h.mu.RLock()
+	probes = append(probes, h.probes...)
+	h.mu.RUnlock()
+
+	return probes
+}
+
+//qmgo ping timeout is in seconds, prefer the timeout of the mongo config
+func (h *CheckerImpl) mongoTimeout(cli *mongo.Cli) int64 {
+
+	if cli.Timeout > 0 {
+		return cli.Timeout
+	}
+
+	seconds := int64(h.timeout() / time.Second)
+	if seconds < 1 {
+		seconds = 1
+	}
+
+	return seconds
+}
+
+//zk is healthy only when the connection holds a session
+func (h *CheckerImpl) zkProbe() error {
+
+	if nil == h.Zk.Conn {
+		return errors.New("zk not connected")
+	}
+
+	state := h.Zk.Conn.State()
+	if state != zk.StateHasSession {
+		return errors.New("zk session state: " + state.String())
+	}
+
+	return nil
+}
+
+//probe timeout, defaultTimeout if Timeout is not set
+func (h *CheckerImpl) timeout() time.Duration {
+
+	if h.Timeout <= 0 {
+		return defaultTimeout
+	}
+
+	return h.Timeout
+}
+
+//run one probe and bound it by Timeout
+func (h *CheckerImpl) runProbe(p probe) DependencyReport {
+
+	start := time.Now()
+	res := make(chan error, 1)
+
+	go func() {
+		res <- p.fn()
+	}()
+
+	var err error
+	select {
+	case err = <-res:
+	case <-time.After(h.timeout()):
+		err = errors.New("health probe timeout")
+	}
+
+	dep := DependencyReport{
+		Name:      p.name,
+		Kind:      p.kind,
+		Status:    StatusUp,
+		Latency:   time.Since(start),
+		CheckedAt: start,
+	}
+	if nil != err {
+		dep.Status = StatusDown
+		dep.Error = err.Error()
+	}
+
+	return dep
+}
+
+//probe every dependency concurrently and store the report
+func (h *CheckerImpl) Check() *Report {
+
+	probes := h.collectProbes()
+	deps := make([]DependencyReport, len(probes))
+
+	var wg sync.WaitGroup
+	for i, p := range probes {
+		wg.Add(1)
+		go func(i int, p probe) {
+			defer wg.Done()
+			deps[i] = h.runProbe(p)
+		}(i, p)
+	}
+	wg.Wait()
+
+	sort.Slice(deps, func(i, j int) bool {
+		return deps[i].Name < deps[j].Name
+	})
+
+	report := &Report{
+		Status:       StatusUp,
+		Dependencies: deps,
+		CheckedAt:    time.Now(),
+	}
+	for _, dep := range deps {
+		if dep.Status != StatusUp {
+			report.Status = StatusDown
+			logrus.Warn("health check failed! dependency:", dep.Name, "Details:", dep.Error)
+		}
+	}
+
+	h.mu.Lock()
+	h.last = report
+	h.mu.Unlock()
+
+	return report
+}
+
+//get the latest report, nil if no check has been done yet
+func (h *CheckerImpl) LastReport() *Report {
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return h.last
+}
+
+//start checking in background every Interval
+func (h *CheckerImpl) Start() {
+
+	h.mu.Lock()
+	if nil != h.stop {
+		h.mu.Unlock()
+		return
+	}
+	h.stop = make(chan struct{})
+	h.done = make(chan struct{})
+	stop, done := h.stop, h.done
+	h.mu.Unlock()
+
+	interval := h.Interval
+	if interval <= 0 {
+		interval = defaultInterval
+	}
+
+	go func() {
+		defer close(done)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		h.Check()
+		for {
+			select {
+			case <-ticker.C:
+				h.Check()
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+//stop the background check
+func (h *CheckerImpl) Stop() {
+
+	h.mu.Lock()
+	stop, done := h.stop, h.done
+	h.stop, h.done = nil, nil
+	h.mu.Unlock()
+
+	if nil == stop {
+		return
+	}
+	close(stop)
+	<-done
+}
+
+//register /healthz and /readyz on mux
+//healthz: liveness, 200 as long as the process can serve requests
+//readyz: readiness, 200 only when every dependency is up, 503 otherwise
+func (h *CheckerImpl) RegisterHandlers(mux *http.ServeMux) {
+
+	mux.HandleFunc("/healthz", h.serveHealthz)
+	mux.HandleFunc("/readyz", h.serveReadyz)
+}
+
+//serve health endpoints on addr, blocks like http.ListenAndServe
+func (h *CheckerImpl) ListenAndServe(addr string) error {
+
+	mux := http.NewServeMux()
+	h.RegisterHandlers(mux)
+
+	return http.ListenAndServe(addr, mux)
+}
+
+func (h *CheckerImpl) serveHealthz(w http.ResponseWriter, r *http.Request) {
+
+	report := h.LastReport()
+	if nil == report {
+		report = &Report{Status: StatusUp, CheckedAt: time.Now()}
+	}
+
+	writeReport(w, http.StatusOK, report)
+}
+
+func (h *CheckerImpl) serveReadyz(w http.ResponseWriter, r *http.Request) {
+
+	report := h.LastReport()
+	if nil == report {
+		report = h.Check()
+	}
+
+	code := http.StatusOK
+	if report.Status != StatusUp {
+		code = http.StatusServiceUnavailable
+	}
+
+	writeReport(w, code, report)
+}
+
+func writeReport(w http.ResponseWriter, code int, report *Report) {
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+
+	err := json.NewEncoder(w).Encode(report)
+	if nil != err {
+		logrus.Error("write health report Error! Details:", err.Error())
+	}
+}
@@ -0,0 +1,75 @@
+/**
+ * @Author KYIMH
+ * @Description
+ * @Date 2026/10/19 18:10
+ **/
+
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+)
+
+var (
+	//ErrInProgress -> another request holds the claim of the key
+	ErrInProgress = errors.New("idempotency: request in progress")
+	//ErrNotFound -> the key has no record, it was never claimed, expired or released
+	ErrNotFound = errors.New("idempotency: key not found")
+	//ErrClaimLost -> the claim expired and was taken over before Complete, Extend or Release
+	ErrClaimLost = errors.New("idempotency: claim lost")
+)
+
+//State -> state of a record
+type State string
+
+const (
+	StatePending State = "pending" // claimed, handler still running
+	StateDone    State = "done"    // final result stored
+)
+
+//Record -> stored state of one idempotency key
+//Status, Header and Payload are only set when State is done
+type Record struct {
+	Key         string
+	State       State
+	Status      int
+	Header      http.Header
+	Payload     []byte
+	CreatedAt   time.Time
+	CompletedAt time.Time
+}
+
+//Claim -> ownership of a key returned by Claim, pass it to Complete, Extend or Release
+//Timeout: lock timeout of the claim, Extend it before it runs out when the work takes longer
+type Claim struct {
+	Key     string
+	Token   string
+	Timeout time.Duration
+}
+
+//Config -> idempotency store config
+//RedisTag: redis tag of the pool holding the records
+//Prefix: key prefix, example: ccs:idem:
+//LockTimeout: a pending claim not completed in time is dropped and the key can be claimed again
+//ResultTTL: how long a completed result is kept for replay
+//PollInterval: interval of Wait when polling a pending key
+type Config struct {
+	RedisTag     string
+	Prefix       string
+	LockTimeout  time.Duration
+	ResultTTL    time.Duration
+	PollInterval time.Duration
+}
+
+//idempotency store operators
+type Store interface {
+	Claim(key string) (*Claim, *Record, error)
+	Complete(claim *Claim, status int, header http.Header, payload []byte) error
+	Extend(claim *Claim) error
+	Release(claim *Claim) error
+	Get(key string) (*Record, error)
+	Wait(ctx context.Context, key string) (*Record, error)
+}
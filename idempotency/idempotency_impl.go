@@ -0,0 +1,266 @@
+/**
+ * @Author KYIMH
+ * @Description
+ * @Date 2026/10/19 18:10
+ **/
+
+package idempotency
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/KYIMH/CCS_Utils/redis"
+	goredis "github.com/go-redis/redis"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultPrefix       = "ccs:idem:"
+	defaultLockTimeout  = 30 * time.Second
+	defaultResultTTL    = 24 * time.Hour
+	defaultPollInterval = 50 * time.Millisecond
+)
+
+//claim the key if it has no record
+//KEYS[1]: record, ARGV[1]: token, ARGV[2]: created unix ms, ARGV[3]: lock timeout ms
+var claimScript = goredis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 1 then
+	return 0
+end
+redis.call('HMSET', KEYS[1], 'state', 'pending', 'token', ARGV[1], 'created', ARGV[2])
+redis.call('PEXPIRE', KEYS[1], ARGV[3])
+return 1
+`)
+
+//store the result if the claim is still owned by token
+//KEYS[1]: record, ARGV[1]: token, ARGV[2]: status, ARGV[3]: payload, ARGV[4]: completed unix ms, ARGV[5]: result ttl ms
+//ARGV[6]: json header
+var completeScript = goredis.NewScript(`
+if redis.call('HGET', KEYS[1], 'token') ~= ARGV[1] or redis.call('HGET', KEYS[1], 'state') ~= 'pending' then
+	return 0
+end
+redis.call('HMSET', KEYS[1], 'state', 'done', 'status', ARGV[2], 'payload', ARGV[3], 'completed', ARGV[4], 'header', ARGV[6])
+redis.call('PEXPIRE', KEYS[1], ARGV[5])
+return 1
+`)
+
+//renew the lock timeout if the claim is still owned by token
+//KEYS[1]: record, ARGV[1]: token, ARGV[2]: lock timeout ms
+var extendScript = goredis.NewScript(`
+if redis.call('HGET', KEYS[1], 'token') ~= ARGV[1] or redis.call('HGET', KEYS[1], 'state') ~= 'pending' then
+	return 0
+end
+redis.call('PEXPIRE', KEYS[1], ARGV[2])
+return 1
+`)
+
+//drop the pending record if the claim is still owned by token
+//KEYS[1]: record, ARGV[1]: token
+var releaseScript = goredis.NewScript(`
+if redis.call('HGET', KEYS[1], 'token') ~= ARGV[1] or redis.call('HGET', KEYS[1], 'state') ~= 'pending' then
+	return 0
+end
+redis.call('DEL', KEYS[1])
+return 1
+`)
+
+//StoreImpl -> idempotency store on the redis pool
+type StoreImpl struct {
+	Redis  redis.Client
+	Config Config
+}
+
+//create new idempotency store, zero fields of config take the defaults
+func NewStore(redisCli redis.Client, config Config) *StoreImpl {
+
+	if "" == config.Prefix {
+		config.Prefix = defaultPrefix
+	}
+	if config.LockTimeout <= 0 {
+		config.LockTimeout = defaultLockTimeout
+	}
+	if config.ResultTTL <= 0 {
+		config.ResultTTL = defaultResultTTL
+	}
+	if config.PollInterval <= 0 {
+		config.PollInterval = defaultPollInterval
+	}
+
+	return &StoreImpl{
+		Redis:  redisCli,
+		Config: config,
+	}
+}
+
+func (s *StoreImpl) recordKey(key string) string {
+
+	return s.Config.Prefix + key
+}
+
+func newToken() (string, error) {
+
+	buf := make([]byte, 16)
+	_, err := rand.Read(buf)
+	if nil != err {
+		return "", err
+	}
+
+	return hex.EncodeToString(buf), nil
+}
+
+func unixMs(t time.Time) int64 {
+
+	return t.UnixNano() / int64(time.Millisecond)
+}
+
+//claim key for the caller
+//returns a claim when the key is free, the record with ErrInProgress when it is pending,
+//or the record with a nil claim when the result is already stored
+func (s *StoreImpl) Claim(key string) (*Claim, *Record, error) {
+
+	cli, err := s.Redis.GetClient(s.Config.RedisTag)
+	if nil != err {
+		return nil, nil, err
+	}
+
+	token, err := newToken()
+	if nil != err {
+		return nil, nil, err
+	}
+
+	for {
+		claimed, err := claimScript.Run(cli, []string{s.recordKey(key)},
+			token, unixMs(time.Now()), int64(s.Config.LockTimeout/time.Millisecond)).Int64()
+		if nil != err {
+			return nil, nil, err
+		}
+		if claimed == 1 {
+			return &Claim{Key: key, Token: token, Timeout: s.Config.LockTimeout}, nil, nil
+		}
+
+		record, err := s.Get(key)
+		if err == ErrNotFound {
+			//the record expired between the two calls, claim again
+			continue
+		}
+		if nil != err {
+			return nil, nil, err
+		}
+		if record.State == StatePending {
+			return nil, record, ErrInProgress
+		}
+
+		return nil, record, nil
+	}
+}
+
+func (s *StoreImpl) runOwned(script *goredis.Script, claim *Claim, args ...interface{}) error {
+
+	cli, err := s.Redis.GetClient(s.Config.RedisTag)
+	if nil != err {
+		return err
+	}
+
+	ok, err := script.Run(cli, []string{s.recordKey(claim.Key)}, append([]interface{}{claim.Token}, args...)...).Int64()
+	if nil != err {
+		return err
+	}
+	if ok != 1 {
+		return ErrClaimLost
+	}
+
+	return nil
+}
+
+//store the final result of a claimed key, kept for ResultTTL, header may be nil
+func (s *StoreImpl) Complete(claim *Claim, status int, header http.Header, payload []byte) error {
+
+	encoded, err := json.Marshal(header)
+	if nil != err {
+		return err
+	}
+
+	return s.runOwned(completeScript, claim,
+		status, payload, unixMs(time.Now()), int64(s.Config.ResultTTL/time.Millisecond), encoded)
+}
+
+//renew the lock timeout of a long running claim
+func (s *StoreImpl) Extend(claim *Claim) error {
+
+	return s.runOwned(extendScript, claim, int64(s.Config.LockTimeout/time.Millisecond))
+}
+
+//give up a claim without a result so the key can be claimed again, example: the handler failed
+func (s *StoreImpl) Release(claim *Claim) error {
+
+	return s.runOwned(releaseScript, claim)
+}
+
+//get the record of key, ErrNotFound if none
+func (s *StoreImpl) Get(key string) (*Record, error) {
+
+	cli, err := s.Redis.GetClient(s.Config.RedisTag)
+	if nil != err {
+		return nil, err
+	}
+
+	fields, err := cli.HGetAll(s.recordKey(key)).Result()
+	if nil != err {
+		return nil, err
+	}
+	if len(fields) == 0 {
+		return nil, ErrNotFound
+	}
+
+	record := &Record{
+		Key:   key,
+		State: State(fields["state"]),
+	}
+	if created, err := strconv.ParseInt(fields["created"], 10, 64); nil == err {
+		record.CreatedAt = time.Unix(0, created*int64(time.Millisecond))
+	}
+	if record.State == StateDone {
+		record.Status, _ = strconv.Atoi(fields["status"])
+		record.Payload = []byte(fields["payload"])
+		//records stored before headers were kept have none
+		if header := fields["header"]; "" != header {
+			err = json.Unmarshal([]byte(header), &record.Header)
+			if nil != err {
+				return nil, err
+			}
+		}
+		if completed, err := strconv.ParseInt(fields["completed"], 10, 64); nil == err {
+			record.CompletedAt = time.Unix(0, completed*int64(time.Millisecond))
+		}
+	}
+
+	return record, nil
+}
+
+//wait until the result of key is stored
+//returns ErrNotFound if the pending claim is released or abandoned, the caller may Claim again
+func (s *StoreImpl) Wait(ctx context.Context, key string) (*Record, error) {
+
+	ticker := time.NewTicker(s.Config.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		record, err := s.Get(key)
+		if nil != err {
+			return nil, err
+		}
+		if record.State == StateDone {
+			return record, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return record, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,164 @@
+/**
+ * @Author KYIMH
+ * @Description
+ * @Date 2026/10/20 03:20
+ **/
+
+package idempotency_test
+
+import (
+	"github.com/KYIMH/CCS_Utils/idempotency"
+	"github.com/KYIMH/CCS_Utils/redis"
+	"github.com/KYIMH/CCS_Utils/redis/redistest"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+const tag = "ccs"
+
+func newClient(t *testing.T) *redis.ClientImpl {
+
+	srv, cli, err := redistest.NewClientImpl(tag)
+	if nil != err {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		cli.Close()
+		srv.Close()
+	})
+
+	return cli
+}
+
+func TestStore(t *testing.T) {
+
+	store := idempotency.NewStore(newClient(t), idempotency.Config{RedisTag: tag})
+
+	claim, _, err := store.Claim("req")
+	if nil != err || nil == claim {
+		t.Fatalf("Claim = %v, %v", claim, err)
+	}
+	if _, rec, err := store.Claim("req"); err != idempotency.ErrInProgress || nil == rec {
+		t.Fatalf("Claim of a pending key = %v, %v", rec, err)
+	}
+	if err := store.Extend(claim); nil != err {
+		t.Fatal(err)
+	}
+	header := http.Header{"Content-Type": []string{"text/plain"}}
+	if err := store.Complete(claim, 201, header, []byte("created")); nil != err {
+		t.Fatal(err)
+	}
+
+	rec, err := store.Get("req")
+	if nil != err || idempotency.StateDone != rec.State || 201 != rec.Status || "created" != string(rec.Payload) ||
+		"text/plain" != rec.Header.Get("Content-Type") {
+		t.Fatalf("Get = %+v, %v", rec, err)
+	}
+	if err := store.Release(claim); err != idempotency.ErrClaimLost {
+		t.Fatalf("Release of a completed claim = %v", err)
+	}
+}
+
+func TestStoreClaimLostAfterTimeout(t *testing.T) {
+
+	store := idempotency.NewStore(newClient(t), idempotency.Config{RedisTag: tag, LockTimeout: 50 * time.Millisecond})
+
+	stale, _, err := store.Claim("req")
+	if nil != err {
+		t.Fatal(err)
+	}
+	time.Sleep(60 * time.Millisecond)
+
+	//the lock ran out, another worker takes the key
+	claim, _, err := store.Claim("req")
+	if nil != err {
+		t.Fatalf("Claim after the lock timeout = %v", err)
+	}
+
+	if err := store.Extend(stale); err != idempotency.ErrClaimLost {
+		t.Fatalf("Extend of a lost claim = %v", err)
+	}
+	if err := store.Complete(stale, 200, nil, []byte("stale")); err != idempotency.ErrClaimLost {
+		t.Fatalf("Complete of a lost claim = %v", err)
+	}
+	if err := store.Release(stale); err != idempotency.ErrClaimLost {
+		t.Fatalf("Release of a lost claim = %v", err)
+	}
+
+	if err := store.Complete(claim, 200, nil, []byte("fresh")); nil != err {
+		t.Fatal(err)
+	}
+	if rec, err := store.Get("req"); nil != err || "fresh" != string(rec.Payload) {
+		t.Fatalf("Get = %+v, %v", rec, err)
+	}
+}
+
+func serve(handler http.Handler, method string, path string, user string, key string) *httptest.ResponseRecorder {
+
+	r := httptest.NewRequest(method, path, nil)
+	r.Header.Set(idempotency.HeaderKey, key)
+	r.Header.Set("X-User", user)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	return w
+}
+
+func userOf(r *http.Request) string {
+
+	return r.Header.Get("X-User")
+}
+
+func TestMiddlewareScope(t *testing.T) {
+
+	store := idempotency.NewStore(newClient(t), idempotency.Config{RedisTag: tag})
+	calls := 0
+	handler := idempotency.Middleware(store, 0, userOf, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Write([]byte(strconv.Itoa(calls)))
+	}))
+
+	for i, c := range []struct{ method, path, user, body string }{
+		{"POST", "/orders", "u1", "1"},
+		{"POST", "/orders", "u1", "1"}, // replay
+		{"POST", "/orders", "u2", "2"}, // another caller
+		{"POST", "/refunds", "u1", "3"},
+		{"PUT", "/orders", "u1", "4"},
+		{"POST", "/orders", "", "5"}, // anonymous, not idempotent
+		{"POST", "/orders", "", "6"},
+	} {
+		if w := serve(handler, c.method, c.path, c.user, "k"); c.body != w.Body.String() {
+			t.Fatalf("request #%d = %q, want %q", i, w.Body.String(), c.body)
+		}
+	}
+}
+
+func TestMiddlewarePanicReleasesClaim(t *testing.T) {
+
+	store := idempotency.NewStore(newClient(t), idempotency.Config{RedisTag: tag})
+	calls := 0
+	handler := idempotency.Middleware(store, 0, userOf, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if 1 == calls {
+			panic("boom")
+		}
+		w.Write([]byte("ok"))
+	}))
+
+	func() {
+		defer func() {
+			if p := recover(); "boom" != p {
+				t.Fatalf("recover = %v", p)
+			}
+		}()
+		serve(handler, "POST", "/orders", "u1", "k")
+	}()
+
+	//the retry runs the handler instead of getting 409 until the lock timeout
+	if w := serve(handler, "POST", "/orders", "u1", "k"); "ok" != w.Body.String() || 2 != calls {
+		t.Fatalf("retry after a panic = %d %q, calls %d", w.Code, w.Body.String(), calls)
+	}
+}
@@ -0,0 +1,197 @@
+/**
+ * @Author KYIMH
+ * @Description
+ * @Date 2026/10/19 18:10
+ **/
+
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/sirupsen/logrus"
+	"net/http"
+	"time"
+)
+
+const (
+	//HeaderKey -> request header carrying the idempotency key
+	HeaderKey = "Idempotency-Key"
+	//HeaderReplayed -> response header set when the response is a replay of a stored result
+	HeaderReplayed = "Idempotent-Replayed"
+)
+
+//PrincipalFunc -> authenticated caller of r, example: the user id set by the auth middleware, "" if anonymous
+type PrincipalFunc func(r *http.Request) string
+
+//store key of a request, the client key is only unique for one caller and one endpoint
+func scopedKey(r *http.Request, principal string, key string) string {
+
+	sum := sha256.Sum256([]byte(r.Method + "\x00" + r.URL.Path + "\x00" + principal + "\x00" + key))
+
+	return hex.EncodeToString(sum[:])
+}
+
+//responseRecorder -> keep a copy of status, header and body while writing through
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	header http.Header
+	body   bytes.Buffer
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+
+	if 0 == r.status {
+		r.header = r.ResponseWriter.Header().Clone()
+	}
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+
+	if 0 == r.status {
+		r.status = http.StatusOK
+		r.header = r.ResponseWriter.Header().Clone()
+	}
+	r.body.Write(b)
+
+	return r.ResponseWriter.Write(b)
+}
+
+//header to store, with the Content-Type net/http sniffs when the handler sets none
+func (r *responseRecorder) storedHeader() http.Header {
+
+	if nil == r.header {
+		r.header = r.ResponseWriter.Header().Clone()
+	}
+	if "" == r.header.Get("Content-Type") && r.body.Len() > 0 {
+		r.header.Set("Content-Type", http.DetectContentType(r.body.Bytes()))
+	}
+
+	return r.header
+}
+
+//extend claim every third of its timeout until stop is closed
+func keepClaim(store Store, claim *Claim, stop <-chan struct{}) {
+
+	timeout := claim.Timeout
+	if timeout <= 0 {
+		timeout = defaultLockTimeout
+	}
+	ticker := time.NewTicker(timeout / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			err := store.Extend(claim)
+			if err == ErrClaimLost {
+				logrus.Error("idempotency claim lost while the handler runs! key:", claim.Key)
+				return
+			}
+			if nil != err {
+				logrus.Error("idempotency Extend Error! key:", claim.Key, "Details:", err.Error())
+			}
+		}
+	}
+}
+
+//wrap next so requests with an Idempotency-Key run once and duplicates replay the stored response and headers
+//the key is scoped by method, path and the principal of the request, so one caller never replays the response of another,
+//requests without a principal run without idempotency, wrap next after the auth middleware
+//a duplicate arriving while the first request runs waits up to wait, then gets 409 Conflict
+//the claim is extended while next runs, so slow handlers keep it past the lock timeout
+//responses with status 5xx are not stored and a panic of next is re-raised, the claim is released so the client can retry
+func Middleware(store Store, wait time.Duration, principal PrincipalFunc, next http.Handler) http.Handler {
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		clientKey := r.Header.Get(HeaderKey)
+		if "" == clientKey {
+			next.ServeHTTP(w, r)
+			return
+		}
+		caller := principal(r)
+		if "" == caller {
+			next.ServeHTTP(w, r)
+			return
+		}
+		key := scopedKey(r, caller, clientKey)
+
+		claim, record, err := store.Claim(key)
+		if err == ErrInProgress && wait > 0 {
+			ctx, cancel := context.WithTimeout(r.Context(), wait)
+			record, err = store.Wait(ctx, key)
+			cancel()
+		}
+
+		switch {
+		case err == context.Canceled:
+			//the client went away while waiting, nobody reads the answer
+			return
+		case err == ErrInProgress || err == context.DeadlineExceeded:
+			http.Error(w, "request with the same idempotency key is in progress", http.StatusConflict)
+			return
+		case err == ErrNotFound:
+			//the first request gave up, let the client retry
+			http.Error(w, "request with the same idempotency key failed, retry", http.StatusConflict)
+			return
+		case nil != err:
+			logrus.Error("idempotency Claim Error! key:", key, "Details:", err.Error())
+			http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+			return
+		case nil == claim:
+			for name, values := range record.Header {
+				w.Header()[name] = values
+			}
+			w.Header().Set(HeaderReplayed, "true")
+			w.WriteHeader(record.Status)
+			w.Write(record.Payload)
+			return
+		}
+
+		stop := make(chan struct{})
+		extended := make(chan struct{})
+		go func() {
+			defer close(extended)
+			keepClaim(store, claim, stop)
+		}()
+
+		defer func() {
+			p := recover()
+			if nil == p {
+				return
+			}
+			<-extended
+			if err := store.Release(claim); nil != err {
+				logrus.Error("idempotency Release Error! key:", key, "Details:", err.Error())
+			}
+			panic(p)
+		}()
+
+		rec := &responseRecorder{ResponseWriter: w}
+		func() {
+			defer close(stop)
+			next.ServeHTTP(rec, r)
+		}()
+		<-extended
+		if 0 == rec.status {
+			rec.status = http.StatusOK
+		}
+
+		if rec.status >= http.StatusInternalServerError {
+			err = store.Release(claim)
+		} else {
+			err = store.Complete(claim, rec.status, rec.storedHeader(), rec.body.Bytes())
+		}
+		if nil != err {
+			logrus.Error("idempotency store result Error! key:", key, "Details:", err.Error())
+		}
+	})
+}
@@ -0,0 +1,57 @@
+/**
+ * @Author KYIMH
+ * @Description
+ * @Date 2026/10/19 20:10
+ **/
+
+package keyspace
+
+import (
+	"errors"
+)
+
+//ErrNotifyDisabled -> notify-keyspace-events of the server misses flags of the listened events
+var ErrNotifyDisabled = errors.New("keyspace: notify-keyspace-events does not enable the listened events")
+
+//EventType -> keyevent name as published by redis
+type EventType string
+
+const (
+	Expired EventType = "expired"
+	Evicted EventType = "evicted"
+	Del     EventType = "del"
+	Set     EventType = "set"
+)
+
+//AllTypes -> every event type the listener understands
+var AllTypes = []EventType{Expired, Evicted, Del, Set}
+
+//Event -> one keyspace notification
+type Event struct {
+	Type EventType
+	Key  string
+	DB   int
+}
+
+//Handler -> callback of matching events, called on the listener goroutine so keep it short
+type Handler func(event Event)
+
+//Config -> keyspace listener config
+//RedisTag: redis tag of the pool to listen on
+//DB: redis db index the keys live in
+//Types: events to subscribe to, AllTypes if empty
+//Enable: add missing flags with CONFIG SET, otherwise Start fails with ErrNotifyDisabled
+type Config struct {
+	RedisTag string
+	DB       int
+	Types    []EventType
+	Enable   bool
+}
+
+//keyspace listener operators
+type Listener interface {
+	Handle(pattern string, handler Handler, types ...EventType) (remove func())
+	EnsureConfig() error
+	Start() error
+	Stop()
+}
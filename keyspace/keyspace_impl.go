@@ -0,0 +1,259 @@
+/**
+ * @Author KYIMH
+ * @Description
+ * @Date 2026/10/19 20:10
+ **/
+
+package keyspace
+
+import (
+	"fmt"
+	"github.com/KYIMH/CCS_Utils/redis"
+	"github.com/KYIMH/CCS_Utils/share/glob"
+	goredis "github.com/go-redis/redis"
+	"github.com/sirupsen/logrus"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const notifyParam = "notify-keyspace-events"
+
+//notify-keyspace-events class flag of every event type
+var typeFlags = map[EventType]string{
+	Expired: "x",
+	Evicted: "e",
+	Del:     "g",
+	Set:     "$",
+}
+
+//registration -> handler registered by Handle
+type registration struct {
+	pattern string
+	types   map[EventType]bool
+	handler Handler
+}
+
+//ListenerImpl -> keyspace listener on one redis tag
+//subscribes to __keyevent@<db>__:<type> and dispatches the key to handlers whose pattern matches
+type ListenerImpl struct {
+	Redis  redis.Dal
+	Config Config
+
+	mu       sync.RWMutex
+	handlers map[int]*registration
+	seq      int
+	ps       *goredis.PubSub
+	done     chan struct{}
+}
+
+//create new keyspace listener, zero fields of config take the defaults
+func NewListener(redisDal redis.Dal, config Config) *ListenerImpl {
+
+	if len(config.Types) == 0 {
+		config.Types = AllTypes
+	}
+
+	return &ListenerImpl{
+		Redis:    redisDal,
+		Config:   config,
+		handlers: make(map[int]*registration),
+	}
+}
+
+//register handler for keys matching the redis glob pattern, every type of Config.Types if types is empty
+//handlers can be added before or after Start, call remove to unregister
+func (l *ListenerImpl) Handle(pattern string, handler Handler, types ...EventType) func() {
+
+	if len(types) == 0 {
+		types = l.Config.Types
+	}
+
+	reg := &registration{
+		pattern: pattern,
+		types:   make(map[EventType]bool, len(types)),
+		handler: handler,
+	}
+	for _, t := range types {
+		reg.types[t] = true
+	}
+
+	l.mu.Lock()
+	l.seq++
+	id := l.seq
+	l.handlers[id] = reg
+	l.mu.Unlock()
+
+	return func() {
+		l.mu.Lock()
+		delete(l.handlers, id)
+		l.mu.Unlock()
+	}
+}
+
+//flags Config.Types needs, E plus the class of every type
+func (l *ListenerImpl) requiredFlags() string {
+
+	flags := "E"
+	for _, t := range l.Config.Types {
+		class, ok := typeFlags[t]
+		if ok && !strings.Contains(flags, class) {
+			flags += class
+		}
+	}
+
+	return flags
+}
+
+//flags of required not enabled by current, A stands for every class but K, E and m
+func missingFlags(current string, required string) string {
+
+	enabled := current
+	if strings.Contains(current, "A") {
+		enabled += "g$lshzxet"
+	}
+
+	missing := ""
+	for _, flag := range required {
+		if !strings.ContainsRune(enabled, flag) {
+			missing += string(flag)
+		}
+	}
+
+	return missing
+}
+
+//check notify-keyspace-events of the server, with Config.Enable missing flags are added
+func (l *ListenerImpl) EnsureConfig() error {
+
+	cli, err := l.Redis.GetClient(l.Config.RedisTag)
+	if nil != err {
+		return err
+	}
+
+	values, err := cli.ConfigGet(notifyParam).Result()
+	if nil != err {
+		logrus.Error("keyspace config Error! tag:", l.Config.RedisTag, "Details:", err.Error())
+		return err
+	}
+
+	current := ""
+	if len(values) == 2 {
+		current, _ = values[1].(string)
+	}
+
+	missing := missingFlags(current, l.requiredFlags())
+	if "" == missing {
+		return nil
+	}
+
+	if !l.Config.Enable {
+		return fmt.Errorf("%w: have %q, missing %q", ErrNotifyDisabled, current, missing)
+	}
+
+	err = cli.ConfigSet(notifyParam, current+missing).Err()
+	if nil != err {
+		logrus.Error("keyspace config Error! tag:", l.Config.RedisTag, "Details:", err.Error())
+		return err
+	}
+
+	return nil
+}
+
+func (l *ListenerImpl) channel(t EventType) string {
+
+	return "__keyevent@" + strconv.Itoa(l.Config.DB) + "__:" + string(t)
+}
+
+//ensure the server config and subscribe, calling Start twice is a no-op
+//go-redis resubscribes after reconnects, events published while disconnected are lost
+func (l *ListenerImpl) Start() error {
+
+	l.mu.Lock()
+	started := nil != l.ps
+	l.mu.Unlock()
+	if started {
+		return nil
+	}
+
+	err := l.EnsureConfig()
+	if nil != err {
+		return err
+	}
+
+	cli, err := l.Redis.GetClient(l.Config.RedisTag)
+	if nil != err {
+		return err
+	}
+
+	channels := make([]string, 0, len(l.Config.Types))
+	for _, t := range l.Config.Types {
+		channels = append(channels, l.channel(t))
+	}
+
+	ps := cli.Subscribe(channels...)
+	_, err = ps.Receive()
+	if nil != err {
+		ps.Close()
+		return err
+	}
+
+	l.mu.Lock()
+	if nil != l.ps {
+		l.mu.Unlock()
+		ps.Close()
+		return nil
+	}
+	l.ps = ps
+	l.done = make(chan struct{})
+	done := l.done
+	l.mu.Unlock()
+
+	go func() {
+		defer close(done)
+
+		for msg := range ps.Channel() {
+			l.dispatch(msg.Channel, msg.Payload)
+		}
+	}()
+
+	return nil
+}
+
+//unsubscribe and wait for the dispatch goroutine
+func (l *ListenerImpl) Stop() {
+
+	l.mu.Lock()
+	ps, done := l.ps, l.done
+	l.ps, l.done = nil, nil
+	l.mu.Unlock()
+
+	if nil == ps {
+		return
+	}
+	ps.Close()
+	<-done
+}
+
+//hand one notification to every matching handler
+func (l *ListenerImpl) dispatch(channel string, key string) {
+
+	i := strings.LastIndexByte(channel, ':')
+	if i < 0 {
+		return
+	}
+	event := Event{Type: EventType(channel[i+1:]), Key: key, DB: l.Config.DB}
+
+	l.mu.RLock()
+	matched := make([]Handler, 0, len(l.handlers))
+	for _, reg := range l.handlers {
+		if reg.types[event.Type] && glob.Match(reg.pattern, key) {
+			matched = append(matched, reg.handler)
+		}
+	}
+	l.mu.RUnlock()
+
+	for _, handler := range matched {
+		handler(event)
+	}
+}
@@ -0,0 +1,397 @@
+/**
+ * @Author KYIMH
+ * @Description
+ * @Date 2026/10/20 00:50
+ **/
+
+package mongo
+
+import (
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+//Query -> filter builder, conditions on different fields are and-ed
+//example: filter, err := SchemaOf("chat").Query().Eq("to_id", uid).Gt("chat_id", last).Build()
+//a later condition with the same field and operator replaces the earlier one
+type Query struct {
+	schema *Schema
+	filter bson.D
+	fields []string
+	subs   []*Query
+}
+
+//query without field validation
+func NewQuery() *Query {
+
+	return &Query{}
+}
+
+func (q *Query) index(key string) int {
+
+	for i, e := range q.filter {
+		if e.Key == key {
+			return i
+		}
+	}
+
+	return -1
+}
+
+//field == value
+func (q *Query) Eq(field string, value interface{}) *Query {
+
+	q.fields = append(q.fields, field)
+
+	i := q.index(field)
+	if i < 0 {
+		q.filter = append(q.filter, bson.E{Key: field, Value: value})
+		return q
+	}
+	if ops, ok := q.filter[i].Value.(bson.D); ok && isOperatorDoc(ops) {
+		q.filter[i].Value = setElem(ops, "$eq", value)
+		return q
+	}
+	q.filter[i].Value = value
+
+	return q
+}
+
+//add operator condition to field, an earlier Eq of the field becomes $eq
+func (q *Query) op(field string, operator string, value interface{}) *Query {
+
+	q.fields = append(q.fields, field)
+
+	i := q.index(field)
+	if i < 0 {
+		q.filter = append(q.filter, bson.E{Key: field, Value: bson.D{{Key: operator, Value: value}}})
+		return q
+	}
+
+	ops, ok := q.filter[i].Value.(bson.D)
+	if !ok || !isOperatorDoc(ops) {
+		ops = bson.D{{Key: "$eq", Value: q.filter[i].Value}}
+	}
+	q.filter[i].Value = setElem(ops, operator, value)
+
+	return q
+}
+
+//field != value
+func (q *Query) Ne(field string, value interface{}) *Query {
+
+	return q.op(field, "$ne", value)
+}
+
+//field is one of values, values is a slice
+func (q *Query) In(field string, values interface{}) *Query {
+
+	return q.op(field, "$in", values)
+}
+
+//field is none of values, values is a slice
+func (q *Query) Nin(field string, values interface{}) *Query {
+
+	return q.op(field, "$nin", values)
+}
+
+//field > value
+func (q *Query) Gt(field string, value interface{}) *Query {
+
+	return q.op(field, "$gt", value)
+}
+
+//field >= value
+func (q *Query) Gte(field string, value interface{}) *Query {
+
+	return q.op(field, "$gte", value)
+}
+
+//field < value
+func (q *Query) Lt(field string, value interface{}) *Query {
+
+	return q.op(field, "$lt", value)
+}
+
+//field <= value
+func (q *Query) Lte(field string, value interface{}) *Query {
+
+	return q.op(field, "$lte", value)
+}
+
+//min <= field < max
+func (q *Query) Between(field string, min interface{}, max interface{}) *Query {
+
+	return q.Gte(field, min).Lt(field, max)
+}
+
+//field is present (exists) or missing (!exists)
+func (q *Query) Exists(field string, exists bool) *Query {
+
+	return q.op(field, "$exists", exists)
+}
+
+//field matches the regular expression pattern, options like "i" for case insensitive
+func (q *Query) Regex(field string, pattern string, options string) *Query {
+
+	q.op(field, "$regex", pattern)
+	if "" != options {
+		q.op(field, "$options", options)
+	}
+
+	return q
+}
+
+//every one of queries matches
+func (q *Query) And(queries ...*Query) *Query {
+
+	return q.logical("$and", queries)
+}
+
+//at least one of queries matches, a second Or is and-ed with the first
+func (q *Query) Or(queries ...*Query) *Query {
+
+	return q.logical("$or", queries)
+}
+
+//none of queries matches
+func (q *Query) Nor(queries ...*Query) *Query {
+
+	return q.logical("$nor", queries)
+}
+
+func (q *Query) logical(operator string, queries []*Query) *Query {
+
+	list := make(bson.A, 0, len(queries))
+	for _, sub := range queries {
+		if nil == sub {
+			continue
+		}
+		q.subs = append(q.subs, sub)
+		list = append(list, sub.filterD())
+	}
+	if len(list) == 0 {
+		return q
+	}
+
+	i := q.index(operator)
+	if i < 0 {
+		q.filter = append(q.filter, bson.E{Key: operator, Value: list})
+		return q
+	}
+	if "$and" == operator {
+		q.filter[i].Value = append(q.filter[i].Value.(bson.A), list...)
+		return q
+	}
+
+	//the same logical operator twice, keep both by and-ing them
+	return q.logical("$and", []*Query{{filter: bson.D{{Key: operator, Value: list}}}})
+}
+
+//copy of the filter so later changes of q do not leak into a parent query
+func (q *Query) filterD() bson.D {
+
+	filter := make(bson.D, len(q.filter))
+	copy(filter, q.filter)
+
+	return filter
+}
+
+//first field unknown to the schema of q or of one of its sub queries, nil if every field is known
+func (q *Query) Err() error {
+
+	return q.validate(q.schema)
+}
+
+func (q *Query) validate(schema *Schema) error {
+
+	if nil != q.schema {
+		schema = q.schema
+	}
+
+	for _, field := range q.fields {
+		if !schema.Has(field) {
+			return fmt.Errorf("%w %q in %s", ErrUnknownField, field, schema.Name())
+		}
+	}
+	for _, sub := range q.subs {
+		err := sub.validate(schema)
+		if nil != err {
+			return err
+		}
+	}
+
+	return nil
+}
+
+//filter as bson.M, the argument of GetDoc, FindMany, UpdateDoc ...
+func (q *Query) Build() (bson.M, error) {
+
+	err := q.Err()
+	if nil != err {
+		return nil, err
+	}
+
+	filter := bson.M{}
+	for _, e := range q.filter {
+		filter[e.Key] = e.Value
+	}
+
+	return filter, nil
+}
+
+//filter as bson.D, keeps the order conditions were added in
+func (q *Query) BuildD() (bson.D, error) {
+
+	err := q.Err()
+	if nil != err {
+		return nil, err
+	}
+
+	return q.filterD(), nil
+}
+
+//Update -> update document builder
+//example: update, err := SchemaOf("chat").Update().Set("msg", b).Inc("read", 1).Build()
+type Update struct {
+	schema *Schema
+	ops    bson.D
+	fields []string
+}
+
+//update without field validation
+func NewUpdate() *Update {
+
+	return &Update{}
+}
+
+//set field of operator to value, a later call for the same operator and field replaces the value
+func (u *Update) op(operator string, field string, value interface{}) *Update {
+
+	u.fields = append(u.fields, field)
+
+	for i, e := range u.ops {
+		if e.Key == operator {
+			u.ops[i].Value = setElem(e.Value.(bson.D), field, value)
+			return u
+		}
+	}
+	u.ops = append(u.ops, bson.E{Key: operator, Value: bson.D{{Key: field, Value: value}}})
+
+	return u
+}
+
+//field = value
+func (u *Update) Set(field string, value interface{}) *Update {
+
+	return u.op("$set", field, value)
+}
+
+//remove field
+func (u *Update) Unset(field string) *Update {
+
+	return u.op("$unset", field, "")
+}
+
+//field += n
+func (u *Update) Inc(field string, n interface{}) *Update {
+
+	return u.op("$inc", field, n)
+}
+
+//append values to the array field
+func (u *Update) Push(field string, values ...interface{}) *Update {
+
+	return u.op("$push", field, eachValue(values))
+}
+
+//append values not already in the array field
+func (u *Update) AddToSet(field string, values ...interface{}) *Update {
+
+	return u.op("$addToSet", field, eachValue(values))
+}
+
+//remove items of the array field equal to value or matching a condition, example: bson.M{"$lt": 3}
+func (u *Update) Pull(field string, value interface{}) *Update {
+
+	return u.op("$pull", field, value)
+}
+
+//first field unknown to the schema, nil if every field is known
+func (u *Update) Err() error {
+
+	for _, field := range u.fields {
+		if !u.schema.Has(field) {
+			return fmt.Errorf("%w %q in %s", ErrUnknownField, field, u.schema.Name())
+		}
+	}
+
+	return nil
+}
+
+//update as bson.M, the operator argument of UpdateDoc
+func (u *Update) Build() (bson.M, error) {
+
+	err := u.Err()
+	if nil != err {
+		return nil, err
+	}
+
+	update := bson.M{}
+	for _, e := range u.ops {
+		update[e.Key] = e.Value
+	}
+
+	return update, nil
+}
+
+//update as bson.D, keeps the order operators were added in
+func (u *Update) BuildD() (bson.D, error) {
+
+	err := u.Err()
+	if nil != err {
+		return nil, err
+	}
+
+	update := make(bson.D, len(u.ops))
+	copy(update, u.ops)
+
+	return update, nil
+}
+
+//one value as is, several as $each
+func eachValue(values []interface{}) interface{} {
+
+	if len(values) == 1 {
+		return values[0]
+	}
+
+	return bson.D{{Key: "$each", Value: bson.A(values)}}
+}
+
+//copy of d with key set to value, replacing an existing key in place
+func setElem(d bson.D, key string, value interface{}) bson.D {
+
+	out := make(bson.D, len(d), len(d)+1)
+	copy(out, d)
+
+	for i, e := range out {
+		if e.Key == key {
+			out[i].Value = value
+			return out
+		}
+	}
+
+	return append(out, bson.E{Key: key, Value: value})
+}
+
+func isOperatorDoc(d bson.D) bool {
+
+	for _, e := range d {
+		if len(e.Key) == 0 || '$' != e.Key[0] {
+			return false
+		}
+	}
+
+	return len(d) > 0
+}
@@ -0,0 +1,250 @@
+/**
+ * @Author KYIMH
+ * @Description
+ * @Date 2026/10/20 01:10
+ **/
+
+package mongo
+
+import (
+	"errors"
+	"fmt"
+	"github.com/KYIMH/CCS_Utils/share/enum/staict_const"
+	"github.com/qiniu/qmgo"
+	"github.com/qiniu/qmgo/middleware"
+	"github.com/qiniu/qmgo/operator"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	driver "go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+//insert every document of docs in one round trip, docs is a slice
+func (d ctxDal) InsertMany(dbName string, docs interface{}) (*qmgo.InsertManyResult, error) {
+
+	if "" == dbName {
+		dbName = staict_const.Chat
+	}
+
+	cli, release, err := d.m.acquire(dbName)
+	if nil != err {
+		return nil, err
+	}
+	defer release()
+
+	return cli.Coll.InsertMany(d.ctx, docs)
+}
+
+//update every document matching condition
+func (d ctxDal) UpdateMany(dbName string, condition bsonM, operator bsonM) (*qmgo.UpdateResult, error) {
+
+	if "" == dbName {
+		dbName = staict_const.Chat
+	}
+
+	cli, release, err := d.m.acquire(dbName)
+	if nil != err {
+		return nil, err
+	}
+	defer release()
+
+	if nil == condition {
+		condition = bsonM{}
+	}
+
+	return cli.Coll.UpdateAll(d.ctx, condition, operator)
+}
+
+//remove every document matching condition, an empty condition removes the whole collection
+func (d ctxDal) RemoveAll(dbName string, condition bsonM) (*qmgo.DeleteResult, error) {
+
+	if "" == dbName {
+		dbName = staict_const.Chat
+	}
+
+	cli, release, err := d.m.acquire(dbName)
+	if nil != err {
+		return nil, err
+	}
+	defer release()
+
+	if nil == condition {
+		condition = bsonM{}
+	}
+
+	return cli.Coll.RemoveAll(d.ctx, condition)
+}
+
+//run models in one round trip, ordered stops at the first failing model, unordered tries every model
+//result is returned with the error of failing models, a nil result means nothing is known about the batch
+func (d ctxDal) BulkWrite(dbName string, models []WriteModel, ordered bool) (*BulkResult, error) {
+
+	if "" == dbName {
+		dbName = staict_const.Chat
+	}
+
+	result := &BulkResult{Ops: make([]OpResult, len(models))}
+	if len(models) == 0 {
+		return result, nil
+	}
+
+	writes := make([]driver.WriteModel, 0, len(models))
+	for i, model := range models {
+		result.Ops[i] = OpResult{Index: i, Op: model.Op}
+
+		write, id, err := driverModel(model)
+		if nil != err {
+			return nil, fmt.Errorf("mongo bulk model %d: %w", i, err)
+		}
+		result.Ops[i].InsertedID = id
+		writes = append(writes, write)
+	}
+
+	cli, release, err := d.m.acquire(dbName)
+	if nil != err {
+		return nil, err
+	}
+	defer release()
+
+	coll, err := cli.Coll.CloneCollection()
+	if nil != err {
+		return nil, err
+	}
+
+	res, err := coll.BulkWrite(d.ctx, writes, options.BulkWrite().SetOrdered(ordered))
+	if nil != res {
+		result.InsertedCount = res.InsertedCount
+		result.MatchedCount = res.MatchedCount
+		result.ModifiedCount = res.ModifiedCount
+		result.DeletedCount = res.DeletedCount
+		result.UpsertedCount = res.UpsertedCount
+		for i, id := range res.UpsertedIDs {
+			result.Ops[i].UpsertedID = id
+		}
+	}
+	if nil == err {
+		return result, afterHooks(models, result)
+	}
+
+	var bulkErr driver.BulkWriteException
+	if !errors.As(err, &bulkErr) {
+		return nil, err
+	}
+
+	opErrs := make(map[int]error, len(bulkErr.WriteErrors))
+	for _, writeErr := range bulkErr.WriteErrors {
+		opErrs[writeErr.Index] = writeErr.WriteError
+	}
+	markOpErrors(result, opErrs, ordered)
+	afterHooks(models, result)
+
+	return result, err
+}
+
+//run the qmgo after hooks of the insert and replace models that succeeded, like InsertDoc and qmgo ReplaceOne do
+func afterHooks(models []WriteModel, result *BulkResult) error {
+
+	for i, model := range models {
+		if nil != result.Ops[i].Err {
+			continue
+		}
+
+		var err error
+		switch model.Op {
+		case OpInsert:
+			err = middleware.Do(model.Doc, operator.AfterInsert, model.Doc)
+		case OpReplaceOne:
+			err = middleware.Do(model.Doc, operator.AfterReplace, model.Doc)
+		}
+		if nil != err {
+			return fmt.Errorf("mongo bulk model %d: %w", i, err)
+		}
+	}
+
+	return nil
+}
+
+//set the error of failed operations, in ordered mode every operation after the first failure was not executed
+func markOpErrors(result *BulkResult, opErrs map[int]error, ordered bool) {
+
+	stopped := false
+	for i := range result.Ops {
+		if stopped {
+			result.Ops[i].Err = ErrNotExecuted
+		} else if err, ok := opErrs[i]; ok {
+			result.Ops[i].Err = err
+			stopped = ordered
+		}
+		if nil != result.Ops[i].Err {
+			result.Ops[i].InsertedID = nil
+		}
+	}
+}
+
+//driver write model of model, and the _id of an inserted document
+//insert and replace documents go through the qmgo before hooks and default fields first, like InsertDoc does
+func driverModel(model WriteModel) (driver.WriteModel, interface{}, error) {
+
+	filter := model.Filter
+	if nil == filter {
+		filter = bsonM{}
+	}
+
+	switch model.Op {
+	case OpInsert:
+		err := middleware.Do(model.Doc, operator.BeforeInsert, model.Doc)
+		if nil != err {
+			return nil, nil, err
+		}
+		doc, id, err := withId(model.Doc)
+		if nil != err {
+			return nil, nil, err
+		}
+		return driver.NewInsertOneModel().SetDocument(doc), id, nil
+	case OpUpdateOne:
+		return driver.NewUpdateOneModel().SetFilter(filter).SetUpdate(model.Update).SetUpsert(model.Upsert), nil, nil
+	case OpUpdateMany:
+		return driver.NewUpdateManyModel().SetFilter(filter).SetUpdate(model.Update).SetUpsert(model.Upsert), nil, nil
+	case OpReplaceOne:
+		err := middleware.Do(model.Doc, operator.BeforeReplace, model.Doc)
+		if nil != err {
+			return nil, nil, err
+		}
+		return driver.NewReplaceOneModel().SetFilter(filter).SetReplacement(model.Doc).SetUpsert(model.Upsert), nil, nil
+	case OpDeleteOne:
+		return driver.NewDeleteOneModel().SetFilter(filter), nil, nil
+	case OpDeleteMany:
+		return driver.NewDeleteManyModel().SetFilter(filter), nil, nil
+	}
+
+	return nil, nil, fmt.Errorf("unknown write op %d", model.Op)
+}
+
+//doc with an _id, a new ObjectID is put in front when doc has none
+func withId(doc interface{}) (interface{}, interface{}, error) {
+
+	raw, err := bson.Marshal(doc)
+	if nil != err {
+		return nil, nil, err
+	}
+
+	value, err := bson.Raw(raw).LookupErr("_id")
+	if nil == err {
+		var id interface{}
+		err = value.Unmarshal(&id)
+		if nil != err {
+			return nil, nil, err
+		}
+		return doc, id, nil
+	}
+
+	var fields bson.D
+	err = bson.Unmarshal(raw, &fields)
+	if nil != err {
+		return nil, nil, err
+	}
+
+	id := primitive.NewObjectID()
+
+	return append(bson.D{{Key: "_id", Value: id}}, fields...), id, nil
+}
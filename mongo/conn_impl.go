@@ -0,0 +1,93 @@
+/**
+ * @Author KYIMH
+ * @Description
+ * @Date 2026/10/20 00:30
+ **/
+
+package mongo
+
+import (
+	"context"
+	"github.com/qiniu/qmgo"
+	"sync"
+)
+
+//sharedConn -> one qmgo.Client and the number of pool entries using it
+type sharedConn struct {
+	client *qmgo.Client
+	users  int
+}
+
+//connPool -> qmgo.Client per uri and credentials, shared by every Cli connecting with them
+type connPool struct {
+	mu    sync.Mutex
+	conns map[string]*sharedConn
+}
+
+//clients with the same uri and credentials share one connection
+func connKey(config MgoConfig) string {
+
+	return config.Uri + "\x00" + config.Username + "\x00" + config.Password + "\x00" + config.AuthDb
+}
+
+//the qmgo.Client of config's uri, opened on first use
+func (p *connPool) connect(ctx context.Context, config MgoConfig) (*qmgo.Client, string, error) {
+
+	key := connKey(config)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if nil == p.conns {
+		p.conns = make(map[string]*sharedConn)
+	}
+
+	conn, ok := p.conns[key]
+	if !ok {
+		client, err := qmgo.NewClient(ctx, connectConfig(config))
+		if nil != err {
+			return nil, "", err
+		}
+		conn = &sharedConn{client: client}
+		p.conns[key] = conn
+	}
+	conn.users++
+
+	return conn.client, key, nil
+}
+
+//drop one user of the connection under key, the connection is closed with its last user
+func (p *connPool) disconnect(ctx context.Context, key string) error {
+
+	p.mu.Lock()
+	conn, ok := p.conns[key]
+	if !ok {
+		p.mu.Unlock()
+		return nil
+	}
+
+	conn.users--
+	if conn.users > 0 {
+		p.mu.Unlock()
+		return nil
+	}
+	delete(p.conns, key)
+	p.mu.Unlock()
+
+	return conn.client.Close(ctx)
+}
+
+//qmgo config of the connection, Database and Coll are picked per Cli
+func connectConfig(config MgoConfig) *qmgo.Config {
+
+	return &qmgo.Config{
+		Uri:      config.Uri,
+		Database: config.DbName,
+		Coll:     config.Coll,
+		Auth: &qmgo.Credential{
+			Username:   config.Username,
+			Password:   config.Password,
+			AuthSource: config.AuthDb,
+		},
+	}
+}
@@ -0,0 +1,116 @@
+/**
+ * @Author KYIMH
+ * @Description
+ * @Date 2026/10/20 01:50
+ **/
+
+package mongo
+
+import (
+	"context"
+	"github.com/qiniu/qmgo"
+)
+
+var _ MogDal = (*MogClientImpl)(nil)
+
+//ctxDal -> MogDal operators of a MogClientImpl running on ctx
+//the operators of MogClientImpl use its Context, WithContext binds another one like the sessCtx of a transaction
+type ctxDal struct {
+	m   *MogClientImpl
+	ctx context.Context
+}
+
+//MogDal running every operator on ctx
+//example: inside WithTransaction, m.WithContext(sessCtx).InsertDoc(...) joins the transaction
+func (m *MogClientImpl) WithContext(ctx context.Context) MogDal {
+
+	return m.dal(ctx)
+}
+
+func (m *MogClientImpl) dal(ctx context.Context) ctxDal {
+
+	if nil == ctx {
+		ctx = m.GetCtx()
+	}
+
+	return ctxDal{m: m, ctx: ctx}
+}
+
+/*================
+operators on m.Context
+==================*/
+
+func (m *MogClientImpl) InsertDoc(dbName string, data interface{}) (*qmgo.InsertOneResult, error) {
+
+	return m.dal(m.GetCtx()).InsertDoc(dbName, data)
+}
+
+func (m *MogClientImpl) GetDoc(dbName string, condition bsonM, res interface{}) error {
+
+	return m.dal(m.GetCtx()).GetDoc(dbName, condition, res)
+}
+
+func (m *MogClientImpl) FindMany(dbName string, condition bsonM, opts FindOptions, res interface{}) error {
+
+	return m.dal(m.GetCtx()).FindMany(dbName, condition, opts, res)
+}
+
+func (m *MogClientImpl) Count(dbName string, condition bsonM) (int64, error) {
+
+	return m.dal(m.GetCtx()).Count(dbName, condition)
+}
+
+func (m *MogClientImpl) Distinct(dbName string, field string, condition bsonM, res interface{}) error {
+
+	return m.dal(m.GetCtx()).Distinct(dbName, field, condition, res)
+}
+
+func (m *MogClientImpl) UpdateDoc(dbName string, condition bsonM, operator bsonM) error {
+
+	return m.dal(m.GetCtx()).UpdateDoc(dbName, condition, operator)
+}
+
+func (m *MogClientImpl) RemoveDoc(dbName string, condition bsonM) error {
+
+	return m.dal(m.GetCtx()).RemoveDoc(dbName, condition)
+}
+
+func (m *MogClientImpl) InsertMany(dbName string, docs interface{}) (*qmgo.InsertManyResult, error) {
+
+	return m.dal(m.GetCtx()).InsertMany(dbName, docs)
+}
+
+func (m *MogClientImpl) UpdateMany(dbName string, condition bsonM, operator bsonM) (*qmgo.UpdateResult, error) {
+
+	return m.dal(m.GetCtx()).UpdateMany(dbName, condition, operator)
+}
+
+func (m *MogClientImpl) RemoveAll(dbName string, condition bsonM) (*qmgo.DeleteResult, error) {
+
+	return m.dal(m.GetCtx()).RemoveAll(dbName, condition)
+}
+
+func (m *MogClientImpl) BulkWrite(dbName string, models []WriteModel, ordered bool) (*BulkResult, error) {
+
+	return m.dal(m.GetCtx()).BulkWrite(dbName, models, ordered)
+}
+
+func (m *MogClientImpl) Upsert(dbName string, condition bsonM, operator bsonM) (*qmgo.UpdateResult, error) {
+
+	return m.dal(m.GetCtx()).Upsert(dbName, condition, operator)
+}
+
+func (m *MogClientImpl) FindOneAndUpdate(dbName string, condition bsonM, operator bsonM, opts FindAndModifyOptions, res interface{}) error {
+
+	return m.dal(m.GetCtx()).FindOneAndUpdate(dbName, condition, operator, opts, res)
+}
+
+func (m *MogClientImpl) FindOneAndReplace(dbName string, condition bsonM, replacement interface{}, opts FindAndModifyOptions, res interface{}) error {
+
+	return m.dal(m.GetCtx()).FindOneAndReplace(dbName, condition, replacement, opts, res)
+}
+
+func (m *MogClientImpl) FindOneAndDelete(dbName string, condition bsonM, opts FindAndModifyOptions, res interface{}) error {
+
+	return m.dal(m.GetCtx()).FindOneAndDelete(dbName, condition, opts, res)
+}
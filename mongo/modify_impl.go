@@ -0,0 +1,105 @@
+/**
+ * @Author KYIMH
+ * @Description
+ * @Date 2026/10/20 01:30
+ **/
+
+package mongo
+
+import (
+	"github.com/KYIMH/CCS_Utils/share/enum/staict_const"
+	"github.com/qiniu/qmgo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+//update the first document matching condition, insert one built from condition and operator if none
+//example: m.Upsert("", bson.M{"chat_id": id}, bson.M{"$set": bson.M{"msg": b}, "$setOnInsert": bson.M{"from_id": uid}})
+func (d ctxDal) Upsert(dbName string, condition bsonM, operator bsonM) (*qmgo.UpdateResult, error) {
+
+	if "" == dbName {
+		dbName = staict_const.Chat
+	}
+
+	cli, release, err := d.m.acquire(dbName)
+	if nil != err {
+		return nil, err
+	}
+	defer release()
+
+	if nil == condition {
+		condition = bsonM{}
+	}
+
+	//qmgo's Upsert replaces the document, update operators need the driver
+	coll, err := cli.Coll.CloneCollection()
+	if nil != err {
+		return nil, err
+	}
+
+	res, err := coll.UpdateOne(d.ctx, condition, operator, options.Update().SetUpsert(true))
+	if nil != err {
+		return nil, err
+	}
+
+	return &qmgo.UpdateResult{
+		MatchedCount:  res.MatchedCount,
+		ModifiedCount: res.ModifiedCount,
+		UpsertedCount: res.UpsertedCount,
+		UpsertedID:    res.UpsertedID,
+	}, nil
+}
+
+//update the first document matching condition and decode it into res, before or after the update
+func (d ctxDal) FindOneAndUpdate(dbName string, condition bsonM, operator bsonM, opts FindAndModifyOptions, res interface{}) error {
+
+	return d.findAndModify(dbName, condition, qmgo.Change{Update: operator}, opts, res)
+}
+
+//replace the first document matching condition and decode it into res, before or after the replace
+func (d ctxDal) FindOneAndReplace(dbName string, condition bsonM, replacement interface{}, opts FindAndModifyOptions, res interface{}) error {
+
+	return d.findAndModify(dbName, condition, qmgo.Change{Update: replacement, Replace: true}, opts, res)
+}
+
+//delete the first document matching condition and decode it into res
+func (d ctxDal) FindOneAndDelete(dbName string, condition bsonM, opts FindAndModifyOptions, res interface{}) error {
+
+	opts.Upsert = false
+	opts.Return = ReturnBefore
+
+	return d.findAndModify(dbName, condition, qmgo.Change{Remove: true}, opts, res)
+}
+
+func (d ctxDal) findAndModify(dbName string, condition bsonM, change qmgo.Change, opts FindAndModifyOptions, res interface{}) error {
+
+	if "" == dbName {
+		dbName = staict_const.Chat
+	}
+
+	if !isPointer(res) {
+		return ErrNotPointer
+	}
+
+	cli, release, err := d.m.acquire(dbName)
+	if nil != err {
+		return err
+	}
+	defer release()
+
+	if nil == condition {
+		condition = bsonM{}
+	}
+
+	query := cli.Coll.Find(d.ctx, condition)
+	if len(opts.Sort) > 0 {
+		query = query.Sort(opts.Sort...)
+	}
+	if len(opts.Projection) > 0 {
+		query = query.Select(opts.Projection)
+	}
+
+	change.Upsert = opts.Upsert
+	change.ReturnNew = ReturnAfter == opts.Return
+
+	return query.Apply(change, res)
+}
@@ -8,9 +8,11 @@ package mongo
 
 import (
 	"context"
-	"github.com/KYIMH/CCS_Utils/share/enum/staict_const"
+	"errors"
 	"github.com/qiniu/qmgo"
 	"go.mongodb.org/mongo-driver/bson"
+	driver "go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 //Cli -> client of mongo db
@@ -20,6 +22,7 @@ import (
 //RetryTimes: try to ping mongodb RetryTimes times
 //Timeout: the timeout of mongodb ping
 //Config: include Uri, Database, Coll, Auth params
+//Client is shared by every Cli of the same uri and credentials in a MogClientImpl, close it through the pool
 type Cli struct {
 	Ctx        context.Context
 	Client     *qmgo.Client
@@ -28,9 +31,16 @@ type Cli struct {
 	RetryTimes int
 	Timeout    int64
 	Config     *qmgo.Config
+
+	refs    *refCount
+	connKey string
 }
 
 //MgoConfig -> mongo config read from zk data
+//Name: logical name of the client in the pool, used by GetClient and the MogDal operators, DbName if empty
+//
+//	example: chat_msg / chat_conversation / chat_user, all with DbName chat
+//
 //DbNAme: name of the database you want to connect in mongodb
 //Coll: name of the database you want to handle under previous database
 //Uri: address of mongodb example: [mongodb://][user:pass@]host1[:port1][,host2[:port2],...][/database][?options]
@@ -38,6 +48,7 @@ type Cli struct {
 //Timeout: refer to the explanation above (Cli.TimeOut)
 //AuthDb: the name of the database to use for authentication, necessary if user is not 'admin' user
 type MgoConfig struct {
+	Name       string `json:"name"`
 	DbName     string `json:"db_name"`
 	Coll       string `json:"coll"`
 	Uri        string `json:"uri"`
@@ -48,32 +59,219 @@ type MgoConfig struct {
 	AuthDb     string `json:"auth_db"`
 }
 
-type (
-	chatMsgType staict_const.ChatMsg
-	bsonM       bson.M
+//name of the config in the pool
+func (c MgoConfig) PoolName() string {
+
+	if "" == c.Name {
+		return c.DbName
+	}
+
+	return c.Name
+}
+
+//alias keeps MogDal implementable outside of this package
+type bsonM = bson.M
+
+var (
+	//no document matched the condition, check it with errors.Is
+	//same value as qmgo.ErrNoSuchDocuments, so existing checks keep working
+	ErrNotFound = qmgo.ErrNoSuchDocuments
+	//res of a retrieve operator is not a non-nil pointer
+	ErrNotPointer = errors.New("mongo: result must be a non-nil pointer")
+	//a Query or Update names a field its Schema does not have
+	ErrUnknownField = errors.New("mongo: unknown field")
+	//an operation of an ordered BulkWrite was not sent because an earlier one failed
+	ErrNotExecuted = errors.New("mongo: not executed, an earlier operation failed")
+	//a sequence passed the max value of the requested type
+	ErrSequenceOverflow = errors.New("mongo: sequence overflow")
+	//returned by the fn of WithTransaction to run the whole transaction again
+	ErrTransactionRetry = qmgo.ErrTransactionRetry
+)
+
+//FindOptions -> options of FindMany, zero values are ignored
+//Sort: field names, '-' prefix for descending, example: []string{"-created_at", "seq"}
+//Skip: number of matching documents to skip
+//Limit: max number of documents returned, all if 0
+//Projection: fields to include (1) or exclude (0), example: bson.M{"msg": 0}
+type FindOptions struct {
+	Sort       []string
+	Skip       int64
+	Limit      int64
+	Projection bsonM
+}
+
+//ReturnDocument -> which version of the document a FindOneAndUpdate / Replace decodes into res
+type ReturnDocument int
+
+const (
+	ReturnBefore ReturnDocument = iota
+	ReturnAfter
 )
 
+//FindAndModifyOptions -> options of FindOneAndUpdate / Replace / Delete, zero values are ignored
+//Return: ReturnBefore (default) or ReturnAfter the change, not used by delete
+//Upsert: insert a document when the condition matches nothing, not used by delete
+//
+//	with ReturnBefore nothing is decoded into res for an inserted document and no error is returned
+//
+//Sort: picks the document when several match, same format as FindOptions.Sort
+//Projection: fields of the returned document, same format as FindOptions.Projection
+type FindAndModifyOptions struct {
+	Return     ReturnDocument
+	Upsert     bool
+	Sort       []string
+	Projection bsonM
+}
+
+//WriteOp -> kind of a BulkWrite operation
+type WriteOp int
+
+const (
+	OpInsert WriteOp = iota
+	OpUpdateOne
+	OpUpdateMany
+	OpReplaceOne
+	OpDeleteOne
+	OpDeleteMany
+)
+
+//WriteModel -> one operation of BulkWrite, build it with InsertModel, UpdateModel ...
+//Doc: document of OpInsert, replacement of OpReplaceOne, pass a pointer to get the qmgo default fields and hooks
+//Filter: condition of update, replace and delete operations
+//Update: update operators of OpUpdateOne / OpUpdateMany
+//Upsert: insert a document when Filter matches nothing, update and replace only
+type WriteModel struct {
+	Op     WriteOp
+	Filter bsonM
+	Update bsonM
+	Doc    interface{}
+	Upsert bool
+}
+
+//insert doc, _id is generated when missing
+func InsertModel(doc interface{}) WriteModel {
+
+	return WriteModel{Op: OpInsert, Doc: doc}
+}
+
+//update the first document matching filter
+func UpdateModel(filter bsonM, update bsonM) WriteModel {
+
+	return WriteModel{Op: OpUpdateOne, Filter: filter, Update: update}
+}
+
+//update every document matching filter
+func UpdateManyModel(filter bsonM, update bsonM) WriteModel {
+
+	return WriteModel{Op: OpUpdateMany, Filter: filter, Update: update}
+}
+
+//update the first document matching filter, insert one built from filter and update if none
+func UpsertModel(filter bsonM, update bsonM) WriteModel {
+
+	return WriteModel{Op: OpUpdateOne, Filter: filter, Update: update, Upsert: true}
+}
+
+//replace the first document matching filter by doc
+func ReplaceModel(filter bsonM, doc interface{}, upsert bool) WriteModel {
+
+	return WriteModel{Op: OpReplaceOne, Filter: filter, Doc: doc, Upsert: upsert}
+}
+
+//delete the first document matching filter
+func DeleteModel(filter bsonM) WriteModel {
+
+	return WriteModel{Op: OpDeleteOne, Filter: filter}
+}
+
+//delete every document matching filter
+func DeleteManyModel(filter bsonM) WriteModel {
+
+	return WriteModel{Op: OpDeleteMany, Filter: filter}
+}
+
+//OpResult -> outcome of one WriteModel, Index is its position in the models passed to BulkWrite
+//InsertedID: _id of the inserted document, OpInsert only
+//UpsertedID: _id of the document inserted by an upsert
+//Err: the write error of the operation, ErrNotExecuted if an ordered batch stopped before it
+type OpResult struct {
+	Index      int
+	Op         WriteOp
+	InsertedID interface{}
+	UpsertedID interface{}
+	Err        error
+}
+
+//BulkResult -> counters of a BulkWrite and one OpResult per model
+type BulkResult struct {
+	InsertedCount int64
+	MatchedCount  int64
+	ModifiedCount int64
+	DeletedCount  int64
+	UpsertedCount int64
+	Ops           []OpResult
+}
+
+//atomically increasing counters, example: ChatMsg.ChatId
+//values of one name are unique across processes, values of Next are increasing within a process
+type Sequencer interface {
+	Next(name string) (uint64, error)
+	//Next limited to uint32, ErrSequenceOverflow once the counter passed math.MaxUint32
+	NextUint32(name string) (uint32, error)
+	//reserve n consecutive values, returns the first one
+	Reserve(name string, n uint64) (uint64, error)
+	//raise the counter to at least value, example: the max ChatId already stored
+	Seed(name string, value uint64) error
+}
+
 //operators of mongo client like: create mongo client, init mongo pool ...
 type MogClient interface {
-	initPool()
 	AddClient2Pool(mongoConfig MgoConfig) error
-	GetClient(dbName string) (*Cli, error)
+	RemoveClient(name string) error
+	GetClient(name string) (*Cli, error)
+	Collection(dbName string, collName string) (*qmgo.Collection, error)
+	Clients() MogPoolType
 	CreateFixedMongoCli(config MgoConfig) (*Cli, error)
 	GetCtx() context.Context
+	//MogDal whose operators run on ctx, example: the sessCtx of WithTransaction
+	WithContext(ctx context.Context) MogDal
+	WithTransaction(ctx context.Context, dbName string, fn func(sessCtx context.Context) error) error
+	Watch(ctx context.Context, dbName string, pipeline interface{}, opts *options.ChangeStreamOptions) (*driver.ChangeStream, error)
 	Close() error
 }
 
-// mongo data operators
+//mongo data operators, dbName is the pool name of the client (MgoConfig.PoolName()), staict_const.Chat if empty
 type MogDal interface {
 	//Create
 	InsertDoc(dbName string, data interface{}) (*qmgo.InsertOneResult, error)
 
 	//Retrieve
-	GetDoc(dbName string, condition bsonM, res chatMsgType) error
+	//res is a pointer to the value the document is decoded into, ErrNotFound if no document matches
+	GetDoc(dbName string, condition bsonM, res interface{}) error
+	//res is a pointer to a slice, it is empty if no document matches
+	FindMany(dbName string, condition bsonM, opts FindOptions, res interface{}) error
+	Count(dbName string, condition bsonM) (int64, error)
+	//res is a pointer to a slice of the field's type, array fields are unwound
+	Distinct(dbName string, field string, condition bsonM, res interface{}) error
 
 	//Update
 	UpdateDoc(dbName string, condition bsonM, operator bsonM) error
+	//update the first document matching condition, insert one built from condition and operator if none
+	Upsert(dbName string, condition bsonM, operator bsonM) (*qmgo.UpdateResult, error)
+	//atomic read-modify-write, res is a pointer, ErrNotFound if no document matches
+	FindOneAndUpdate(dbName string, condition bsonM, operator bsonM, opts FindAndModifyOptions, res interface{}) error
+	FindOneAndReplace(dbName string, condition bsonM, replacement interface{}, opts FindAndModifyOptions, res interface{}) error
+	FindOneAndDelete(dbName string, condition bsonM, opts FindAndModifyOptions, res interface{}) error
 
 	//Delete
 	RemoveDoc(dbName string, condition bsonM) error
+
+	//Bulk
+	//docs is a slice of documents
+	InsertMany(dbName string, docs interface{}) (*qmgo.InsertManyResult, error)
+	UpdateMany(dbName string, condition bsonM, operator bsonM) (*qmgo.UpdateResult, error)
+	RemoveAll(dbName string, condition bsonM) (*qmgo.DeleteResult, error)
+	//one round trip for models, ordered stops at the first failing model, unordered tries every model
+	//the error is a mongo.BulkWriteException of the driver when models failed, see BulkResult.Ops for each of them
+	BulkWrite(dbName string, models []WriteModel, ordered bool) (*BulkResult, error)
 }
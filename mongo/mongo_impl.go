@@ -9,19 +9,34 @@ package mongo
 import (
 	"context"
 	"errors"
+	"fmt"
 	"github.com/KYIMH/CCS_Utils/share/enum/staict_const"
+	"github.com/KYIMH/CCS_Utils/share/multierr"
 	"github.com/qiniu/qmgo"
+	"github.com/sirupsen/logrus"
+	"reflect"
+	"sync"
+	"time"
 )
 
+const defaultDrainTimeout = 30 * time.Second
+
 type MogPoolType map[string]*Cli
 
 //MogClientImpl -> mongo client implement
-//Config: list of MogConfig
-//Pool: map of Cli(mongo client) example: {'dbname': mongo client of dbname}
+//Config: list of MogConfig, one per pool name
+//Pool: map of Cli(mongo client) example: {'chat_msg': client of chat.chat_msg, 'chat_user': client of chat.chat_user}, guarded by mu,
+//change it with AddClient2Pool / RemoveClient and range over Clients()
+//DrainTimeout: how long a replaced or removed client waits for in-flight operations before it is closed, 30s if 0
 type MogClientImpl struct {
-	Config  []MgoConfig
-	Pool    MogPoolType
-	Context context.Context
+	Config       []MgoConfig
+	Pool         MogPoolType
+	Context      context.Context
+	DrainTimeout time.Duration
+
+	mu       sync.RWMutex
+	conns    connPool
+	retiring sync.WaitGroup
 }
 
 //create new mongodb client
@@ -42,85 +57,224 @@ func (m *MogClientImpl) initPool() {
 	m.Pool = make(MogPoolType, 0)
 }
 
-//add new mongo client to connection pool
+//add new mongo client to connection pool under mongoConfig.PoolName(), the connection of its uri is reused if open
+//a client already registered for the name is replaced and closed once its in-flight operations drained
 func (m *MogClientImpl) AddClient2Pool(mongoConfig MgoConfig) error {
 
+	client, key, err := m.conns.connect(m.Context, mongoConfig)
+	if nil != err {
+		return err
+	}
+
+	newCli := newFixedCli(client, mongoConfig)
+	newCli.connKey = key
+
+	name := mongoConfig.PoolName()
+
+	m.mu.Lock()
+	old, replaced := m.Pool[name]
+	m.Pool[name] = newCli
+	m.setConfig(mongoConfig)
+	m.mu.Unlock()
+
+	if replaced {
+		m.retiring.Add(1)
+		go func() {
+			defer m.retiring.Done()
+
+			err := m.drainAndClose(old)
+			if nil != err {
+				logrus.Error("mongo close replaced client Error! name:", name, "Details:", err.Error())
+			}
+		}()
+	}
+
+	return nil
+}
+
+//close the client of name after its in-flight operations drained and forget it
+func (m *MogClientImpl) RemoveClient(name string) error {
+
+	m.mu.Lock()
+	cli, ok := m.Pool[name]
+	if !ok {
+		m.mu.Unlock()
+		return errors.New("no connection " + name + " in Manager")
+	}
+
+	delete(m.Pool, name)
+	for i, config := range m.Config {
+		if config.PoolName() == name {
+			m.Config = append(m.Config[:i], m.Config[i+1:]...)
+			break
+		}
+	}
+	m.mu.Unlock()
+
+	return m.drainAndClose(cli)
+}
+
+//replace the config of the same pool name or append it, caller holds mu
+func (m *MogClientImpl) setConfig(mongoConfig MgoConfig) {
+
+	for i, config := range m.Config {
+		if config.PoolName() == mongoConfig.PoolName() {
+			m.Config[i] = mongoConfig
+			return
+		}
+	}
+
 	m.Config = append(m.Config, mongoConfig)
+}
 
-	for _, config := range m.Config {
+//wait for in-flight operations of cli up to DrainTimeout, then close it
+//a shared connection is only closed when no other Cli uses it
+func (m *MogClientImpl) drainAndClose(cli *Cli) error {
+
+	timeout := m.DrainTimeout
+	if timeout <= 0 {
+		timeout = defaultDrainTimeout
+	}
 
-		newCli, err := m.CreateFixedMongoCli(config)
+	if nil != cli.refs && !cli.refs.retire(timeout) {
+		logrus.Warn("mongo client closed with operations in flight, db: ", cli.Config.Database, " coll: ", cli.Config.Coll)
+	}
 
+	return m.closeCli(cli)
+}
+
+//close the connection of cli, or drop cli from its shared connection
+func (m *MogClientImpl) closeCli(cli *Cli) error {
+
+	if "" == cli.connKey {
+		return cli.Client.Close(m.Context)
+	}
+
+	return m.conns.disconnect(m.Context, cli.connKey)
+}
+
+//get the client of name and hold it until release is called, so replacing it waits for the operation
+func (m *MogClientImpl) acquire(name string) (*Cli, func(), error) {
+
+	for {
+		cli, err := m.GetClient(name)
 		if nil != err {
-			return err
+			return nil, nil, err
+		}
+
+		if nil == cli.refs {
+			return cli, func() {}, nil
+		}
+		//lost the race with a replace, the pool holds the new client now
+		if cli.refs.acquire() {
+			return cli, cli.refs.release, nil
 		}
-		m.Pool[config.DbName] = newCli
 	}
+}
 
-	return nil
+//hold the client of name for work MogDal does not cover, like iterating a cursor,
+//call release when done, replacing or removing the client waits for it
+func (m *MogClientImpl) Acquire(name string) (cli *Cli, release func(), err error) {
+
+	return m.acquire(name)
 }
 
-//get mongo client by dbname
-func (m *MogClientImpl) GetClient(dbName string) (*Cli, error) {
+//copy of the pool to range over
+func (m *MogClientImpl) Clients() MogPoolType {
 
-	cli, ok := m.Pool[dbName]
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	clients := make(MogPoolType, len(m.Pool))
+	for name, cli := range m.Pool {
+		clients[name] = cli
+	}
+
+	return clients
+}
+
+//get mongo client by pool name
+func (m *MogClientImpl) GetClient(name string) (*Cli, error) {
+
+	m.mu.RLock()
+	cli, ok := m.Pool[name]
+	m.mu.RUnlock()
 
 	if !ok {
-		return nil, errors.New("no connection " + dbName + " in Manager")
+		return nil, errors.New("no connection " + name + " in Manager")
 	}
 	return cli, nil
 }
 
-// return context for mongodb
+//any collection of dbName on the connection of a pool client of that database
+//the collection is bound to that connection, get it again after the client was replaced or removed
+func (m *MogClientImpl) Collection(dbName string, collName string) (*qmgo.Collection, error) {
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, config := range m.Config {
+		if config.DbName != dbName {
+			continue
+		}
+		cli, ok := m.Pool[config.PoolName()]
+		if ok {
+			return cli.Client.Database(dbName).Collection(collName), nil
+		}
+	}
+
+	return nil, errors.New("no connection " + dbName + " in Manager")
+}
+
+//return context for mongodb
 func (m *MogClientImpl) GetCtx() context.Context {
 
 	return m.Context
 }
 
 //use this function to get a connection points to a fixed database and collection
+//the connection is the caller's own, it is not shared with the pool
 func (m *MogClientImpl) CreateFixedMongoCli(config MgoConfig) (*Cli, error) {
 
-	connConfig := &qmgo.Config{
-		Uri:      config.Uri,
-		Database: config.DbName,
-		Coll:     config.Coll,
-		Auth: &qmgo.Credential{
-			Username:   config.Username,
-			Password:   config.Password,
-			AuthSource: config.AuthDb,
-		},
-	}
-
-	cli, err := qmgo.Open(m.Context, connConfig)
-
+	client, err := qmgo.NewClient(m.Context, connectConfig(config))
 	if nil != err {
 		return nil, err
 	}
 
-	//create new mongo client
-	newCli := &Cli{
-		Client:     cli.Client,
-		Database:   cli.Database,
-		Coll:       cli.Collection,
+	return newFixedCli(client, config), nil
+}
+
+//Cli of config's database and collection on client
+func newFixedCli(client *qmgo.Client, config MgoConfig) *Cli {
+
+	database := client.Database(config.DbName)
+
+	return &Cli{
+		Client:     client,
+		Database:   database,
+		Coll:       database.Collection(config.Coll),
 		RetryTimes: config.RetryTimes,
 		Timeout:    config.Timeout,
-		Config:     connConfig,
+		Config:     connectConfig(config),
+		refs:       newRefCount(),
 	}
-
-	return newCli, nil
 }
 
-//close all mongo client
+//close all mongo client, every client is closed even if some fail and all errors are returned
+//waits for replaced clients still draining
 func (m *MogClientImpl) Close() error {
 
-	for _, cli := range m.Pool {
-		err := cli.Client.Close(m.Context)
+	m.retiring.Wait()
+
+	var errs multierr.Errors
+	for name, cli := range m.Clients() {
+		err := m.closeCli(cli)
 		if nil != err {
-			return err
+			errs.Add(fmt.Errorf("mongo %s: %w", name, err))
 		}
 	}
 
-	return nil
+	return errs.Err()
 }
 
 /*================
@@ -128,18 +282,19 @@ Mongo dal operator
 ==================*/
 
 //insert one document
-func (m *MogClientImpl) InsertDoc(dbName string, data interface{}) (*qmgo.InsertOneResult, error) {
+func (d ctxDal) InsertDoc(dbName string, data interface{}) (*qmgo.InsertOneResult, error) {
 
 	if "" == dbName {
 		dbName = staict_const.Chat
 	}
 
-	cli, err := m.GetClient(dbName)
+	cli, release, err := d.m.acquire(dbName)
 	if nil != err {
 		return nil, err
 	}
+	defer release()
 
-	result, err := cli.Coll.InsertOne(m.GetCtx(), &data)
+	result, err := cli.Coll.InsertOne(d.ctx, data)
 	if nil != err {
 		return nil, err
 	}
@@ -147,20 +302,25 @@ func (m *MogClientImpl) InsertDoc(dbName string, data interface{}) (*qmgo.Insert
 	return result, nil
 }
 
-//get one document
-func (m *MogClientImpl) GetDoc(dbName string, condition bsonM, res chatMsgType) error {
+//get one document decoded into res, res must be a pointer
+//example: msg := new(staict_const.ChatMsg); err := m.GetDoc("", bson.M{"msg_id": id}, msg)
+func (d ctxDal) GetDoc(dbName string, condition bsonM, res interface{}) error {
 
 	if "" == dbName {
 		dbName = staict_const.Chat
 	}
 
-	cli, err := m.GetClient(dbName)
+	if !isPointer(res) {
+		return ErrNotPointer
+	}
+
+	cli, release, err := d.m.acquire(dbName)
 	if nil != err {
 		return err
 	}
+	defer release()
 
-	err = cli.Coll.Find(m.GetCtx(), condition).One(&res)
-
+	err = cli.Coll.Find(d.ctx, condition).One(res)
 	if nil != err {
 		return err
 	}
@@ -168,20 +328,110 @@ func (m *MogClientImpl) GetDoc(dbName string, condition bsonM, res chatMsgType)
 	return nil
 }
 
+//get every document matching condition decoded into res, res must be a pointer to a slice
+func (d ctxDal) FindMany(dbName string, condition bsonM, opts FindOptions, res interface{}) error {
+
+	if "" == dbName {
+		dbName = staict_const.Chat
+	}
+
+	if !isPointer(res) {
+		return ErrNotPointer
+	}
+
+	cli, release, err := d.m.acquire(dbName)
+	if nil != err {
+		return err
+	}
+	defer release()
+
+	if nil == condition {
+		condition = bsonM{}
+	}
+
+	query := cli.Coll.Find(d.ctx, condition)
+	if len(opts.Sort) > 0 {
+		query = query.Sort(opts.Sort...)
+	}
+	if opts.Skip > 0 {
+		query = query.Skip(opts.Skip)
+	}
+	if opts.Limit > 0 {
+		query = query.Limit(opts.Limit)
+	}
+	if len(opts.Projection) > 0 {
+		query = query.Select(opts.Projection)
+	}
+
+	return query.All(res)
+}
+
+//number of documents matching condition
+func (d ctxDal) Count(dbName string, condition bsonM) (int64, error) {
+
+	if "" == dbName {
+		dbName = staict_const.Chat
+	}
+
+	cli, release, err := d.m.acquire(dbName)
+	if nil != err {
+		return 0, err
+	}
+	defer release()
+
+	if nil == condition {
+		condition = bsonM{}
+	}
+
+	return cli.Coll.Find(d.ctx, condition).Count()
+}
+
+//distinct values of field among documents matching condition decoded into res, res must be a pointer to a slice
+func (d ctxDal) Distinct(dbName string, field string, condition bsonM, res interface{}) error {
+
+	if "" == dbName {
+		dbName = staict_const.Chat
+	}
+
+	if !isPointer(res) {
+		return ErrNotPointer
+	}
+
+	cli, release, err := d.m.acquire(dbName)
+	if nil != err {
+		return err
+	}
+	defer release()
+
+	if nil == condition {
+		condition = bsonM{}
+	}
+
+	return cli.Coll.Find(d.ctx, condition).Distinct(field, res)
+}
+
+func isPointer(res interface{}) bool {
+
+	v := reflect.ValueOf(res)
+
+	return v.Kind() == reflect.Ptr && !v.IsNil()
+}
+
 //update one document
-func (m *MogClientImpl) UpdateDoc(dbName string, condition bsonM, operator bsonM) error {
+func (d ctxDal) UpdateDoc(dbName string, condition bsonM, operator bsonM) error {
 
 	if "" == dbName {
 		dbName = staict_const.Chat
 	}
 
-	cli, err := m.GetClient(dbName)
+	cli, release, err := d.m.acquire(dbName)
 	if nil != err {
 		return err
 	}
+	defer release()
 
 	//example: err = cli.Coll.UpdateOne(dal.Client.GetCtx(), bson.M{"name": "d4"}, bson.M{"$set": bson.M{"age": 7}})
-	err = cli.Coll.UpdateOne(m.GetCtx(), condition, operator)
+	err = cli.Coll.UpdateOne(d.ctx, condition, operator)
 
 	if nil != err {
 		return err
@@ -191,18 +441,19 @@ func (m *MogClientImpl) UpdateDoc(dbName string, condition bsonM, operator bsonM
 }
 
 //remove one doc
-func (m *MogClientImpl) RemoveDoc(dbName string, condition bsonM) error {
+func (d ctxDal) RemoveDoc(dbName string, condition bsonM) error {
 
 	if "" == dbName {
 		dbName = staict_const.Chat
 	}
 
-	cli, err := m.GetClient(dbName)
+	cli, release, err := d.m.acquire(dbName)
 	if nil != err {
 		return err
 	}
+	defer release()
 
-	err = cli.Coll.Remove(m.GetCtx(), condition)
+	err = cli.Coll.Remove(d.ctx, condition)
 	if nil != err {
 		return err
 	}
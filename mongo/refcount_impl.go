@@ -0,0 +1,73 @@
+/**
+ * @Author KYIMH
+ * @Description
+ * @Date 2026/10/19 23:40
+ **/
+
+package mongo
+
+import (
+	"sync"
+	"time"
+)
+
+//refCount -> in-flight operations of one Cli, a retired Cli accepts no new ones
+type refCount struct {
+	mu      sync.Mutex
+	n       int
+	retired bool
+	idle    chan struct{}
+}
+
+func newRefCount() *refCount {
+
+	return &refCount{idle: make(chan struct{})}
+}
+
+//count one more operation, false once the client is retired
+func (r *refCount) acquire() bool {
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.retired {
+		return false
+	}
+	r.n++
+
+	return true
+}
+
+func (r *refCount) release() {
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.n--
+	if r.retired && 0 == r.n {
+		close(r.idle)
+	}
+}
+
+//stop accepting operations and wait up to timeout for running ones, false on timeout
+func (r *refCount) retire(timeout time.Duration) bool {
+
+	r.mu.Lock()
+	if !r.retired {
+		r.retired = true
+		if 0 == r.n {
+			close(r.idle)
+		}
+	}
+	r.mu.Unlock()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-r.idle:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
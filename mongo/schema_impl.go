@@ -0,0 +1,247 @@
+/**
+ * @Author KYIMH
+ * @Description
+ * @Date 2026/10/20 00:50
+ **/
+
+package mongo
+
+import (
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsoncodec"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	schemasMu sync.RWMutex
+	schemas   = make(map[string]*Schema)
+
+	timeType           = reflect.TypeOf(time.Time{})
+	marshalerType      = reflect.TypeOf((*bson.Marshaler)(nil)).Elem()
+	valueMarshalerType = reflect.TypeOf((*bsoncodec.ValueMarshaler)(nil)).Elem()
+
+	//untyped documents and arrays, their fields are only known at runtime
+	openTypes = map[reflect.Type]bool{
+		reflect.TypeOf(bson.D{}):   true,
+		reflect.TypeOf(bson.M{}):   true,
+		reflect.TypeOf(bson.A{}):   true,
+		reflect.TypeOf(bson.Raw{}): true,
+	}
+)
+
+//Schema -> field paths of a document struct read from its bson tags, used to validate Query and Update fields
+type Schema struct {
+	name string
+	root *schemaNode
+}
+
+//schemaNode -> one document level
+//open: any sub path is allowed (map, interface{}, bson.D / M / A / Raw, custom marshaler)
+//array: numeric indexes and positional operators ($, $[], $[id]) may follow
+type schemaNode struct {
+	children map[string]*schemaNode
+	open     bool
+	array    bool
+}
+
+//read the bson field paths of model, a struct or a pointer to one
+//example: NewSchema(staict_const.ChatMsg{}).Has("to_id") == true
+func NewSchema(model interface{}) *Schema {
+
+	t := reflect.TypeOf(model)
+	for nil != t && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	s := &Schema{root: &schemaNode{open: true}}
+	if nil == t {
+		return s
+	}
+
+	s.name = t.String()
+	s.root = newSchemaNode(t, map[reflect.Type]*schemaNode{})
+	if nil == s.root.children {
+		s.root.children = make(map[string]*schemaNode)
+	}
+	//every document has an _id, even when the struct does not map it
+	if _, ok := s.root.children["_id"]; !ok {
+		s.root.children["_id"] = &schemaNode{open: true}
+	}
+
+	return s
+}
+
+//register the schema of model under name, usually the pool name of the collection
+func RegisterSchema(name string, model interface{}) *Schema {
+
+	s := NewSchema(model)
+
+	schemasMu.Lock()
+	schemas[name] = s
+	schemasMu.Unlock()
+
+	return s
+}
+
+//schema registered under name, nil if none, a nil schema validates nothing
+func SchemaOf(name string) *Schema {
+
+	schemasMu.RLock()
+	defer schemasMu.RUnlock()
+
+	return schemas[name]
+}
+
+func newSchemaNode(t reflect.Type, visiting map[reflect.Type]*schemaNode) *schemaNode {
+
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if openTypes[t] {
+		return &schemaNode{open: true}
+	}
+
+	switch t.Kind() {
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &schemaNode{}
+		}
+		elem := newSchemaNode(t.Elem(), visiting)
+		return &schemaNode{children: elem.children, open: elem.open, array: true}
+	case reflect.Map, reflect.Interface:
+		return &schemaNode{open: true}
+	case reflect.Struct:
+	default:
+		return &schemaNode{}
+	}
+
+	if t == timeType || t.PkgPath() == "go.mongodb.org/mongo-driver/bson/primitive" {
+		return &schemaNode{}
+	}
+	if t.Implements(marshalerType) || t.Implements(valueMarshalerType) ||
+		reflect.PtrTo(t).Implements(marshalerType) || reflect.PtrTo(t).Implements(valueMarshalerType) {
+		return &schemaNode{open: true}
+	}
+	//recursive type, point back at the level being described
+	if node, ok := visiting[t]; ok {
+		return node
+	}
+
+	node := &schemaNode{children: make(map[string]*schemaNode)}
+	visiting[t] = node
+	defer delete(visiting, t)
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if "" != field.PkgPath {
+			continue
+		}
+
+		name, inline, skip := bsonTag(field)
+		if skip {
+			continue
+		}
+
+		child := newSchemaNode(field.Type, visiting)
+		if inline {
+			if child.open {
+				node.open = true
+			}
+			for k, v := range child.children {
+				node.children[k] = v
+			}
+			continue
+		}
+		node.children[name] = child
+	}
+
+	return node
+}
+
+//key, inline flag and skip flag of a struct field, same rules as the bson struct codec
+func bsonTag(field reflect.StructField) (string, bool, bool) {
+
+	tag, ok := field.Tag.Lookup("bson")
+	if !ok && !strings.Contains(string(field.Tag), ":") && len(field.Tag) > 0 {
+		tag = string(field.Tag)
+	}
+	if "-" == tag {
+		return "", false, true
+	}
+
+	parts := strings.Split(tag, ",")
+	name := parts[0]
+	inline := false
+	for _, opt := range parts[1:] {
+		if "inline" == opt {
+			inline = true
+		}
+	}
+	if "" == name {
+		name = strings.ToLower(field.Name)
+	}
+
+	return name, inline, false
+}
+
+//whether path is a field of the schema, dotted paths, array indexes and positional operators are understood
+//a nil schema has every field
+func (s *Schema) Has(path string) bool {
+
+	if nil == s {
+		return true
+	}
+
+	node := s.root
+	for _, part := range strings.Split(path, ".") {
+		if node.open {
+			return true
+		}
+		if node.array && isArrayPart(part) {
+			continue
+		}
+		child, ok := node.children[part]
+		if !ok {
+			return false
+		}
+		node = child
+	}
+
+	return true
+}
+
+//name of the struct the schema was read from
+func (s *Schema) Name() string {
+
+	if nil == s {
+		return ""
+	}
+
+	return s.name
+}
+
+//query validated against the schema
+func (s *Schema) Query() *Query {
+
+	return &Query{schema: s}
+}
+
+//update validated against the schema
+func (s *Schema) Update() *Update {
+
+	return &Update{schema: s}
+}
+
+func isArrayPart(part string) bool {
+
+	if strings.HasPrefix(part, "$") {
+		return true
+	}
+	_, err := strconv.Atoi(part)
+
+	return nil == err
+}
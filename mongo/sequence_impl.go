@@ -0,0 +1,138 @@
+/**
+ * @Author KYIMH
+ * @Description
+ * @Date 2026/10/20 01:30
+ **/
+
+package mongo
+
+import (
+	"errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"math"
+	"sync"
+)
+
+var _ Sequencer = (*SequenceImpl)(nil)
+
+//counterDoc -> one document of the counters collection, seq is the last value handed out
+type counterDoc struct {
+	Id  string `bson:"_id"`
+	Seq int64  `bson:"seq"`
+}
+
+//seqBlock -> values reserved from the counters collection not handed out yet, next > last when used up
+type seqBlock struct {
+	next uint64
+	last uint64
+}
+
+//SequenceImpl -> Sequencer backed by a counters collection, one document {_id: name, seq: last value} per name
+//Mongo: dal of the counters collection
+//DbName: pool name of the counters collection
+//Block: values reserved per round trip, 1 if 0, values of a block not handed out are lost when the process stops
+//
+//	with Block > 1 values of different processes interleave, they are only increasing within a process
+type SequenceImpl struct {
+	Mongo  MogDal
+	DbName string
+	Block  uint64
+
+	mu     sync.Mutex
+	blocks map[string]*seqBlock
+}
+
+//create sequence on the counters collection registered as dbName
+//example: seq := NewSequence(mongoCli, "ccs_counters", 100); chatId, err := seq.NextUint32("chat_id")
+func NewSequence(dal MogDal, dbName string, block uint64) *SequenceImpl {
+
+	if 0 == block {
+		block = 1
+	}
+
+	return &SequenceImpl{
+		Mongo:  dal,
+		DbName: dbName,
+		Block:  block,
+		blocks: make(map[string]*seqBlock),
+	}
+}
+
+//next value of name, the first value of a new name is 1
+func (s *SequenceImpl) Next(name string) (uint64, error) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, ok := s.blocks[name]
+	if !ok || b.next > b.last {
+		first, err := s.allocate(name, s.Block)
+		if nil != err {
+			return 0, err
+		}
+		b = &seqBlock{next: first, last: first + s.Block - 1}
+		s.blocks[name] = b
+	}
+
+	v := b.next
+	b.next++
+
+	return v, nil
+}
+
+//next value of name as uint32, example: ChatMsg.ChatId
+func (s *SequenceImpl) NextUint32(name string) (uint32, error) {
+
+	v, err := s.Next(name)
+	if nil != err {
+		return 0, err
+	}
+	if v > math.MaxUint32 {
+		return 0, ErrSequenceOverflow
+	}
+
+	return uint32(v), nil
+}
+
+//reserve n consecutive values of name in one round trip, returns the first one
+//the values come straight from the counters collection, not from the local block of Next
+func (s *SequenceImpl) Reserve(name string, n uint64) (uint64, error) {
+
+	if 0 == n {
+		return 0, errors.New("mongo: reserve 0 values of sequence " + name)
+	}
+
+	return s.allocate(name, n)
+}
+
+//raise the counter of name to at least value, so values already used elsewhere are not handed out again
+func (s *SequenceImpl) Seed(name string, value uint64) error {
+
+	if value > math.MaxInt64 {
+		return ErrSequenceOverflow
+	}
+
+	_, err := s.Mongo.Upsert(s.DbName, bson.M{"_id": name}, bson.M{"$max": bson.M{"seq": int64(value)}})
+
+	return err
+}
+
+//add n to the counter of name, returns the first of the n values
+func (s *SequenceImpl) allocate(name string, n uint64) (uint64, error) {
+
+	if n > math.MaxInt64 {
+		return 0, ErrSequenceOverflow
+	}
+
+	var doc counterDoc
+	err := s.Mongo.FindOneAndUpdate(s.DbName, bson.M{"_id": name}, bson.M{"$inc": bson.M{"seq": int64(n)}},
+		FindAndModifyOptions{Return: ReturnAfter, Upsert: true}, &doc)
+	if nil != err {
+		return 0, err
+	}
+	if doc.Seq < int64(n) {
+		return 0, ErrSequenceOverflow
+	}
+
+	return uint64(doc.Seq) - n + 1, nil
+}
@@ -0,0 +1,56 @@
+/**
+ * @Author KYIMH
+ * @Description
+ * @Date 2026/10/20 01:50
+ **/
+
+package mongo
+
+import (
+	"context"
+	"github.com/KYIMH/CCS_Utils/share/enum/staict_const"
+)
+
+//run fn in a transaction on the connection of the client of dbName, commit if fn returns nil, abort otherwise
+//every operation of the transaction must run on sessCtx, example:
+//
+//	err := m.WithTransaction(ctx, "chat_msg", func(sessCtx context.Context) error {
+//		tx := m.WithContext(sessCtx)
+//		_, err := tx.InsertDoc("chat_msg", msg)
+//		if nil != err {
+//			return err
+//		}
+//		return tx.UpdateDoc("chat_conversation", bson.M{"_id": convId}, bson.M{"$set": bson.M{"last_msg": msg.ChatId}})
+//	})
+//
+//pool names sharing the connection of dbName (same uri and credentials) can be used inside the transaction
+//fn is run again on TransientTransactionError and the commit is retried on UnknownTransactionCommitResult,
+//for up to 120s, so fn must not have side effects outside the transaction, fn can return ErrTransactionRetry to run again
+//needs a replica set or sharded cluster of mongodb 4.0+
+func (m *MogClientImpl) WithTransaction(ctx context.Context, dbName string, fn func(sessCtx context.Context) error) error {
+
+	if "" == dbName {
+		dbName = staict_const.Chat
+	}
+	if nil == ctx {
+		ctx = m.GetCtx()
+	}
+
+	cli, release, err := m.acquire(dbName)
+	if nil != err {
+		return err
+	}
+	defer release()
+
+	sess, err := cli.Client.Session()
+	if nil != err {
+		return err
+	}
+	defer sess.EndSession(ctx)
+
+	_, err = sess.StartTransaction(ctx, func(sessCtx context.Context) (interface{}, error) {
+		return nil, fn(sessCtx)
+	})
+
+	return err
+}
@@ -0,0 +1,42 @@
+/**
+ * @Author KYIMH
+ * @Description
+ * @Date 2026/10/20 02:10
+ **/
+
+package mongo
+
+import (
+	"context"
+	"github.com/KYIMH/CCS_Utils/share/enum/staict_const"
+	driver "go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+//open a change stream on the collection of dbName, pipeline filters the changes, nil for every change
+//the stream is not tied to the pool entry, it fails once the client is replaced or removed, open it again then
+//needs a replica set or sharded cluster, the changestream package adds typed events and resume tokens on top
+func (m *MogClientImpl) Watch(ctx context.Context, dbName string, pipeline interface{}, opts *options.ChangeStreamOptions) (*driver.ChangeStream, error) {
+
+	if "" == dbName {
+		dbName = staict_const.Chat
+	}
+	if nil == ctx {
+		ctx = m.GetCtx()
+	}
+	if nil == pipeline {
+		pipeline = driver.Pipeline{}
+	}
+
+	cli, err := m.GetClient(dbName)
+	if nil != err {
+		return nil, err
+	}
+
+	coll, err := cli.Coll.CloneCollection()
+	if nil != err {
+		return nil, err
+	}
+
+	return coll.Watch(ctx, pipeline, opts)
+}
@@ -0,0 +1,55 @@
+/**
+ * @Author KYIMH
+ * @Description
+ * @Date 2026/10/19 19:40
+ **/
+
+package presence
+
+import (
+	"time"
+)
+
+//Presence -> online state of one user
+//ServerId: chat server the user is connected to
+//LastSeen: time of the last heartbeat
+type Presence struct {
+	UserId   string    `json:"user_id"`
+	ServerId string    `json:"server_id"`
+	LastSeen time.Time `json:"last_seen"`
+}
+
+//Event -> online/offline transition published on Config.Channel
+type Event struct {
+	UserId   string    `json:"user_id"`
+	ServerId string    `json:"server_id"`
+	Online   bool      `json:"online"`
+	At       time.Time `json:"at"`
+}
+
+//Config -> presence tracker config
+//RedisTag: redis tag of the pool holding the presence keys
+//Prefix: key prefix, example: ccs:presence:
+//Timeout: a user without heartbeat for Timeout is offline
+//SweepInterval: how often the sweeper evicts stale users, Timeout/2 if 0
+//Channel: pub/sub channel of transitions, no events are published if empty
+type Config struct {
+	RedisTag      string
+	Prefix        string
+	Timeout       time.Duration
+	SweepInterval time.Duration
+	Channel       string
+}
+
+//presence tracker operators
+type Tracker interface {
+	Heartbeat(userId string, serverId string) error
+	Offline(userId string) error
+	IsOnline(userId string) (bool, error)
+	OnlineCount() (int64, error)
+	ListOnline(offset int64, count int64) ([]Presence, error)
+	Sweep() (int, error)
+	Start()
+	Stop()
+	Subscribe(handler func(Event)) (stop func(), err error)
+}
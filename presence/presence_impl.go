@@ -0,0 +1,356 @@
+/**
+ * @Author KYIMH
+ * @Description
+ * @Date 2026/10/19 19:40
+ **/
+
+package presence
+
+import (
+	"encoding/json"
+	"github.com/KYIMH/CCS_Utils/redis"
+	goredis "github.com/go-redis/redis"
+	"github.com/sirupsen/logrus"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	defaultPrefix  = "ccs:presence:"
+	defaultTimeout = 60 * time.Second
+)
+
+//record the heartbeat and the server, returns 1 if the user was not tracked, the caller publishes online
+//KEYS[1]: seen, KEYS[2]: server, ARGV[1]: user, ARGV[2]: server id, ARGV[3]: now unix ms
+var heartbeatScript = goredis.NewScript(`
+local added = redis.call('ZADD', KEYS[1], ARGV[3], ARGV[1])
+redis.call('HSET', KEYS[2], ARGV[1], ARGV[2])
+return added
+`)
+
+//drop the user if tracked and, when a cutoff is given, its heartbeat is older, returns its server or nil if kept
+//KEYS[1]: seen, KEYS[2]: server, ARGV[1]: user, ARGV[2]: cutoff unix ms or empty
+var evictScript = goredis.NewScript(`
+local score = redis.call('ZSCORE', KEYS[1], ARGV[1])
+if not score or (ARGV[2] ~= '' and tonumber(score) >= tonumber(ARGV[2])) then
+	return false
+end
+local server = redis.call('HGET', KEYS[2], ARGV[1]) or ''
+redis.call('ZREM', KEYS[1], ARGV[1])
+redis.call('HDEL', KEYS[2], ARGV[1])
+return server
+`)
+
+//TrackerImpl -> presence tracker on the redis pool
+//last heartbeats live in a sorted set of user ids scored by unix ms,
+//the server of every user lives in a hash next to it
+type TrackerImpl struct {
+	Redis  redis.Dal
+	Config Config
+
+	mu   sync.Mutex
+	stop chan struct{}
+	done chan struct{}
+}
+
+//create new presence tracker, zero fields of config take the defaults
+func NewTracker(redisDal redis.Dal, config Config) *TrackerImpl {
+
+	if "" == config.Prefix {
+		config.Prefix = defaultPrefix
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = defaultTimeout
+	}
+	if config.SweepInterval <= 0 {
+		config.SweepInterval = config.Timeout / 2
+	}
+
+	return &TrackerImpl{
+		Redis:  redisDal,
+		Config: config,
+	}
+}
+
+func (t *TrackerImpl) seenKey() string {
+
+	return t.Config.Prefix + "seen"
+}
+
+func (t *TrackerImpl) serverKey() string {
+
+	return t.Config.Prefix + "server"
+}
+
+func toMillis(at time.Time) int64 {
+
+	return at.UnixNano() / int64(time.Millisecond)
+}
+
+func fromMillis(ms int64) time.Time {
+
+	return time.Unix(0, ms*int64(time.Millisecond))
+}
+
+//heartbeats older than the cutoff are offline
+func (t *TrackerImpl) cutoff(now time.Time) int64 {
+
+	return toMillis(now.Add(-t.Config.Timeout))
+}
+
+//record a heartbeat of userId connected to serverId, publishes online if the user was not tracked
+//a user whose heartbeats lapsed but who is not swept yet stays tracked, so no event is published for it
+func (t *TrackerImpl) Heartbeat(userId string, serverId string) error {
+
+	cli, err := t.Redis.GetClient(t.Config.RedisTag)
+	if nil != err {
+		return err
+	}
+
+	now := time.Now()
+	added, err := heartbeatScript.Run(cli, []string{t.seenKey(), t.serverKey()}, userId, serverId, toMillis(now)).Int64()
+	if nil != err {
+		logrus.Error("presence Heartbeat Error! user:", userId, "Details:", err.Error())
+		return err
+	}
+
+	if 1 == added {
+		t.publish(Event{UserId: userId, ServerId: serverId, Online: true, At: now})
+	}
+
+	return nil
+}
+
+//mark userId offline at once, example: on logout
+func (t *TrackerImpl) Offline(userId string) error {
+
+	_, err := t.evict(userId, "", time.Now())
+
+	return err
+}
+
+//drop userId from the presence keys and publish offline, only if its heartbeat is older than cutoff when set
+//the check and the removal are one script, so of concurrent callers only one publishes
+func (t *TrackerImpl) evict(userId string, cutoff string, now time.Time) (bool, error) {
+
+	cli, err := t.Redis.GetClient(t.Config.RedisTag)
+	if nil != err {
+		return false, err
+	}
+
+	serverId, err := evictScript.Run(cli, []string{t.seenKey(), t.serverKey()}, userId, cutoff).String()
+	if err == goredis.Nil {
+		return false, nil
+	}
+	if nil != err {
+		logrus.Error("presence evict Error! user:", userId, "Details:", err.Error())
+		return false, err
+	}
+
+	t.publish(Event{UserId: userId, ServerId: serverId, Online: false, At: now})
+
+	return true, nil
+}
+
+//true if userId sent a heartbeat within Timeout
+func (t *TrackerImpl) IsOnline(userId string) (bool, error) {
+
+	zs, err := redis.AsSortedSets(t.Redis)
+	if nil != err {
+		return false, err
+	}
+
+	score, exists, err := zs.RedisZScore(t.Config.RedisTag, t.seenKey(), userId)
+	if nil != err {
+		return false, err
+	}
+
+	return exists && int64(score) >= t.cutoff(time.Now()), nil
+}
+
+//number of online users, stale entries not yet swept are not counted
+func (t *TrackerImpl) OnlineCount() (int64, error) {
+
+	zs, err := redis.AsSortedSets(t.Redis)
+	if nil != err {
+		return 0, err
+	}
+
+	min := strconv.FormatInt(t.cutoff(time.Now()), 10)
+
+	return zs.RedisZCount(t.Config.RedisTag, t.seenKey(), min, "+inf")
+}
+
+//page of online users ordered by last heartbeat, oldest first, count <= 0 lists all from offset
+func (t *TrackerImpl) ListOnline(offset int64, count int64) ([]Presence, error) {
+
+	zs, err := redis.AsSortedSets(t.Redis)
+	if nil != err {
+		return nil, err
+	}
+
+	min := strconv.FormatInt(t.cutoff(time.Now()), 10)
+
+	values, err := zs.RedisZRangeByScoreWithScores(t.Config.RedisTag, t.seenKey(), min, "+inf", offset, count)
+	if nil != err {
+		return nil, err
+	}
+
+	list := make([]Presence, 0, len(values))
+	for _, z := range values {
+		userId, _ := z.Member.(string)
+		serverId, err := t.Redis.RedisHGet(t.Config.RedisTag, t.serverKey(), userId)
+		if nil != err {
+			return nil, err
+		}
+		list = append(list, Presence{
+			UserId:   userId,
+			ServerId: serverId,
+			LastSeen: fromMillis(int64(z.Score)),
+		})
+	}
+
+	return list, nil
+}
+
+//evict users without heartbeat within Timeout, returns the number of evicted users
+func (t *TrackerImpl) Sweep() (int, error) {
+
+	now := time.Now()
+	cutoff := strconv.FormatInt(t.cutoff(now), 10)
+
+	zs, err := redis.AsSortedSets(t.Redis)
+	if nil != err {
+		return 0, err
+	}
+
+	stale, err := zs.RedisZRangeByScoreWithScores(t.Config.RedisTag, t.seenKey(), "-inf", "("+cutoff, 0, 0)
+	if nil != err {
+		return 0, err
+	}
+
+	evicted := 0
+	for _, z := range stale {
+		userId, _ := z.Member.(string)
+
+		//users whose heartbeat raced the range above are kept by the script
+		ok, err := t.evict(userId, cutoff, now)
+		if nil != err {
+			return evicted, err
+		}
+		if ok {
+			evicted++
+		}
+	}
+
+	return evicted, nil
+}
+
+//start the background sweeper, calling Start twice is a no-op
+func (t *TrackerImpl) Start() {
+
+	t.mu.Lock()
+	if nil != t.stop {
+		t.mu.Unlock()
+		return
+	}
+	t.stop = make(chan struct{})
+	t.done = make(chan struct{})
+	stop, done := t.stop, t.done
+	t.mu.Unlock()
+
+	go func() {
+		defer close(done)
+
+		ticker := time.NewTicker(t.Config.SweepInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if _, err := t.Sweep(); nil != err {
+					logrus.Error("presence sweep Error! Details:", err.Error())
+				}
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+//stop the background sweeper
+func (t *TrackerImpl) Stop() {
+
+	t.mu.Lock()
+	stop, done := t.stop, t.done
+	t.stop, t.done = nil, nil
+	t.mu.Unlock()
+
+	if nil == stop {
+		return
+	}
+	close(stop)
+	<-done
+}
+
+//publish a transition on Config.Channel, failures are logged, presence keys stay the source of truth
+func (t *TrackerImpl) publish(event Event) {
+
+	if "" == t.Config.Channel {
+		return
+	}
+
+	payload, err := json.Marshal(event)
+	if nil != err {
+		logrus.Error("presence publish Error! user:", event.UserId, "Details:", err.Error())
+		return
+	}
+
+	publisher, err := redis.AsPublisher(t.Redis)
+	if nil == err {
+		err = publisher.RedisPublish(t.Config.RedisTag, t.Config.Channel, string(payload))
+	}
+	if nil != err {
+		logrus.Error("presence publish Error! user:", event.UserId, "Details:", err.Error())
+	}
+}
+
+//receive transitions published on Config.Channel, call stop to unsubscribe
+func (t *TrackerImpl) Subscribe(handler func(Event)) (func(), error) {
+
+	cli, err := t.Redis.GetClient(t.Config.RedisTag)
+	if nil != err {
+		return nil, err
+	}
+
+	ps := cli.Subscribe(t.Config.Channel)
+	_, err = ps.Receive()
+	if nil != err {
+		ps.Close()
+		return nil, err
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		for msg := range ps.Channel() {
+			var event Event
+			err := json.Unmarshal([]byte(msg.Payload), &event)
+			if nil != err {
+				logrus.Error("presence event Error! payload:", msg.Payload, "Details:", err.Error())
+				continue
+			}
+			handler(event)
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			ps.Close()
+			<-done
+		})
+	}, nil
+}
@@ -0,0 +1,97 @@
+/**
+ * @Author KYIMH
+ * @Description
+ * @Date 2026/10/20 03:20
+ **/
+
+package presence_test
+
+import (
+	"github.com/KYIMH/CCS_Utils/presence"
+	"github.com/KYIMH/CCS_Utils/redis"
+	"github.com/KYIMH/CCS_Utils/redis/redistest"
+	goredis "github.com/go-redis/redis"
+	"testing"
+	"time"
+)
+
+const tag = "ccs"
+
+func newClient(t *testing.T) *redis.ClientImpl {
+
+	srv, cli, err := redistest.NewClientImpl(tag)
+	if nil != err {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		cli.Close()
+		srv.Close()
+	})
+
+	return cli
+}
+
+//racingDal -> runs onRange right after the stale range of Sweep, before it evicts
+type racingDal struct {
+	*redis.ClientImpl
+	onRange func()
+}
+
+func (d racingDal) RedisZRangeByScoreWithScores(redisTag string, key string, min string, max string, offset int64, count int64) ([]goredis.Z, error) {
+
+	values, err := d.ClientImpl.RedisZRangeByScoreWithScores(redisTag, key, min, max, offset, count)
+	d.onRange()
+
+	return values, err
+}
+
+func TestTracker(t *testing.T) {
+
+	tracker := presence.NewTracker(newClient(t), presence.Config{RedisTag: tag, Timeout: 50 * time.Millisecond})
+
+	for i := 0; i < 2; i++ {
+		if err := tracker.Heartbeat("u1", "s1"); nil != err {
+			t.Fatal(err)
+		}
+	}
+	if online, err := tracker.IsOnline("u1"); nil != err || !online {
+		t.Fatalf("IsOnline = %v, %v", online, err)
+	}
+	if n, err := tracker.Sweep(); nil != err || 0 != n {
+		t.Fatalf("Sweep of a fresh user = %d, %v", n, err)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if n, err := tracker.Sweep(); nil != err || 1 != n {
+		t.Fatalf("Sweep = %d, %v", n, err)
+	}
+	if n, err := tracker.Sweep(); nil != err || 0 != n {
+		t.Fatalf("Sweep again = %d, %v", n, err)
+	}
+}
+
+func TestTrackerSweepRacingHeartbeat(t *testing.T) {
+
+	cli := newClient(t)
+	config := presence.Config{RedisTag: tag, Timeout: 50 * time.Millisecond}
+	beater := presence.NewTracker(cli, config)
+
+	if err := beater.Heartbeat("u1", "s1"); nil != err {
+		t.Fatal(err)
+	}
+	time.Sleep(60 * time.Millisecond)
+
+	//u1 is in the stale range, its heartbeat lands before the sweeper evicts it
+	sweeper := presence.NewTracker(racingDal{ClientImpl: cli, onRange: func() {
+		if err := beater.Heartbeat("u1", "s2"); nil != err {
+			t.Error(err)
+		}
+	}}, config)
+
+	if n, err := sweeper.Sweep(); nil != err || 0 != n {
+		t.Fatalf("Sweep racing a heartbeat = %d, %v", n, err)
+	}
+	if online, err := beater.IsOnline("u1"); nil != err || !online {
+		t.Fatalf("IsOnline after the race = %v, %v", online, err)
+	}
+}
@@ -0,0 +1,105 @@
+/**
+ * @Author KYIMH
+ * @Description
+ * @Date 2026/10/20 04:10
+ **/
+
+package redis
+
+import (
+	"fmt"
+)
+
+var (
+	_ Dal           = ClientImpl{}
+	_ PrimaryReader = ClientImpl{}
+	_ ReplicaReader = ClientImpl{}
+	_ NXSetter      = ClientImpl{}
+	_ XXSetter      = ClientImpl{}
+	_ SortedSets    = ClientImpl{}
+	_ Publisher     = ClientImpl{}
+	_ Counters      = ClientImpl{}
+	_ Sharded       = (*ShardedImpl)(nil)
+	_ PrimaryReader = (*ShardedImpl)(nil)
+	_ ReplicaReader = (*ShardedImpl)(nil)
+	_ NXSetter      = (*ShardedImpl)(nil)
+	_ XXSetter      = (*ShardedImpl)(nil)
+	_ SortedSets    = (*ShardedImpl)(nil)
+	_ Publisher     = (*ShardedImpl)(nil)
+	_ Counters      = (*ShardedImpl)(nil)
+)
+
+func unsupported(name string) error {
+
+	return fmt.Errorf("%w: %s", ErrUnsupported, name)
+}
+
+//view of dal whose reads go to the primary, dal itself when it is no PrimaryReader
+func PrimaryOf(dal Dal) Dal {
+
+	if p, ok := dal.(PrimaryReader); ok {
+		return p.Primary()
+	}
+
+	return dal
+}
+
+//view of dal whose reads may go to replicas, dal itself when it is no ReplicaReader
+func ReplicaOf(dal Dal) Dal {
+
+	if r, ok := dal.(ReplicaReader); ok {
+		return r.Replica()
+	}
+
+	return dal
+}
+
+//dal as NXSetter, ErrUnsupported if it is none
+func AsNXSetter(dal Dal) (NXSetter, error) {
+
+	if nx, ok := dal.(NXSetter); ok {
+		return nx, nil
+	}
+
+	return nil, unsupported("NXSetter")
+}
+
+//dal as XXSetter, ErrUnsupported if it is none
+func AsXXSetter(dal Dal) (XXSetter, error) {
+
+	if xx, ok := dal.(XXSetter); ok {
+		return xx, nil
+	}
+
+	return nil, unsupported("XXSetter")
+}
+
+//dal as SortedSets, ErrUnsupported if it is none
+func AsSortedSets(dal Dal) (SortedSets, error) {
+
+	if zs, ok := dal.(SortedSets); ok {
+		return zs, nil
+	}
+
+	return nil, unsupported("SortedSets")
+}
+
+//dal as Publisher, ErrUnsupported if it is none
+func AsPublisher(dal Dal) (Publisher, error) {
+
+	if p, ok := dal.(Publisher); ok {
+		return p, nil
+	}
+
+	return nil, unsupported("Publisher")
+}
+
+//dal as Counters, ErrUnsupported if it is none
+func AsCounters(dal Dal) (Counters, error) {
+
+	if c, ok := dal.(Counters); ok {
+		return c, nil
+	}
+
+	return nil, unsupported("Counters")
+}
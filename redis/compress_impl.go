@@ -0,0 +1,238 @@
+/**
+ * @Author KYIMH
+ * @Description
+ * @Date 2026/10/19 22:40
+ **/
+
+package redis
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"errors"
+	"github.com/go-redis/redis"
+	"github.com/sirupsen/logrus"
+	"io"
+	"io/ioutil"
+	"strings"
+)
+
+//compressed values start with marker and the algorithm byte, values without it are returned as stored
+const (
+	compressMarker           = "\x00ccz"
+	defaultCompressThreshold = 1024
+)
+
+var errUnknownCompression = errors.New("redis: unknown compression algorithm")
+
+//compressValue -> value with a per call compression, see Compress
+type compressValue struct {
+	value       interface{}
+	compression Compression
+}
+
+//raw bytes of the value, for writers that do not know about compression
+func (v compressValue) MarshalBinary() ([]byte, error) {
+
+	switch raw := v.value.(type) {
+	case string:
+		return []byte(raw), nil
+	case []byte:
+		return raw, nil
+	}
+
+	return nil, errors.New("redis: only string and []byte values can be compressed")
+}
+
+//wrap a RedisSet / RedisSetNX value to compress it with compression instead of the tag setting
+//example: cli.RedisSet(tag, key, redis.Compress(snapshot, redis.Compression{Algorithm: redis.CompressGzip}), 0)
+func Compress(value interface{}, compression Compression) interface{} {
+
+	return compressValue{value: value, compression: compression}
+}
+
+//wrap a value to store it raw even if its tag compresses
+func Uncompressed(value interface{}) interface{} {
+
+	return compressValue{value: value}
+}
+
+//value to hand to the driver, compressed and then sealed when the call or the tag asks for it
+func (c ClientImpl) encodeValue(redisTag string, value interface{}) (interface{}, error) {
+
+	value, err := c.compressed(redisTag, value)
+	if nil != err {
+		return nil, err
+	}
+
+	cipher := c.cipher(redisTag)
+	if nil == cipher {
+		return value, nil
+	}
+
+	var raw []byte
+	switch v := value.(type) {
+	case string:
+		raw = []byte(v)
+	case []byte:
+		raw = v
+	default:
+		//numbers stay in the clear so INCR and friends keep working
+		return value, nil
+	}
+
+	sealed, err := cipher.Encrypt(raw)
+	if nil != err {
+		return nil, err
+	}
+
+	return sealed, nil
+}
+
+//value compressed when the call or the tag asks for it
+func (c ClientImpl) compressed(redisTag string, value interface{}) (interface{}, error) {
+
+	compression := c.compression(redisTag)
+	if wrapped, ok := value.(compressValue); ok {
+		value = wrapped.value
+		compression = wrapped.compression
+	}
+
+	if compression.Algorithm == CompressNone {
+		return value, nil
+	}
+
+	var raw []byte
+	switch v := value.(type) {
+	case string:
+		raw = []byte(v)
+	case []byte:
+		raw = v
+	default:
+		return value, nil
+	}
+
+	threshold := compression.Threshold
+	if threshold <= 0 {
+		threshold = defaultCompressThreshold
+	}
+	if len(raw) < threshold {
+		return value, nil
+	}
+
+	compressed, err := compressBytes(raw, compression)
+	if nil != err {
+		return nil, err
+	}
+	//incompressible payloads stay raw
+	if len(compressed) >= len(raw) {
+		return value, nil
+	}
+
+	return compressed, nil
+}
+
+func compressBytes(raw []byte, compression Compression) ([]byte, error) {
+
+	level := compression.Level
+	if level == 0 {
+		level = flate.DefaultCompression
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(compressMarker)
+	buf.WriteByte(byte(compression.Algorithm))
+
+	var w io.WriteCloser
+	var err error
+	switch compression.Algorithm {
+	case CompressGzip:
+		w, err = gzip.NewWriterLevel(&buf, level)
+	case CompressFlate:
+		w, err = flate.NewWriter(&buf, level)
+	default:
+		return nil, errUnknownCompression
+	}
+	if nil != err {
+		return nil, err
+	}
+
+	_, err = w.Write(raw)
+	if nil != err {
+		return nil, err
+	}
+	err = w.Close()
+	if nil != err {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+//stored value as written by the caller, legacy plaintext and uncompressed values pass through
+func (c ClientImpl) decodeValue(redisTag string, stored string) (string, error) {
+
+	if cipher := c.cipher(redisTag); nil != cipher && cipher.IsEncrypted([]byte(stored)) {
+		plain, err := cipher.Decrypt([]byte(stored))
+		if nil != err {
+			return "", err
+		}
+		stored = string(plain)
+	}
+
+	return decompress(stored)
+}
+
+//stored value decompressed, values without the marker are returned as is
+func decompress(stored string) (string, error) {
+
+	if !strings.HasPrefix(stored, compressMarker) || len(stored) <= len(compressMarker) {
+		return stored, nil
+	}
+
+	body := strings.NewReader(stored[len(compressMarker)+1:])
+
+	var r io.ReadCloser
+	var err error
+	switch CompressAlgorithm(stored[len(compressMarker)]) {
+	case CompressGzip:
+		r, err = gzip.NewReader(body)
+	case CompressFlate:
+		r = flate.NewReader(body)
+	default:
+		return "", errUnknownCompression
+	}
+	if nil != err {
+		return "", err
+	}
+	defer r.Close()
+
+	raw, err := ioutil.ReadAll(r)
+	if nil != err {
+		return "", err
+	}
+
+	return string(raw), nil
+}
+
+//GET key decoded, redis.Nil if key does not exist
+func (c ClientImpl) getValue(redisTag string, key string) (string, error) {
+
+	var stored string
+	err := c.read(redisTag, func(cli *redis.Client) (err error) {
+		stored, err = cli.Do("GET", key).String()
+		return
+	})
+	if nil != err {
+		return "", err
+	}
+
+	value, err := c.decodeValue(redisTag, stored)
+	if nil != err {
+		logrus.Error("Redis decode Error! key:", key, "Details:", err.Error())
+		return "", err
+	}
+
+	return value, nil
+}
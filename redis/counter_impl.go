@@ -0,0 +1,171 @@
+/**
+ * @Author KYIMH
+ * @Description
+ * @Date 2026/10/19 16:20
+ **/
+
+package redis
+
+import (
+	"github.com/go-redis/redis"
+	"github.com/sirupsen/logrus"
+	"strconv"
+)
+
+//INCRBY, then set the expire (milliseconds) if the key has none, so the first increment starts the window
+//KEYS[1]: counter, ARGV[1]: delta, ARGV[2]: expire ms
+var incrWithExpireScript = redis.NewScript(`
+local v = redis.call('INCRBY', KEYS[1], ARGV[1])
+if tonumber(ARGV[2]) > 0 and redis.call('PTTL', KEYS[1]) == -1 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return v
+`)
+
+//same as incrWithExpireScript, but refuse the increment if the result would exceed the cap
+//KEYS[1]: counter, ARGV[1]: delta, ARGV[2]: cap, ARGV[3]: expire ms
+//returns {value, capped}
+var incrCappedScript = redis.NewScript(`
+local cur = tonumber(redis.call('GET', KEYS[1]) or '0')
+if cur + tonumber(ARGV[1]) > tonumber(ARGV[2]) then
+	return {cur, 1}
+end
+local v = redis.call('INCRBY', KEYS[1], ARGV[1])
+if tonumber(ARGV[3]) > 0 and redis.call('PTTL', KEYS[1]) == -1 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[3])
+end
+return {v, 0}
+`)
+
+func (c ClientImpl) RedisIncr(redisTag string, key string) (int64, error) {
+
+	return c.RedisIncrBy(redisTag, key, 1)
+}
+
+func (c ClientImpl) RedisIncrBy(redisTag string, key string, delta int64) (int64, error) {
+
+	cli, err := c.GetClient(redisTag)
+	if nil != err {
+		return 0, err
+	}
+
+	v, err := cli.IncrBy(key, delta).Result()
+	if err != nil {
+		logrus.Error("RedisIncrBy Error! key:", key, "delta:", delta, "Details:", err.Error())
+		return 0, err
+	}
+
+	return v, nil
+}
+
+func (c ClientImpl) RedisIncrByFloat(redisTag string, key string, delta float64) (float64, error) {
+
+	cli, err := c.GetClient(redisTag)
+	if nil != err {
+		return 0.0, err
+	}
+
+	v, err := cli.IncrByFloat(key, delta).Result()
+	if err != nil {
+		logrus.Error("RedisIncrByFloat Error! key:", key, "delta:", delta, "Details:", err.Error())
+		return 0.0, err
+	}
+
+	return v, nil
+}
+
+func (c ClientImpl) RedisDecr(redisTag string, key string) (int64, error) {
+
+	return c.RedisDecrBy(redisTag, key, 1)
+}
+
+func (c ClientImpl) RedisDecrBy(redisTag string, key string, delta int64) (int64, error) {
+
+	cli, err := c.GetClient(redisTag)
+	if nil != err {
+		return 0, err
+	}
+
+	v, err := cli.DecrBy(key, delta).Result()
+	if err != nil {
+		logrus.Error("RedisDecrBy Error! key:", key, "delta:", delta, "Details:", err.Error())
+		return 0, err
+	}
+
+	return v, nil
+}
+
+//increase key by delta, the expire in milliseconds like RedisSet is set atomically when the key has none
+func (c ClientImpl) RedisIncrWithExpire(redisTag string, key string, delta int64, expire int) (int64, error) {
+
+	cli, err := c.GetClient(redisTag)
+	if nil != err {
+		return 0, err
+	}
+
+	v, err := incrWithExpireScript.Run(cli, []string{key}, delta, expire).Int64()
+	if err != nil {
+		logrus.Error("RedisIncrWithExpire Error! key:", key, "delta:", delta, "Details:", err.Error())
+		return 0, err
+	}
+
+	return v, nil
+}
+
+//increase key by delta unless the result exceeds limit, the expire (milliseconds) is set like RedisIncrWithExpire
+//when capped is true the counter is left untouched and value is its current value
+func (c ClientImpl) RedisIncrCapped(redisTag string, key string, delta int64, limit int64, expire int) (value int64, capped bool, err error) {
+
+	cli, err := c.GetClient(redisTag)
+	if nil != err {
+		return 0, false, err
+	}
+
+	res, err := incrCappedScript.Run(cli, []string{key}, delta, limit, expire).Result()
+	if err != nil {
+		logrus.Error("RedisIncrCapped Error! key:", key, "delta:", delta, "limit:", limit, "Details:", err.Error())
+		return 0, false, err
+	}
+
+	pair, ok := res.([]interface{})
+	if !ok || len(pair) != 2 {
+		return 0, false, redis.Nil
+	}
+	value, _ = pair[0].(int64)
+	flag, _ := pair[1].(int64)
+
+	return value, flag == 1, nil
+}
+
+//read many counters in one round trip, missing keys read as 0
+func (c ClientImpl) RedisMGetInt64(redisTag string, keys ...string) ([]int64, error) {
+
+	cli, err := c.GetClient(redisTag)
+	if nil != err {
+		return nil, err
+	}
+
+	if len(keys) == 0 {
+		return []int64{}, nil
+	}
+
+	values, err := cli.MGet(keys...).Result()
+	if err != nil {
+		logrus.Error("RedisMGetInt64 Error! keys:", keys, "Details:", err.Error())
+		return nil, err
+	}
+
+	counters := make([]int64, len(values))
+	for i, v := range values {
+		s, ok := v.(string)
+		if !ok {
+			continue
+		}
+		counters[i], err = strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return counters, nil
+}
@@ -0,0 +1,299 @@
+/**
+ * @Author KYIMH
+ * @Description
+ * @Date 2026/10/19 22:10
+ **/
+
+package redis
+
+import (
+	"errors"
+	"github.com/go-redis/redis"
+	"github.com/sirupsen/logrus"
+	"strings"
+	"time"
+)
+
+const defaultMigrateCount = 100
+
+type copyResult int
+
+const (
+	keyCopied copyResult = iota
+	keyVanished
+	keyExists
+)
+
+//DUMP key from source and RESTORE it on target with its remaining TTL
+//without replace an existing target key is left alone and keyExists returned
+//PTTL answers -2ms for a missing key and -1ms for a key without expire
+func copyKey(source *redis.Client, target *redis.Client, key string, replace bool) (copyResult, error) {
+
+	payload, err := source.Dump(key).Result()
+	if err == redis.Nil {
+		return keyVanished, nil
+	}
+	if nil != err {
+		return 0, err
+	}
+
+	ttl, err := source.PTTL(key).Result()
+	if nil != err {
+		return 0, err
+	}
+	if ttl == -2*time.Millisecond {
+		return keyVanished, nil
+	}
+	if ttl < 0 {
+		ttl = 0
+	}
+
+	if replace {
+		err = target.RestoreReplace(key, ttl, payload).Err()
+	} else {
+		err = target.Restore(key, ttl, payload).Err()
+		if nil != err && strings.HasPrefix(err.Error(), "BUSYKEY") {
+			return keyExists, nil
+		}
+	}
+	if nil != err {
+		return 0, err
+	}
+
+	return keyCopied, nil
+}
+
+//copy keys matching options.Match from sourceTag to destTag of dal with DUMP/RESTORE keeping TTLs
+//ErrSameServer if both tags reach the same redis process and db, whatever address they use
+//on error the returned result holds the cursor of the failed batch, pass it as options.Cursor to resume,
+//a resumed batch is copied again which is harmless in both modes
+func Migrate(dal Dal, sourceTag string, destTag string, options MigrateOptions) (MigrateResult, error) {
+
+	result := MigrateResult{Cursor: options.Cursor, Mismatched: []string{}}
+	if options.Count <= 0 {
+		options.Count = defaultMigrateCount
+	}
+
+	source, err := dal.GetClient(sourceTag)
+	if nil != err {
+		return result, err
+	}
+	target, err := dal.GetClient(destTag)
+	if nil != err {
+		return result, err
+	}
+	//copying onto itself and then deleting the source would wipe the keys
+	same, err := sameServer(source, target)
+	if nil != err {
+		logrus.Error("redis migrate Error! from:", sourceTag, "to:", destTag, "Details:", err.Error())
+		return result, err
+	}
+	if same {
+		return result, ErrSameServer
+	}
+
+	start := time.Now()
+	for {
+		keys, next, err := source.Scan(result.Cursor, options.Match, options.Count).Result()
+		if nil != err {
+			logrus.Error("redis migrate Error! tag:", sourceTag, "Details:", err.Error())
+			return result, err
+		}
+
+		batch := result
+		err = migrateBatch(source, target, keys, options, &batch)
+		if nil != err {
+			logrus.Error("redis migrate Error! from:", sourceTag, "to:", destTag, "Details:", err.Error())
+			return result, err
+		}
+		result = batch
+		result.Cursor = next
+		result.Done = 0 == next
+
+		if nil != options.OnBatch {
+			options.OnBatch(result)
+		}
+		if result.Done {
+			return result, nil
+		}
+
+		if options.Rate > 0 {
+			due := start.Add(time.Duration(result.Scanned) * time.Second / time.Duration(options.Rate))
+			if wait := time.Until(due); wait > 0 {
+				time.Sleep(wait)
+			}
+		}
+	}
+}
+
+//source and target talk to the same database: the same redis process by run_id and the same db index
+//addresses are not compared, a hostname, an ip or a proxy may all lead to one server
+func sameServer(source *redis.Client, target *redis.Client) (bool, error) {
+
+	if source == target {
+		return true, nil
+	}
+	if source.Options().DB != target.Options().DB {
+		return false, nil
+	}
+
+	sourceId, err := runId(source)
+	if nil != err {
+		return false, err
+	}
+	targetId, err := runId(target)
+	if nil != err {
+		return false, err
+	}
+
+	return sourceId == targetId, nil
+}
+
+//run_id of INFO server, unique per redis process
+func runId(cli *redis.Client) (string, error) {
+
+	info, err := cli.Info("server").Result()
+	if nil != err {
+		return "", err
+	}
+
+	for _, line := range strings.Split(info, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "run_id:") {
+			return strings.TrimPrefix(line, "run_id:"), nil
+		}
+	}
+
+	return "", errors.New("redis: INFO server of " + cli.Options().Addr + " has no run_id")
+}
+
+//copy, verify and delete one scan batch, counters are added to result
+func migrateBatch(source *redis.Client, target *redis.Client, keys []string, options MigrateOptions, result *MigrateResult) error {
+
+	copied := make([]string, 0, len(keys))
+	for _, key := range keys {
+		result.Scanned++
+
+		status, err := copyKey(source, target, key, options.Mode == MigrateReplace)
+		if nil != err {
+			return err
+		}
+		switch status {
+		case keyCopied:
+			result.Copied++
+			copied = append(copied, key)
+		case keyExists:
+			result.Skipped++
+		}
+	}
+
+	deletable := copied
+	if options.Verify {
+		verified, err := verifyKeys(source, target, copied)
+		if nil != err {
+			return err
+		}
+		result.Verified += int64(len(verified))
+		for _, key := range copied {
+			if !verified[key] {
+				result.Mismatched = append(result.Mismatched, key)
+			}
+		}
+
+		deletable = make([]string, 0, len(verified))
+		for _, key := range copied {
+			if verified[key] {
+				deletable = append(deletable, key)
+			}
+		}
+	}
+
+	if options.DeleteSource && len(deletable) > 0 {
+		n, err := source.Del(deletable...).Result()
+		if nil != err {
+			return err
+		}
+		result.Deleted += n
+	}
+
+	return nil
+}
+
+//keys whose type and element count match on both sides
+func verifyKeys(source *redis.Client, target *redis.Client, keys []string) (map[string]bool, error) {
+
+	verified := make(map[string]bool, len(keys))
+	if len(keys) == 0 {
+		return verified, nil
+	}
+
+	sourceSizes, err := keySizes(source, keys)
+	if nil != err {
+		return nil, err
+	}
+	targetSizes, err := keySizes(target, keys)
+	if nil != err {
+		return nil, err
+	}
+
+	for i, key := range keys {
+		//a source key that expired after the copy has nothing left to compare
+		if "none" != sourceSizes[i].typ && sourceSizes[i] == targetSizes[i] {
+			verified[key] = true
+		}
+	}
+
+	return verified, nil
+}
+
+type keySize struct {
+	typ    string
+	length int64
+}
+
+//type and element count of keys in two pipelines
+func keySizes(cli *redis.Client, keys []string) ([]keySize, error) {
+
+	pipe := cli.Pipeline()
+	types := make([]*redis.StatusCmd, len(keys))
+	for i, key := range keys {
+		types[i] = pipe.Type(key)
+	}
+	_, err := pipe.Exec()
+	if nil != err {
+		return nil, err
+	}
+
+	sizes := make([]keySize, len(keys))
+	lengths := make([]*redis.IntCmd, len(keys))
+	pipe = cli.Pipeline()
+	for i, key := range keys {
+		sizes[i].typ = types[i].Val()
+		switch sizes[i].typ {
+		case "string":
+			lengths[i] = pipe.StrLen(key)
+		case "list":
+			lengths[i] = pipe.LLen(key)
+		case "hash":
+			lengths[i] = pipe.HLen(key)
+		case "set":
+			lengths[i] = pipe.SCard(key)
+		case "zset":
+			lengths[i] = pipe.ZCard(key)
+		case "stream":
+			lengths[i] = pipe.XLen(key)
+		}
+	}
+	_, err = pipe.Exec()
+	if nil != err && err != redis.Nil {
+		return nil, err
+	}
+
+	for i := range sizes {
+		if nil != lengths[i] {
+			sizes[i].length = lengths[i].Val()
+		}
+	}
+
+	return sizes, nil
+}
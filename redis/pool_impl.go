@@ -0,0 +1,203 @@
+/**
+ * @Author KYIMH
+ * @Description
+ * @Date 2026/10/20 00:10
+ **/
+
+package redis
+
+import (
+	"errors"
+	"github.com/go-redis/redis"
+	"sync"
+	"time"
+)
+
+const defaultDrainTimeout = 5 * time.Second
+
+var errNoPool = errors.New("redis: ClientImpl has no pool, create it with NewClientImpl or set Pool")
+
+type ClientPoolType map[string]*redis.Client
+
+//poolState -> lock of Pool, Replicas, Compression and Ciphers of a ClientImpl, shared by its copies
+type poolState struct {
+	mu       sync.RWMutex
+	retiring sync.WaitGroup
+}
+
+//state of every ClientImpl built by struct literal instead of NewClientImpl
+var literalState poolState
+
+func (c ClientImpl) state() *poolState {
+
+	if nil != c.lock {
+		return c.lock
+	}
+
+	return &literalState
+}
+
+//client of redisTag
+func (c ClientImpl) client(redisTag string) (*redis.Client, bool) {
+
+	s := c.state()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	cli, ok := c.Pool[redisTag]
+
+	return cli, ok
+}
+
+//compression setting of redisTag
+func (c ClientImpl) compression(redisTag string) Compression {
+
+	s := c.state()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return c.Compression[redisTag]
+}
+
+//value cipher of redisTag, nil if its values are stored in the clear
+func (c ClientImpl) cipher(redisTag string) ValueCipher {
+
+	s := c.state()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return c.Ciphers[redisTag]
+}
+
+//encrypt the values of redisTag with cipher, nil turns encryption off
+//values written before are still readable, values sealed by a removed cipher are not
+func (c ClientImpl) SetCipher(redisTag string, cipher ValueCipher) error {
+
+	s := c.state()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if nil == cipher {
+		delete(c.Ciphers, redisTag)
+		return nil
+	}
+	if nil == c.Ciphers {
+		return errors.New("redis: ClientImpl has no Ciphers map for tag " + redisTag)
+	}
+	c.Ciphers[redisTag] = cipher
+
+	return nil
+}
+
+//read replicas of redisTag
+func (c ClientImpl) replicas(redisTag string) (*ReplicaSet, bool) {
+
+	s := c.state()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	replicas, ok := c.Replicas[redisTag]
+
+	return replicas, ok && nil != replicas
+}
+
+//set the client of redisTag, returns the client it replaced
+func (c ClientImpl) swap(redisTag string, cli *redis.Client) (*redis.Client, bool, error) {
+
+	s := c.state()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if nil == c.Pool {
+		return nil, false, errNoPool
+	}
+
+	old, replaced := c.Pool[redisTag]
+	c.Pool[redisTag] = cli
+
+	return old, replaced, nil
+}
+
+//set the client, compression and replicas of config.RedisTag, returns the client and replicas they replaced
+func (c ClientImpl) swapTag(config RedisConfig, cli *redis.Client, replicas *ReplicaSet) (*redis.Client, *ReplicaSet, error) {
+
+	s := c.state()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tag := config.RedisTag
+	if nil == c.Pool {
+		return nil, nil, errNoPool
+	}
+	if config.Compression.Algorithm != CompressNone && nil == c.Compression {
+		return nil, nil, errors.New("redis: ClientImpl has no Compression map for tag " + tag)
+	}
+	if nil != replicas && nil == c.Replicas {
+		return nil, nil, errors.New("redis: ClientImpl has no Replicas map for tag " + tag)
+	}
+
+	if config.Compression.Algorithm != CompressNone {
+		c.Compression[tag] = config.Compression
+	} else {
+		delete(c.Compression, tag)
+	}
+
+	oldReplicas := c.Replicas[tag]
+	if nil != replicas {
+		c.Replicas[tag] = replicas
+	} else {
+		delete(c.Replicas, tag)
+	}
+
+	old := c.Pool[tag]
+	c.Pool[tag] = cli
+
+	return old, oldReplicas, nil
+}
+
+//forget the client, compression, cipher and replicas of redisTag, returns the client and replicas
+func (c ClientImpl) remove(redisTag string) (*redis.Client, *ReplicaSet, bool) {
+
+	s := c.state()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cli, ok := c.Pool[redisTag]
+	replicas := c.Replicas[redisTag]
+	delete(c.Pool, redisTag)
+	delete(c.Replicas, redisTag)
+	delete(c.Compression, redisTag)
+	delete(c.Ciphers, redisTag)
+
+	return cli, replicas, ok
+}
+
+//copy of the pool map to range over
+func (c ClientImpl) Clients() ClientPoolType {
+
+	s := c.state()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	clients := make(ClientPoolType, len(c.Pool))
+	for tag, cli := range c.Pool {
+		clients[tag] = cli
+	}
+
+	return clients
+}
+
+//copy of the replica map to range over
+func (c ClientImpl) replicaSets() ReplicaPoolType {
+
+	s := c.state()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	replicas := make(ReplicaPoolType, len(c.Replicas))
+	for tag, set := range c.Replicas {
+		replicas[tag] = set
+	}
+
+	return replicas
+}
@@ -0,0 +1,359 @@
+/**
+ * @Author KYIMH
+ * @Description
+ * @Date 2026/10/20 05:10
+ **/
+
+package redis
+
+import (
+	"errors"
+	"github.com/go-redis/redis"
+	"github.com/sirupsen/logrus"
+	"hash/fnv"
+	"sync"
+)
+
+const (
+	rebalanceScanCount = 1000
+	migrationStripes   = 64
+)
+
+//migration -> keys moving to the shard AddShard adds, one key at a time
+//a key is moved under its stripe lock by the first write to it or by the AddShard scan, whichever comes first,
+//once moved the old copy is frozen and reads and writes go to the new shard
+type migration struct {
+	next    *ring
+	tag     string
+	target  *redis.Client
+	stripes [migrationStripes]sync.Mutex
+
+	mu     sync.Mutex
+	moved  map[string]bool
+	copied int
+}
+
+func newMigration(next *ring, tag string, target *redis.Client) *migration {
+
+	return &migration{
+		next:   next,
+		tag:    tag,
+		target: target,
+		moved:  make(map[string]bool),
+	}
+}
+
+//true if key changes owner in this migration
+func (m *migration) moving(key string) bool {
+
+	return m.next.owner(key) == m.tag
+}
+
+func (m *migration) stripeOf(key string) int {
+
+	h := fnv.New32a()
+	h.Write([]byte(key))
+
+	return int(h.Sum32() % migrationStripes)
+}
+
+func (m *migration) isMoved(key string) bool {
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.moved[key]
+}
+
+//keys moved so far
+func (m *migration) movedKeys() []string {
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	keys := make([]string, 0, len(m.moved))
+	for key := range m.moved {
+		keys = append(keys, key)
+	}
+
+	return keys
+}
+
+//shard holding key: the owner in r, or the new shard of m once key moved there
+func holderOf(r *ring, m *migration, key string) string {
+
+	if nil != m && m.moving(key) && m.isMoved(key) {
+		return m.tag
+	}
+
+	return r.owner(key)
+}
+
+func (s *ShardedImpl) routing() (*ring, *migration) {
+
+	s.state.mu.RLock()
+	defer s.state.mu.RUnlock()
+
+	return s.state.ring, s.state.migration
+}
+
+//shard to write key to, see writeShards
+func (s *ShardedImpl) writeShard(key string) (string, func(), error) {
+
+	tags, done, err := s.writeShards([]string{key})
+	if nil != err {
+		return "", nil, err
+	}
+
+	return tags[0], done, nil
+}
+
+//shards to write keys to, call done once the write returned
+//while AddShard runs the keys it moves are copied to the new shard first and stay locked until done,
+//so no write lands on an old copy the scan already moved
+func (s *ShardedImpl) writeShards(keys []string) ([]string, func(), error) {
+
+	s.state.moving.RLock()
+
+	r, m := s.routing()
+	tags := make([]string, len(keys))
+	if nil == m {
+		for i, key := range keys {
+			tags[i] = r.owner(key)
+		}
+		return tags, s.state.moving.RUnlock, nil
+	}
+
+	//stripes are taken in index order so batches sharing stripes cannot deadlock
+	held := make([]bool, migrationStripes)
+	for _, key := range keys {
+		if m.moving(key) {
+			held[m.stripeOf(key)] = true
+		}
+	}
+	for i, lock := range held {
+		if lock {
+			m.stripes[i].Lock()
+		}
+	}
+	done := func() {
+		for i, lock := range held {
+			if lock {
+				m.stripes[i].Unlock()
+			}
+		}
+		s.state.moving.RUnlock()
+	}
+
+	for i, key := range keys {
+		if !m.moving(key) {
+			tags[i] = r.owner(key)
+			continue
+		}
+		err := s.moveKey(r, m, key)
+		if nil != err {
+			done()
+			return nil, nil, err
+		}
+		tags[i] = m.tag
+	}
+
+	return tags, done, nil
+}
+
+//copy key from its owner in r to the new shard of m unless already moved, caller holds the stripe of key
+func (s *ShardedImpl) moveKey(r *ring, m *migration, key string) error {
+
+	if m.isMoved(key) {
+		return nil
+	}
+
+	tag := r.owner(key)
+	source, err := s.Dal.GetClient(tag)
+	if nil != err {
+		return err
+	}
+
+	result, err := copyKey(source, m.target, key, true)
+	if nil == err && result == keyVanished {
+		//drop what an earlier failed AddShard may have left on the new shard
+		err = m.target.Del(key).Err()
+	}
+	if nil != err {
+		logrus.Error("redis rebalance Error! shard:", tag, "key:", key, "Details:", err.Error())
+		return err
+	}
+
+	m.mu.Lock()
+	m.moved[key] = true
+	if result == keyCopied {
+		m.copied++
+	}
+	m.mu.Unlock()
+
+	return nil
+}
+
+//add redisTag to the ring and move the keys it now owns from the other shards, returns the copied keys
+//the client of redisTag must already be in Dal, the shards are scanned page by page and keys move one at a time,
+//a write to a moving key moves it first, so write commands of this process only wait for the keys they touch,
+//a failed copy moves the keys back and leaves the ring unchanged
+//RedisBLPOP already waiting on a moving list keeps waiting on the old shard, pushes after the move go to the new one
+//the sources are not deleted: other processes keep routing to the old shards until they add the shard too,
+//call PurgeMoved once every process switched, writes of lagging processes after the copy are lost by the purge
+func (s *ShardedImpl) AddShard(redisTag string) (int, error) {
+
+	s.state.rebalance.Lock()
+	defer s.state.rebalance.Unlock()
+
+	current := s.ring()
+	for _, tag := range current.tags {
+		if tag == redisTag {
+			return 0, errors.New("redis: shard " + redisTag + " already in ring")
+		}
+	}
+
+	target, err := s.Dal.GetClient(redisTag)
+	if nil != err {
+		return 0, err
+	}
+	m := newMigration(current.with(redisTag), redisTag, target)
+
+	//writes routed before the migration started must land before the scan copies their keys
+	s.switchState(current, m)
+
+	for _, tag := range current.tags {
+		err = s.moveOwned(tag, current, m)
+		if nil != err {
+			s.rollback(current, m)
+			return 0, err
+		}
+	}
+
+	s.switchState(m.next, nil)
+
+	return m.copied, nil
+}
+
+//set ring and migration once no write command is in flight
+func (s *ShardedImpl) switchState(r *ring, m *migration) {
+
+	s.state.moving.Lock()
+	defer s.state.moving.Unlock()
+
+	s.state.mu.Lock()
+	s.state.ring = r
+	s.state.migration = m
+	s.state.mu.Unlock()
+}
+
+//move the keys of a failed migration back to their owners in r and end it
+func (s *ShardedImpl) rollback(r *ring, m *migration) {
+
+	s.state.moving.Lock()
+	defer s.state.moving.Unlock()
+
+	for _, key := range m.movedKeys() {
+		tag := r.owner(key)
+		source, err := s.Dal.GetClient(tag)
+		if nil == err {
+			var result copyResult
+			result, err = copyKey(m.target, source, key, true)
+			if nil == err && result == keyVanished {
+				err = source.Del(key).Err()
+			}
+		}
+		if nil != err {
+			logrus.Error("redis rebalance rollback Error! shard:", tag, "key:", key, "Details:", err.Error())
+		}
+	}
+
+	s.state.mu.Lock()
+	s.state.migration = nil
+	s.state.mu.Unlock()
+}
+
+//move the keys of shard tag that m assigns to its new shard, one SCAN page at a time
+func (s *ShardedImpl) moveOwned(tag string, r *ring, m *migration) error {
+
+	source, err := s.Dal.GetClient(tag)
+	if nil != err {
+		return err
+	}
+
+	var cursor uint64
+	for {
+		keys, nextCursor, err := source.Scan(cursor, "", rebalanceScanCount).Result()
+		if nil != err {
+			logrus.Error("redis rebalance Error! shard:", tag, "Details:", err.Error())
+			return err
+		}
+
+		for _, key := range keys {
+			if !m.moving(key) {
+				continue
+			}
+			lock := &m.stripes[m.stripeOf(key)]
+			lock.Lock()
+			err = s.moveKey(r, m, key)
+			lock.Unlock()
+			if nil != err {
+				return err
+			}
+		}
+
+		cursor = nextCursor
+		if 0 == cursor {
+			return nil
+		}
+	}
+}
+
+//delete the keys every shard holds but no longer owns, the second step of AddShard, returns the deleted keys
+//only call it once every process sharing the shards switched to the same ring, keys a lagging process
+//still reads or writes on the old shard are deleted
+func (s *ShardedImpl) PurgeMoved() (int, error) {
+
+	s.state.rebalance.Lock()
+	defer s.state.rebalance.Unlock()
+
+	r := s.ring()
+	purged := 0
+	for _, tag := range r.tags {
+		source, err := s.Dal.GetClient(tag)
+		if nil != err {
+			return purged, err
+		}
+
+		var cursor uint64
+		for {
+			keys, nextCursor, err := source.Scan(cursor, "", rebalanceScanCount).Result()
+			if nil != err {
+				logrus.Error("redis purge Error! shard:", tag, "Details:", err.Error())
+				return purged, err
+			}
+
+			moved := make([]string, 0, len(keys))
+			for _, key := range keys {
+				if r.owner(key) != tag {
+					moved = append(moved, key)
+				}
+			}
+			if len(moved) > 0 {
+				err = s.Dal.RedisBatchDel(tag, moved...)
+				if nil != err {
+					logrus.Error("redis purge Error! shard:", tag, "Details:", err.Error())
+					return purged, err
+				}
+				purged += len(moved)
+			}
+
+			cursor = nextCursor
+			if 0 == cursor {
+				break
+			}
+		}
+	}
+
+	return purged, nil
+}
@@ -7,17 +7,121 @@
 package redis
 
 import (
+	"errors"
 	"github.com/go-redis/redis"
 	"time"
 )
 
+//ErrUnsupported -> the Dal does not implement an optional interface like SortedSets or Counters
+var ErrUnsupported = errors.New("redis: operation not supported by this Dal")
+
+//ErrCrossShard -> keys of one multi key command live on different shards
+var ErrCrossShard = errors.New("redis: keys map to different shards")
+
+//ErrSameServer -> source and destination of Migrate are the same redis database
+var ErrSameServer = errors.New("redis: migrate source and destination are the same server")
+
+//RedisConfig -> redis config read from zk data
+//RedisTag: tag the client is registered under in ClientImpl.Pool
+//Addr: address of redis example: 127.0.0.1:6379
+//PoolSize: socket connect nums, 15 if 0
+//Timeout: dial, read and write timeout in milliseconds, driver defaults if 0
+//Compression: value compression of the tag, see ClientImpl.Compression
+//Replicas: addresses of read replicas of the tag, they share Password, DB, PoolSize and Timeout, only ClientImpl.Replica() reads them
+//ReplicaCheck: replica health check interval in milliseconds, 5s if 0, negative disables the check
+type RedisConfig struct {
+	RedisTag     string      `json:"redis_tag"`
+	Addr         string      `json:"addr"`
+	Password     string      `json:"password"`
+	DB           int         `json:"db"`
+	PoolSize     int         `json:"pool_size"`
+	Timeout      int64       `json:"timeout"`
+	Compression  Compression `json:"compression"`
+	Replicas     []string    `json:"replicas"`
+	ReplicaCheck int64       `json:"replica_check"`
+}
+
+//CompressAlgorithm -> algorithm byte stored after the compressed value marker
+type CompressAlgorithm byte
+
+const (
+	CompressNone  CompressAlgorithm = 0
+	CompressGzip  CompressAlgorithm = 'g'
+	CompressFlate CompressAlgorithm = 'f'
+)
+
+//Compression -> value compression of a tag or of one call
+//Algorithm: CompressGzip or CompressFlate, CompressNone stores values raw
+//Threshold: only string and []byte values of at least Threshold bytes are compressed, 1024 if 0
+//Level: compress/flate level, flate.DefaultCompression if 0
+type Compression struct {
+	Algorithm CompressAlgorithm `json:"algorithm"`
+	Threshold int               `json:"threshold"`
+	Level     int               `json:"level"`
+}
+
+//ValueCipher -> value encryption of a tag, crypto.Cipher satisfies it, see ClientImpl.SetCipher
+//IsEncrypted tells sealed values from legacy plaintext, which is returned as stored
+type ValueCipher interface {
+	Encrypt(plain []byte) ([]byte, error)
+	Decrypt(data []byte) ([]byte, error)
+	IsEncrypted(data []byte) bool
+}
+
+//MigrateMode -> what Migrate does with keys already in the destination
+type MigrateMode int
+
+const (
+	MigrateReplace MigrateMode = iota
+	MigrateSkipExisting
+)
+
+//MigrateOptions -> options of Migrate
+//Match: SCAN MATCH pattern, every key if empty
+//Count: SCAN COUNT hint and batch size, 100 if 0
+//Cursor: SCAN cursor to resume from, MigrateResult.Cursor of an interrupted run
+//Verify: compare type and element count of every copied key
+//DeleteSource: delete copied keys from the source, only verified ones when Verify is set
+//Rate: max keys per second, 0 means unthrottled
+//OnBatch: called after every batch, persist result.Cursor there to resume after a crash
+type MigrateOptions struct {
+	Match        string
+	Count        int64
+	Mode         MigrateMode
+	Cursor       uint64
+	Verify       bool
+	DeleteSource bool
+	Rate         int
+	OnBatch      func(result MigrateResult)
+}
+
+//MigrateResult -> progress of Migrate
+//Cursor: SCAN cursor of the next batch, Done when the scan finished
+//Mismatched: keys whose copy failed verification, never deleted from the source
+type MigrateResult struct {
+	Cursor     uint64   `json:"cursor"`
+	Done       bool     `json:"done"`
+	Scanned    int64    `json:"scanned"`
+	Copied     int64    `json:"copied"`
+	Skipped    int64    `json:"skipped"`
+	Verified   int64    `json:"verified"`
+	Deleted    int64    `json:"deleted"`
+	Mismatched []string `json:"mismatched"`
+}
+
 //redis client operators
 type Client interface {
 	GetClient(redisTag string) (*redis.Client, error)
+	AddClient2Pool(config RedisConfig) error
+	ReplaceClient(redisTag string, cli *redis.Client)
+	RemoveClient(redisTag string) error
+	Clients() ClientPoolType
+	SetCipher(redisTag string, cipher ValueCipher) error
 	Close() error
 }
 
 //redis data operators
+//optional operators live in the smaller interfaces below, get them with AsSortedSets, AsCounters ...
 type Dal interface {
 	GetClient(redisTag string) (*redis.Client, error)
 	Close() error
@@ -49,6 +153,61 @@ type Dal interface {
 	RedisListAllValuesWithPrefix(redisTag string, prefix string) (map[string]string, error)
 	RedisBatchDel(redisTag string, key ...string) error
 	RedisMset(redisTag string, pairs ...interface{}) error
-	getKeys(redisTag string, prefix string) ([]string, error)
-	getKeyAndValuesMap(redisTag string, keys []string, prefix string) (map[string]string, error)
+}
+
+//PrimaryReader -> Dal with a view whose reads always go to the primary, see PrimaryOf
+type PrimaryReader interface {
+	Primary() Dal
+}
+
+//ReplicaReader -> Dal with a view whose reads go to read replicas, see ReplicaOf
+type ReplicaReader interface {
+	Replica() Dal
+}
+
+//NXSetter -> Dal with SET NX, see AsNXSetter
+type NXSetter interface {
+	RedisSetNX(redisTag string, key string, value interface{}, expire int) (bool, error)
+}
+
+//XXSetter -> Dal with writes that only touch existing keys, see AsXXSetter
+//both report false when the key does not exist, expire is in milliseconds like RedisSet
+type XXSetter interface {
+	RedisSetXX(redisTag string, key string, value interface{}, expire int) (bool, error)
+	RedisPExpire(redisTag string, key string, expire int) (bool, error)
+}
+
+//SortedSets -> Dal with score range operators on sorted sets, see AsSortedSets
+type SortedSets interface {
+	RedisZScore(redisTag string, key string, member string) (score float64, exists bool, err error)
+	RedisZCount(redisTag string, key string, min string, max string) (int64, error)
+	RedisZRangeByScoreWithScores(redisTag string, key string, min string, max string, offset int64, count int64) (values []redis.Z, err error)
+	RedisZRemRangeByScore(redisTag string, key string, min string, max string) (int64, error)
+}
+
+//Publisher -> Dal with pub/sub publish, see AsPublisher
+type Publisher interface {
+	RedisPublish(redisTag string, channel string, message interface{}) error
+}
+
+//Counters -> Dal with atomic counters, see AsCounters
+//expire of RedisIncrWithExpire and RedisIncrCapped is in milliseconds like RedisSet
+type Counters interface {
+	RedisIncr(redisTag string, key string) (int64, error)
+	RedisIncrBy(redisTag string, key string, delta int64) (int64, error)
+	RedisIncrByFloat(redisTag string, key string, delta float64) (float64, error)
+	RedisDecr(redisTag string, key string) (int64, error)
+	RedisDecrBy(redisTag string, key string, delta int64) (int64, error)
+	RedisIncrWithExpire(redisTag string, key string, delta int64, expire int) (int64, error)
+	RedisIncrCapped(redisTag string, key string, delta int64, limit int64, expire int) (value int64, capped bool, err error)
+	RedisMGetInt64(redisTag string, keys ...string) ([]int64, error)
+}
+
+//consistent hash sharded redis operators, redisTag arguments of Dal are ignored
+type Sharded interface {
+	Dal
+	Shards() []string
+	ShardOf(key string) string
+	AddShard(redisTag string) (copied int, err error)
+	PurgeMoved() (purged int, err error)
 }
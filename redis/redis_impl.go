@@ -1,19 +1,37 @@
 package redis
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"github.com/KYIMH/CCS_Utils/share/multierr"
 	"github.com/go-redis/redis"
 	"github.com/sirupsen/logrus"
+	"io"
+	"io/ioutil"
 	"net"
+	"strconv"
 	"strings"
 	"time"
 )
 
-type ClientPoolType map[string]*redis.Client
-
+//ClientImpl -> redis client pool by tag
+//Pool: clients by tag, change it at runtime with AddClient2Pool, ReplaceClient and RemoveClient and range over Clients()
+//Replicas: optional read replicas by tag built from RedisConfig.Replicas, read only methods of the Replica() view are load balanced over them, reads stay on the primary otherwise
+//Compression: optional value compression by tag for RedisSet, RedisSetNX and RedisMset
+//Ciphers: optional value encryption by tag for the same writers, values are compressed before they are sealed
+//DrainTimeout: grace period before a replaced or removed client is closed, 5s if 0
+//Pool, Replicas, Compression and Ciphers are guarded by a lock shared by copies of the value, a ClientImpl built by
+//struct literal shares one package lock with every other literal, build the maps before using it concurrently
 type ClientImpl struct {
-	Pool ClientPoolType
+	Pool         ClientPoolType
+	Replicas     ReplicaPoolType
+	Compression  map[string]Compression
+	Ciphers      map[string]ValueCipher
+	DrainTimeout time.Duration
+
+	replicaReads bool
+	lock         *poolState
 }
 
 //create new redis client
@@ -68,10 +86,72 @@ func NewClient() *redis.Client {
 	return redisClient
 }
 
+//create redis client from config
+func NewClientWithConfig(config RedisConfig) *redis.Client {
+
+	if config.PoolSize <= 0 {
+		config.PoolSize = 15
+	}
+	timeout := time.Duration(config.Timeout) * time.Millisecond
+
+	return redis.NewClient(&redis.Options{
+		Network:      "tcp",
+		Addr:         config.Addr,
+		Password:     config.Password,
+		DB:           config.DB,
+		PoolSize:     config.PoolSize,
+		DialTimeout:  timeout,
+		ReadTimeout:  timeout,
+		WriteTimeout: timeout,
+	})
+}
+
+//create client pool from configs, one client per tag
+func NewClientImpl(configs ...RedisConfig) *ClientImpl {
+
+	pool := make(ClientPoolType, len(configs))
+	replicas := make(ReplicaPoolType)
+	compression := make(map[string]Compression)
+	for _, config := range configs {
+		pool[config.RedisTag] = NewClientWithConfig(config)
+		if set := NewReplicaSetWithConfig(config); nil != set {
+			replicas[config.RedisTag] = set
+		}
+		if config.Compression.Algorithm != CompressNone {
+			compression[config.RedisTag] = config.Compression
+		}
+	}
+
+	return &ClientImpl{
+		Pool:        pool,
+		Replicas:    replicas,
+		Compression: compression,
+		Ciphers:     make(map[string]ValueCipher),
+		lock:        new(poolState),
+	}
+}
+
+//read a json list of redis configs, the format zk data uses
+func ReadConfigFile(path string) ([]RedisConfig, error) {
+
+	data, err := ioutil.ReadFile(path)
+	if nil != err {
+		return nil, err
+	}
+
+	var configs []RedisConfig
+	err = json.Unmarshal(data, &configs)
+	if nil != err {
+		return nil, err
+	}
+
+	return configs, nil
+}
+
 //get redis client by redis tag name
 func (c ClientImpl) GetClient(redisTag string) (*redis.Client, error) {
 
-	cli, ok := c.Pool[redisTag]
+	cli, ok := c.client(redisTag)
 
 	if !ok {
 		return nil, errors.New("no connection " + redisTag + " in Manager")
@@ -80,17 +160,113 @@ func (c ClientImpl) GetClient(redisTag string) (*redis.Client, error) {
 	return cli, nil
 }
 
-//close all redis client
+//open a client for config and put it under its tag with the compression and replicas of config,
+//a client or replicas already there are closed after DrainTimeout
+func (c ClientImpl) AddClient2Pool(config RedisConfig) error {
+
+	cli := NewClientWithConfig(config)
+	err := cli.Ping().Err()
+	if nil != err {
+		cli.Close()
+		logrus.Error("redis connection failed: ", err.Error())
+		return err
+	}
+
+	//replicas are not pinged, an unreachable one is marked down by its health check and reads fall back to the primary
+	replicas := NewReplicaSetWithConfig(config)
+
+	old, oldReplicas, err := c.swapTag(config, cli, replicas)
+	if nil != err {
+		cli.Close()
+		if nil != replicas {
+			replicas.Close()
+		}
+		return err
+	}
+	if nil != old && old != cli {
+		c.retire(config.RedisTag, old)
+	}
+	if nil != oldReplicas {
+		c.retire(config.RedisTag, oldReplicas)
+	}
+
+	return nil
+}
+
+//put cli under redisTag atomically, calls already holding the old client finish on it,
+//the old client is closed after DrainTimeout
+func (c ClientImpl) ReplaceClient(redisTag string, cli *redis.Client) {
+
+	old, replaced, err := c.swap(redisTag, cli)
+	if nil != err {
+		logrus.Error("redis ReplaceClient Error! tag:", redisTag, "Details:", err.Error())
+		return
+	}
+	if replaced && old != cli {
+		c.retire(redisTag, old)
+	}
+}
+
+//forget the client and replicas of redisTag and close them after DrainTimeout
+func (c ClientImpl) RemoveClient(redisTag string) error {
+
+	cli, replicas, ok := c.remove(redisTag)
+	if nil != replicas {
+		c.retire(redisTag, replicas)
+	}
+	if !ok {
+		return errors.New("no connection " + redisTag + " in Manager")
+	}
+	c.retire(redisTag, cli)
+
+	return nil
+}
+
+//close cli in background once in-flight commands had DrainTimeout to finish
+//go-redis does not track in-flight commands, commands still running after the grace period fail
+func (c ClientImpl) retire(redisTag string, cli io.Closer) {
+
+	timeout := c.DrainTimeout
+	if timeout <= 0 {
+		timeout = defaultDrainTimeout
+	}
+
+	s := c.state()
+	s.retiring.Add(1)
+	go func() {
+		defer s.retiring.Done()
+
+		time.Sleep(timeout)
+		err := cli.Close()
+		if nil != err {
+			logrus.Error("redis close replaced client Error! tag:", redisTag, "Details:", err.Error())
+		}
+	}()
+}
+
+//close all redis client, every client is closed even if some fail and all errors are returned
+//waits for replaced clients still in their grace period
 func (c ClientImpl) Close() error {
 
-	for _, cli := range c.Pool {
+	var errs multierr.Errors
+
+	for tag, cli := range c.Clients() {
 		err := cli.Close()
 		if nil != err {
-			return err
+			errs.Add(fmt.Errorf("redis %s: %w", tag, err))
 		}
 	}
 
-	return nil
+	for tag, replicas := range c.replicaSets() {
+		err := replicas.Close()
+		if nil != err {
+			errs.Add(fmt.Errorf("redis replica %s: %w", tag, err))
+		}
+	}
+
+	c.state().retiring.Wait()
+
+	return errs.Err()
 }
 
 //redis String set
@@ -101,6 +277,12 @@ func (c ClientImpl) RedisSet(redisTag string, key string, value interface{}, exp
 		return err
 	}
 
+	value, err = c.encodeValue(redisTag, value)
+	if nil != err {
+		logrus.Error("RedisSet Error! key:", key, "Details:", err.Error())
+		return err
+	}
+
 	if expire > 0 {
 		err := cli.Do("SET", key, value, "PX", expire).Err()
 		if err != nil {
@@ -118,26 +300,72 @@ func (c ClientImpl) RedisSet(redisTag string, key string, value interface{}, exp
 	return nil
 }
 
-func (c ClientImpl) RedisKeyExists(redisTag string, key string) (bool, error) {
+//redis String set if not exists, expire in milliseconds like RedisSet, returns true if key was set
+func (c ClientImpl) RedisSetNX(redisTag string, key string, value interface{}, expire int) (bool, error) {
 
 	cli, err := c.GetClient(redisTag)
 	if nil != err {
 		return false, err
 	}
 
-	ok, err := cli.Do("EXISTS", key).Bool()
+	value, err = c.encodeValue(redisTag, value)
+	if nil != err {
+		logrus.Error("RedisSetNX Error! key:", key, "Details:", err.Error())
+		return false, err
+	}
+
+	ok, err := cli.SetNX(key, value, time.Duration(expire)*time.Millisecond).Result()
+	if err != nil {
+		logrus.Error("RedisSetNX Error! key:", key, "Details:", err.Error())
+		return false, err
+	}
+
+	return ok, nil
+}
+
+//redis String set only if exists, expire in milliseconds like RedisSet, returns true if key was set
+func (c ClientImpl) RedisSetXX(redisTag string, key string, value interface{}, expire int) (bool, error) {
+
+	cli, err := c.GetClient(redisTag)
+	if nil != err {
+		return false, err
+	}
+
+	value, err = c.encodeValue(redisTag, value)
+	if nil != err {
+		logrus.Error("RedisSetXX Error! key:", key, "Details:", err.Error())
+		return false, err
+	}
+
+	ok, err := cli.SetXX(key, value, time.Duration(expire)*time.Millisecond).Result()
+	if err != nil {
+		logrus.Error("RedisSetXX Error! key:", key, "Details:", err.Error())
+		return false, err
+	}
+
+	return ok, nil
+}
+
+func (c ClientImpl) RedisKeyExists(redisTag string, key string) (bool, error) {
+
+	var ok bool
+	err := c.read(redisTag, func(cli *redis.Client) (err error) {
+		ok, err = cli.Do("EXISTS", key).Bool()
+		return
+	})
 
 	return ok, err
 }
 
 func (c ClientImpl) RedisGet(redisTag string, key string) (string, error) {
 
-	cli, err := c.GetClient(redisTag)
+	//missing tag is an error, read failures read as empty
+	_, err := c.GetClient(redisTag)
 	if nil != err {
 		return "", err
 	}
 
-	value, err := cli.Do("GET", key).String()
+	value, err := c.getValue(redisTag, key)
 	if err != nil {
 		return "", nil
 	}
@@ -147,77 +375,67 @@ func (c ClientImpl) RedisGet(redisTag string, key string) (string, error) {
 
 func (c ClientImpl) RedisGetResult(redisTag string, key string) (interface{}, error) {
 
-	cli, err := c.GetClient(redisTag)
+	v, err := c.getValue(redisTag, key)
+	if err == redis.Nil {
+		return nil, nil
+	}
 	if nil != err {
 		return nil, err
 	}
 
-	v, err := cli.Do("GET", key).Result()
-	if err == redis.Nil {
-		return v, nil
-	}
-
-	return v, err
+	return v, nil
 }
 
 func (c ClientImpl) RedisGetInt(redisTag string, key string) (int, error) {
 
-	cli, err := c.GetClient(redisTag)
-	if nil != err {
-		return 0, err
-	}
-
-	v, err := cli.Do("GET", key).Int()
+	v, err := c.getValue(redisTag, key)
 	if err == redis.Nil {
 		return 0, nil
 	}
+	if nil != err {
+		return 0, err
+	}
 
-	return v, err
+	return strconv.Atoi(v)
 }
 
 func (c ClientImpl) RedisGetInt64(redisTag string, key string) (int64, error) {
 
-	cli, err := c.GetClient(redisTag)
-	if nil != err {
-		return 0, err
-	}
-
-	v, err := cli.Do("GET", key).Int64()
+	v, err := c.getValue(redisTag, key)
 	if err == redis.Nil {
 		return 0, nil
 	}
+	if nil != err {
+		return 0, err
+	}
 
-	return v, err
+	return strconv.ParseInt(v, 10, 64)
 }
 
 func (c ClientImpl) RedisGetUint64(redisTag string, key string) (uint64, error) {
 
-	cli, err := c.GetClient(redisTag)
-	if nil != err {
-		return 0, err
-	}
-
-	v, err := cli.Do("GET", key).Uint64()
+	v, err := c.getValue(redisTag, key)
 	if err == redis.Nil {
 		return 0, nil
 	}
+	if nil != err {
+		return 0, err
+	}
 
-	return v, err
+	return strconv.ParseUint(v, 10, 64)
 }
 
 func (c ClientImpl) RedisGetFloat64(redisTag string, key string) (float64, error) {
 
-	cli, err := c.GetClient(redisTag)
-	if nil != err {
-		return 0.0, err
-	}
-
-	v, err := cli.Do("GET", key).Float64()
+	v, err := c.getValue(redisTag, key)
 	if err == redis.Nil {
 		return 0.0, nil
 	}
+	if nil != err {
+		return 0.0, err
+	}
 
-	return v, err
+	return strconv.ParseFloat(v, 64)
 }
 
 func (c ClientImpl) RedisExpire(redisTag string, key string, expire int) error {
@@ -238,12 +456,11 @@ func (c ClientImpl) RedisExpire(redisTag string, key string, expire int) error {
 
 func (c ClientImpl) RedisPTTL(redisTag string, key string) (int, error) {
 
-	cli, err := c.GetClient(redisTag)
-	if nil != err {
-		return -1, err
-	}
-
-	ttl, err := cli.Do("PTTL", key).Int()
+	var ttl int
+	err := c.read(redisTag, func(cli *redis.Client) (err error) {
+		ttl, err = cli.Do("PTTL", key).Int()
+		return
+	})
 	if err != nil {
 		return -1, err
 	}
@@ -251,14 +468,30 @@ func (c ClientImpl) RedisPTTL(redisTag string, key string) (int, error) {
 	return ttl, nil
 }
 
-func (c ClientImpl) RedisTTL(redisTag string, key string) (int, error) {
+//set the expire of key in milliseconds, returns false if key does not exist
+func (c ClientImpl) RedisPExpire(redisTag string, key string, expire int) (bool, error) {
 
 	cli, err := c.GetClient(redisTag)
 	if nil != err {
-		return -1, err
+		return false, err
 	}
 
-	ttl, err := cli.Do("TTL", key).Int()
+	ok, err := cli.PExpire(key, time.Duration(expire)*time.Millisecond).Result()
+	if err != nil {
+		logrus.Error("RedisPExpire Error! key:", key, "Details:", err.Error())
+		return false, err
+	}
+
+	return ok, nil
+}
+
+func (c ClientImpl) RedisTTL(redisTag string, key string) (int, error) {
+
+	var ttl int
+	err := c.read(redisTag, func(cli *redis.Client) (err error) {
+		ttl, err = cli.Do("TTL", key).Int()
+		return
+	})
 	if err != nil {
 		return -1, err
 	}
@@ -283,12 +516,17 @@ func (c ClientImpl) RedisDel(redisTag string, key string) error {
 
 func (c ClientImpl) RedisHGet(redisTag, key, field string) (string, error) {
 
-	cli, err := c.GetClient(redisTag)
+	//missing tag is an error, read failures read as empty
+	_, err := c.GetClient(redisTag)
 	if nil != err {
 		return "", err
 	}
 
-	value, err := cli.Do("HGET", key, field).String()
+	var value string
+	err = c.read(redisTag, func(cli *redis.Client) (err error) {
+		value, err = cli.Do("HGET", key, field).String()
+		return
+	})
 	if err != nil {
 		logrus.Error("HGet Error! key:", key)
 	}
@@ -361,12 +599,10 @@ func (c ClientImpl) RedisZRank(redisTag, key, member string) (int, error) {
 
 func (c ClientImpl) RedisZRange(redisTag string, key string, start, stop int) (values []string, err error) {
 
-	cli, err := c.GetClient(redisTag)
-	if nil != err {
-		return []string{}, err
-	}
-
-	values, err = cli.ZRange(key, int64(start), int64(stop)).Result()
+	err = c.read(redisTag, func(cli *redis.Client) (err error) {
+		values, err = cli.ZRange(key, int64(start), int64(stop)).Result()
+		return
+	})
 	if err != nil {
 		logrus.Error("RedisZRange Error!", key, "start:", start, "stop:", stop, "Details:", err.Error())
 		return
@@ -377,12 +613,10 @@ func (c ClientImpl) RedisZRange(redisTag string, key string, start, stop int) (v
 
 func (c ClientImpl) RedisZRangeWithScores(redisTag string, key string, start, stop int) (values []redis.Z, err error) {
 
-	cli, err := c.GetClient(redisTag)
-	if nil != err {
-		return []redis.Z{}, err
-	}
-
-	values, err = cli.ZRangeWithScores(key, int64(start), int64(stop)).Result()
+	err = c.read(redisTag, func(cli *redis.Client) (err error) {
+		values, err = cli.ZRangeWithScores(key, int64(start), int64(stop)).Result()
+		return
+	})
 	if err != nil {
 		logrus.Error("RedisZRange Error!", key, "start:", start, "stop:", stop, "Details:", err.Error())
 		return
@@ -405,6 +639,82 @@ func (c ClientImpl) RedisZRem(redisTag, key, member string) error {
 	return err
 }
 
+func (c ClientImpl) RedisZScore(redisTag, key, member string) (score float64, exists bool, err error) {
+
+	cli, err := c.GetClient(redisTag)
+	if nil != err {
+		return 0, false, err
+	}
+
+	score, err = cli.ZScore(key, member).Result()
+	if err == redis.Nil {
+		return 0, false, nil
+	}
+
+	if err != nil {
+		logrus.Error("RedisZScore Error!", key, "member:", member, "Details:", err.Error())
+		return 0, false, err
+	}
+
+	return score, true, nil
+}
+
+//count members with score in [min, max], min and max accept -inf, +inf and ( for exclusive bounds
+func (c ClientImpl) RedisZCount(redisTag, key, min, max string) (int64, error) {
+
+	cli, err := c.GetClient(redisTag)
+	if nil != err {
+		return 0, err
+	}
+
+	n, err := cli.ZCount(key, min, max).Result()
+	if err != nil {
+		logrus.Error("RedisZCount Error!", key, "min:", min, "max:", max, "Details:", err.Error())
+		return 0, err
+	}
+
+	return n, nil
+}
+
+//members with score in [min, max] ordered by score, count <= 0 means no limit
+func (c ClientImpl) RedisZRangeByScoreWithScores(redisTag, key, min, max string, offset, count int64) (values []redis.Z, err error) {
+
+	cli, err := c.GetClient(redisTag)
+	if nil != err {
+		return []redis.Z{}, err
+	}
+
+	opt := redis.ZRangeBy{Min: min, Max: max}
+	if count > 0 {
+		opt.Offset = offset
+		opt.Count = count
+	}
+
+	values, err = cli.ZRangeByScoreWithScores(key, opt).Result()
+	if err != nil {
+		logrus.Error("RedisZRangeByScoreWithScores Error!", key, "min:", min, "max:", max, "Details:", err.Error())
+		return
+	}
+
+	return
+}
+
+func (c ClientImpl) RedisZRemRangeByScore(redisTag, key, min, max string) (int64, error) {
+
+	cli, err := c.GetClient(redisTag)
+	if nil != err {
+		return 0, err
+	}
+
+	n, err := cli.ZRemRangeByScore(key, min, max).Result()
+	if err != nil {
+		logrus.Error("RedisZRemRangeByScore Error!", key, "min:", min, "max:", max, "Details:", err.Error())
+		return 0, err
+	}
+
+	return n, nil
+}
+
 func (c ClientImpl) RedisRPUSH(redisTag string, key string, member string) (err error) {
 
 	cli, err := c.GetClient(redisTag)
@@ -459,12 +769,10 @@ func (c ClientImpl) RedisLLEN(redisTag string, key string) (value int64, err err
 
 func (c ClientImpl) RedisLRange(redisTag string, key string, start, stop int) (values []string, err error) {
 
-	cli, err := c.GetClient(redisTag)
-	if nil != err {
-		return []string{}, err
-	}
-
-	values, err = cli.LRange(key, int64(start), int64(stop)).Result()
+	err = c.read(redisTag, func(cli *redis.Client) (err error) {
+		values, err = cli.LRange(key, int64(start), int64(stop)).Result()
+		return
+	})
 	if err != nil {
 		logrus.Error("RedisLRange Error!", key, "start:", start, "stop:", stop, "Details:", err.Error())
 		return
@@ -523,7 +831,20 @@ func (c ClientImpl) RedisMset(redisTag string, pairs ...interface{}) error {
 		return err
 	}
 
-	err = cli.MSet(pairs...).Err()
+	encoded := make([]interface{}, len(pairs))
+	for i, value := range pairs {
+		if i%2 == 0 {
+			encoded[i] = value
+			continue
+		}
+		encoded[i], err = c.encodeValue(redisTag, value)
+		if nil != err {
+			logrus.Error("RedisMset Error! Details:", err.Error())
+			return err
+		}
+	}
+
+	err = cli.MSet(encoded...).Err()
 	if err != nil {
 		logrus.Error("RedisMset Error! pairs:", pairs, "Details:", err.Error())
 	}
@@ -531,6 +852,21 @@ func (c ClientImpl) RedisMset(redisTag string, pairs ...interface{}) error {
 	return err
 }
 
+func (c ClientImpl) RedisPublish(redisTag string, channel string, message interface{}) error {
+
+	cli, err := c.GetClient(redisTag)
+	if nil != err {
+		return err
+	}
+
+	err = cli.Publish(channel, message).Err()
+	if err != nil {
+		logrus.Error("RedisPublish Error! channel:", channel, "Details:", err.Error())
+	}
+
+	return err
+}
+
 func (c ClientImpl) getKeys(redisTag string, prefix string) ([]string, error) {
 
 	cli, err := c.GetClient(redisTag)
@@ -575,6 +911,10 @@ func (c ClientImpl) getKeyAndValuesMap(redisTag string, keys []string, prefix st
 			logrus.Error("error retrieving value for key ", key, "Details:", err.Error())
 
 		}
+		value, err = c.decodeValue(redisTag, value)
+		if err != nil {
+			logrus.Error("error decoding value for key ", key, "Details:", err.Error())
+		}
 
 		strippedKey := strings.Split(key, prefix)
 		values[strippedKey[1]] = value
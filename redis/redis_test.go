@@ -0,0 +1,299 @@
+/**
+ * @Author KYIMH
+ * @Description
+ * @Date 2026/10/20 03:20
+ **/
+
+package redis_test
+
+import (
+	"bytes"
+	"github.com/KYIMH/CCS_Utils/crypto"
+	"github.com/KYIMH/CCS_Utils/redis"
+	"github.com/KYIMH/CCS_Utils/redis/redistest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const tag = "ccs"
+
+func newClient(t *testing.T) (*redistest.Server, *redis.ClientImpl) {
+
+	srv, cli, err := redistest.NewClientImpl(tag)
+	if nil != err {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		cli.Close()
+		srv.Close()
+	})
+
+	return srv, cli
+}
+
+func TestClientImplDal(t *testing.T) {
+
+	_, cli := newClient(t)
+	var dal redis.Dal = cli
+
+	if err := dal.RedisSet(tag, "k", "v", 10); nil != err {
+		t.Fatal(err)
+	}
+	if v, err := dal.RedisGet(tag, "k"); nil != err || "v" != v {
+		t.Fatalf("RedisGet = %q, %v", v, err)
+	}
+	if ttl, err := dal.RedisTTL(tag, "k"); nil != err || ttl <= 0 || ttl > 10 {
+		t.Fatalf("RedisTTL = %d, %v", ttl, err)
+	}
+
+	if err := dal.RedisHSet(tag, "h", "f", "1"); nil != err {
+		t.Fatal(err)
+	}
+	if v, err := dal.RedisHGet(tag, "h", "f"); nil != err || "1" != v {
+		t.Fatalf("RedisHGet = %q, %v", v, err)
+	}
+
+	for member, score := range map[string]string{"a": "1", "b": "2"} {
+		if err := dal.RedisZAdd(tag, "z", member, score); nil != err {
+			t.Fatal(err)
+		}
+	}
+	if values, err := dal.RedisZRange(tag, "z", 0, -1); nil != err || strings.Join(values, ",") != "a,b" {
+		t.Fatalf("RedisZRange = %v, %v", values, err)
+	}
+
+	if err := dal.RedisRPUSH(tag, "l", "x"); nil != err {
+		t.Fatal(err)
+	}
+	if n, err := dal.RedisLLEN(tag, "l"); nil != err || 1 != n {
+		t.Fatalf("RedisLLEN = %d, %v", n, err)
+	}
+
+	if err := dal.RedisDel(tag, "k"); nil != err {
+		t.Fatal(err)
+	}
+	if ok, err := dal.RedisKeyExists(tag, "k"); nil != err || ok {
+		t.Fatalf("RedisKeyExists = %v, %v", ok, err)
+	}
+}
+
+func TestClientImplCounters(t *testing.T) {
+
+	_, cli := newClient(t)
+
+	for i, want := range []int64{2, 4, 6} {
+		v, err := cli.RedisIncrWithExpire(tag, "c", 2, 30000)
+		if nil != err || want != v {
+			t.Fatalf("RedisIncrWithExpire #%d = %d, %v", i, v, err)
+		}
+	}
+	//expire is in milliseconds like RedisSet
+	if ttl, err := cli.RedisPTTL(tag, "c"); nil != err || ttl <= 29000 || ttl > 30000 {
+		t.Fatalf("RedisPTTL = %d, %v", ttl, err)
+	}
+
+	v, capped, err := cli.RedisIncrCapped(tag, "cap", 6, 10, 0)
+	if nil != err || 6 != v || capped {
+		t.Fatalf("RedisIncrCapped = %d, %v, %v", v, capped, err)
+	}
+	v, capped, err = cli.RedisIncrCapped(tag, "cap", 6, 10, 0)
+	if nil != err || 6 != v || !capped {
+		t.Fatalf("RedisIncrCapped over cap = %d, %v, %v", v, capped, err)
+	}
+}
+
+func TestClientImplIncrCappedExpire(t *testing.T) {
+
+	_, cli := newClient(t)
+
+	//a refused first increment does not create the key
+	v, capped, err := cli.RedisIncrCapped(tag, "cap", 20, 10, 30000)
+	if nil != err || 0 != v || !capped {
+		t.Fatalf("RedisIncrCapped of an absent key over cap = %d, %v, %v", v, capped, err)
+	}
+	if ok, err := cli.RedisKeyExists(tag, "cap"); nil != err || ok {
+		t.Fatalf("RedisKeyExists after a refused increment = %v, %v", ok, err)
+	}
+
+	if _, _, err := cli.RedisIncrCapped(tag, "cap", 6, 10, 30000); nil != err {
+		t.Fatal(err)
+	}
+	if err := cli.RedisExpire(tag, "cap", 5); nil != err {
+		t.Fatal(err)
+	}
+
+	//a refused increment keeps the value and the window of the counter
+	v, capped, err = cli.RedisIncrCapped(tag, "cap", 6, 10, 30000)
+	if nil != err || 6 != v || !capped {
+		t.Fatalf("RedisIncrCapped over cap = %d, %v, %v", v, capped, err)
+	}
+	if ttl, err := cli.RedisTTL(tag, "cap"); nil != err || ttl <= 0 || ttl > 5 {
+		t.Fatalf("RedisTTL after a refused increment = %d, %v", ttl, err)
+	}
+}
+
+func TestClientImplCompression(t *testing.T) {
+
+	srv, cli := newClient(t)
+
+	err := cli.AddClient2Pool(redis.RedisConfig{
+		RedisTag:    "zip",
+		Addr:        srv.Addr(),
+		Compression: redis.Compression{Algorithm: redis.CompressGzip, Threshold: 16},
+	})
+	if nil != err {
+		t.Fatal(err)
+	}
+
+	value := strings.Repeat("compressible ", 100)
+	if err := cli.RedisSet("zip", "k", value, 0); nil != err {
+		t.Fatal(err)
+	}
+	if v, err := cli.RedisGet("zip", "k"); nil != err || value != v {
+		t.Fatalf("RedisGet of a compressed value = %d bytes, %v", len(v), err)
+	}
+
+	//both tags point at the same server, the stored bytes are the compressed form
+	raw, err := cli.GetClient(tag)
+	if nil != err {
+		t.Fatal(err)
+	}
+	stored, err := raw.Get("k").Result()
+	if nil != err || len(stored) >= len(value) {
+		t.Fatalf("stored value = %d bytes, %v", len(stored), err)
+	}
+}
+
+func TestClientImplCipher(t *testing.T) {
+
+	_, cli := newClient(t)
+
+	keys, err := crypto.NewStaticKeys("k1", map[string][]byte{"k1": bytes.Repeat([]byte{7}, 32)})
+	if nil != err {
+		t.Fatal(err)
+	}
+	cipher := crypto.NewCipher(keys)
+
+	if err := cli.RedisSet(tag, "legacy", "plain", 0); nil != err {
+		t.Fatal(err)
+	}
+	if err := cli.SetCipher(tag, cipher); nil != err {
+		t.Fatal(err)
+	}
+	if err := cli.RedisSet(tag, "k", "secret", 0); nil != err {
+		t.Fatal(err)
+	}
+	if v, err := cli.RedisGet(tag, "k"); nil != err || "secret" != v {
+		t.Fatalf("RedisGet of a sealed value = %q, %v", v, err)
+	}
+	if v, err := cli.RedisGet(tag, "legacy"); nil != err || "plain" != v {
+		t.Fatalf("RedisGet of legacy plaintext = %q, %v", v, err)
+	}
+
+	raw, err := cli.GetClient(tag)
+	if nil != err {
+		t.Fatal(err)
+	}
+	stored, err := raw.Get("k").Bytes()
+	if nil != err || !cipher.IsEncrypted(stored) {
+		t.Fatalf("stored value = %q, %v", stored, err)
+	}
+}
+
+func TestShardedAddShardWhileWriting(t *testing.T) {
+
+	_, cli := newClient(t)
+	srv, err := redistest.Run()
+	if nil != err {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Close)
+	if err := cli.AddClient2Pool(redis.RedisConfig{RedisTag: "b", Addr: srv.Addr()}); nil != err {
+		t.Fatal(err)
+	}
+
+	const keys, writers, rounds = 300, 4, 5
+	sharded := redis.NewSharded(cli, []string{tag}, 0)
+	for i := 0; i < keys; i++ {
+		if err := sharded.RedisSet("", "k"+strconv.Itoa(i), 0, 0); nil != err {
+			t.Fatal(err)
+		}
+	}
+
+	//increments racing the migration must all land on the shard the key ends up on
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for r := 0; r < rounds; r++ {
+				for i := 0; i < keys; i++ {
+					if _, err := sharded.RedisIncr("", "k"+strconv.Itoa(i)); nil != err {
+						t.Error(err)
+						return
+					}
+				}
+			}
+		}()
+	}
+	copied, err := sharded.AddShard("b")
+	wg.Wait()
+	if nil != err || 0 == copied {
+		t.Fatalf("AddShard = %d, %v", copied, err)
+	}
+
+	moved := 0
+	for i := 0; i < keys; i++ {
+		key := "k" + strconv.Itoa(i)
+		if v, err := sharded.RedisGetInt(tag, key); nil != err || writers*rounds != v {
+			t.Fatalf("%s = %d, %v", key, v, err)
+		}
+		if "b" == sharded.ShardOf(key) {
+			moved++
+		}
+	}
+	if 0 == moved {
+		t.Fatal("no key moved to the new shard")
+	}
+
+	if purged, err := sharded.PurgeMoved(); nil != err || moved != purged {
+		t.Fatalf("PurgeMoved = %d, %v, want %d", purged, err, moved)
+	}
+}
+
+func TestMigrateSameServer(t *testing.T) {
+
+	srv, cli := newClient(t)
+	other, err := redistest.Run()
+	if nil != err {
+		t.Fatal(err)
+	}
+	t.Cleanup(other.Close)
+
+	//another name of the same server
+	alias := strings.Replace(srv.Addr(), "127.0.0.1", "localhost", 1)
+	for _, config := range []redis.RedisConfig{
+		{RedisTag: "alias", Addr: alias},
+		{RedisTag: "db1", Addr: srv.Addr(), DB: 1},
+		{RedisTag: "other", Addr: other.Addr()},
+	} {
+		if err := cli.AddClient2Pool(config); nil != err {
+			t.Fatal(err)
+		}
+	}
+	if err := cli.RedisSet(tag, "k", "v", 0); nil != err {
+		t.Fatal(err)
+	}
+
+	options := redis.MigrateOptions{DeleteSource: true}
+	if _, err := redis.Migrate(cli, tag, "alias", options); err != redis.ErrSameServer {
+		t.Fatalf("Migrate onto an alias of the source = %v", err)
+	}
+	for _, dest := range []string{"db1", "other"} {
+		if result, err := redis.Migrate(cli, tag, dest, redis.MigrateOptions{}); nil != err || 1 != result.Copied {
+			t.Fatalf("Migrate to %s = %+v, %v", dest, result, err)
+		}
+	}
+}
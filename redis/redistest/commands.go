@@ -0,0 +1,1526 @@
+/**
+ * @Author KYIMH
+ * @Description
+ * @Date 2026/10/19 15:02
+ **/
+
+package redistest
+
+import (
+	"encoding/json"
+	"github.com/KYIMH/CCS_Utils/fakes"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	msgWrongType  = "WRONGTYPE Operation against a key holding the wrong kind of value"
+	msgNotInteger = "ERR value is not an integer or out of range"
+	msgNotFloat   = "ERR value is not a valid float"
+	msgSyntax     = "ERR syntax error"
+)
+
+//command -> handler of one redis command
+//arity: exact number of args including the name, negative means at least -arity
+//blocking: handler takes mu by itself, used by blocking pops
+//pubsub: allowed while the connection subscribes to channels
+type command struct {
+	fn       func(s *Server, c *client, w *respWriter, args []string)
+	arity    int
+	blocking bool
+	pubsub   bool
+}
+
+var commands = map[string]command{
+	//connection
+	"PING":   {fn: cmdPing, arity: -1, pubsub: true},
+	"ECHO":   {fn: cmdEcho, arity: 2},
+	"SELECT": {fn: cmdSelect, arity: 2},
+
+	//server
+	"FLUSHDB":  {fn: cmdFlushDB, arity: -1},
+	"FLUSHALL": {fn: cmdFlushAll, arity: -1},
+	"DBSIZE":   {fn: cmdDBSize, arity: 1},
+	"INFO":     {fn: cmdInfo, arity: -1},
+	"CONFIG":   {fn: cmdConfig, arity: -2},
+	"MEMORY":   {fn: cmdMemory, arity: -2},
+
+	//keys
+	"DEL":     {fn: cmdDel, arity: -2},
+	"EXISTS":  {fn: cmdExists, arity: -2},
+	"EXPIRE":  {fn: cmdExpire, arity: 3},
+	"PEXPIRE": {fn: cmdExpire, arity: 3},
+	"PERSIST": {fn: cmdPersist, arity: 2},
+	"TTL":     {fn: cmdTTL, arity: 2},
+	"PTTL":    {fn: cmdTTL, arity: 2},
+	"TYPE":    {fn: cmdType, arity: 2},
+	"KEYS":    {fn: cmdKeys, arity: 2},
+	"SCAN":    {fn: cmdScan, arity: -2},
+	"DUMP":    {fn: cmdDump, arity: 2},
+	"RESTORE": {fn: cmdRestore, arity: -4},
+
+	//strings
+	"SET":    {fn: cmdSet, arity: -3},
+	"SETNX":  {fn: cmdSetNX, arity: 3},
+	"GET":    {fn: cmdGet, arity: 2},
+	"STRLEN": {fn: cmdStrLen, arity: 2},
+	"MGET":   {fn: cmdMGet, arity: -2},
+	"MSET":   {fn: cmdMSet, arity: -3},
+
+	//bits
+	"SETBIT": {fn: cmdSetBit, arity: 4},
+	"GETBIT": {fn: cmdGetBit, arity: 3},
+
+	//counters
+	"INCR":        {fn: cmdIncr, arity: 2},
+	"DECR":        {fn: cmdIncr, arity: 2},
+	"INCRBY":      {fn: cmdIncr, arity: 3},
+	"DECRBY":      {fn: cmdIncr, arity: 3},
+	"INCRBYFLOAT": {fn: cmdIncrByFloat, arity: 3},
+
+	//hashes
+	"HSET":    {fn: cmdHSet, arity: -4},
+	"HMSET":   {fn: cmdHSet, arity: -4},
+	"HGET":    {fn: cmdHGet, arity: 3},
+	"HDEL":    {fn: cmdHDel, arity: -3},
+	"HEXISTS": {fn: cmdHExists, arity: 3},
+	"HLEN":    {fn: cmdHLen, arity: 2},
+	"HGETALL": {fn: cmdHGetAll, arity: 2},
+
+	//lists
+	"LPUSH":  {fn: cmdPush, arity: -3},
+	"RPUSH":  {fn: cmdPush, arity: -3},
+	"LPOP":   {fn: cmdPop, arity: 2},
+	"RPOP":   {fn: cmdPop, arity: 2},
+	"BLPOP":  {fn: cmdBPop, arity: -3, blocking: true},
+	"BRPOP":  {fn: cmdBPop, arity: -3, blocking: true},
+	"LLEN":   {fn: cmdLLen, arity: 2},
+	"LRANGE": {fn: cmdLRange, arity: 4},
+
+	//sorted sets
+	"ZADD":   {fn: cmdZAdd, arity: -4},
+	"ZRANK":  {fn: cmdZRank, arity: 3},
+	"ZSCORE": {fn: cmdZScore, arity: 3},
+	"ZCARD":  {fn: cmdZCard, arity: 2},
+	"ZRANGE": {fn: cmdZRange, arity: -4},
+	"ZREM":   {fn: cmdZRem, arity: -3},
+
+	"ZCOUNT":           {fn: cmdZCount, arity: 4},
+	"ZRANGEBYSCORE":    {fn: cmdZRangeByScore, arity: -4},
+	"ZREMRANGEBYSCORE": {fn: cmdZRemRangeByScore, arity: 4},
+
+	//pub/sub
+	"PUBLISH":      {fn: cmdPublish, arity: 3},
+	"SUBSCRIBE":    {fn: cmdSubscribe, arity: -2, pubsub: true},
+	"PSUBSCRIBE":   {fn: cmdSubscribe, arity: -2, pubsub: true},
+	"UNSUBSCRIBE":  {fn: cmdUnsubscribe, arity: -1, pubsub: true},
+	"PUNSUBSCRIBE": {fn: cmdUnsubscribe, arity: -1, pubsub: true},
+}
+
+func cmdPing(s *Server, c *client, w *respWriter, args []string) {
+
+	//subscribed connections get the pong as a pub/sub message
+	if c.subscriptions() > 0 {
+		payload := ""
+		if len(args) > 1 {
+			payload = args[1]
+		}
+		w.strings([]string{"pong", payload})
+		return
+	}
+
+	if len(args) > 1 {
+		w.bulk(args[1])
+		return
+	}
+	w.status("PONG")
+}
+
+func cmdEcho(s *Server, c *client, w *respWriter, args []string) {
+
+	w.bulk(args[1])
+}
+
+func cmdSelect(s *Server, c *client, w *respWriter, args []string) {
+
+	index, err := strconv.Atoi(args[1])
+	if nil != err || index < 0 {
+		w.error("ERR DB index is out of range")
+		return
+	}
+
+	c.db = index
+	w.status("OK")
+}
+
+func cmdFlushDB(s *Server, c *client, w *respWriter, args []string) {
+
+	delete(s.dbs, c.db)
+	w.status("OK")
+}
+
+func cmdFlushAll(s *Server, c *client, w *respWriter, args []string) {
+
+	s.dbs = make(map[int]*database)
+	w.status("OK")
+}
+
+func cmdDBSize(s *Server, c *client, w *respWriter, args []string) {
+
+	w.int(int64(len(s.liveKeys(c))))
+}
+
+//INFO [section], only the server section with redis_version and run_id
+func cmdInfo(s *Server, c *client, w *respWriter, args []string) {
+
+	w.bulk("# Server\r\nredis_version:5.0.0\r\nrun_id:" + s.runId + "\r\n")
+}
+
+//CONFIG GET / SET, only notify-keyspace-events is kept, other parameters read as unset
+func cmdConfig(s *Server, c *client, w *respWriter, args []string) {
+
+	switch strings.ToUpper(args[1]) {
+	case "GET":
+		if len(args) != 3 {
+			w.error(msgSyntax)
+			return
+		}
+		if fakes.MatchPattern(strings.ToLower(args[2]), "notify-keyspace-events") {
+			w.strings([]string{"notify-keyspace-events", s.notifyFlags})
+			return
+		}
+		w.arrayLen(0)
+	case "SET":
+		if len(args) != 4 {
+			w.error(msgSyntax)
+			return
+		}
+		if "notify-keyspace-events" == strings.ToLower(args[2]) {
+			s.notifyFlags = args[3]
+		}
+		w.status("OK")
+	default:
+		w.error("ERR unknown subcommand '" + args[1] + "'")
+	}
+}
+
+//MEMORY USAGE key [SAMPLES count], an estimate from key and payload sizes
+func cmdMemory(s *Server, c *client, w *respWriter, args []string) {
+
+	if "USAGE" != strings.ToUpper(args[1]) || len(args) < 3 {
+		w.error("ERR unknown subcommand '" + args[1] + "'")
+		return
+	}
+
+	e := s.lookup(c, args[2])
+	if nil == e {
+		w.nilBulk()
+		return
+	}
+
+	//dictEntry, robj and sds headers of the key
+	size := 56 + len(args[2])
+	switch e.kind {
+	case kindString:
+		size += len(e.str)
+	case kindHash:
+		for field, value := range e.hash {
+			size += 24 + len(field) + len(value)
+		}
+	case kindList:
+		for _, value := range e.list {
+			size += 11 + len(value)
+		}
+	case kindZSet:
+		for member := range e.zset {
+			size += 48 + len(member)
+		}
+	}
+
+	w.int(int64(size))
+}
+
+func cmdDel(s *Server, c *client, w *respWriter, args []string) {
+
+	var n int64
+	for _, key := range args[1:] {
+		if nil != s.lookup(c, key) {
+			delete(s.db(c.db).data, key)
+			s.notify(c.db, "del", 'g', key)
+			n++
+		}
+	}
+
+	w.int(n)
+}
+
+func cmdExists(s *Server, c *client, w *respWriter, args []string) {
+
+	var n int64
+	for _, key := range args[1:] {
+		if nil != s.lookup(c, key) {
+			n++
+		}
+	}
+
+	w.int(n)
+}
+
+//EXPIRE in seconds, PEXPIRE in milliseconds
+func cmdExpire(s *Server, c *client, w *respWriter, args []string) {
+
+	ttl, err := strconv.ParseInt(args[2], 10, 64)
+	if nil != err {
+		w.error(msgNotInteger)
+		return
+	}
+
+	e := s.lookup(c, args[1])
+	if nil == e {
+		w.int(0)
+		return
+	}
+
+	unit := time.Second
+	if "PEXPIRE" == strings.ToUpper(args[0]) {
+		unit = time.Millisecond
+	}
+
+	if ttl <= 0 {
+		delete(s.db(c.db).data, args[1])
+		s.notify(c.db, "del", 'g', args[1])
+	} else {
+		e.expireAt = s.now().Add(time.Duration(ttl) * unit)
+	}
+
+	w.int(1)
+}
+
+func cmdPersist(s *Server, c *client, w *respWriter, args []string) {
+
+	e := s.lookup(c, args[1])
+	if nil == e || e.expireAt.IsZero() {
+		w.int(0)
+		return
+	}
+
+	e.expireAt = time.Time{}
+	w.int(1)
+}
+
+//TTL in seconds, PTTL in milliseconds, -2 if key does not exist, -1 if key has no expire
+func cmdTTL(s *Server, c *client, w *respWriter, args []string) {
+
+	e := s.lookup(c, args[1])
+	if nil == e {
+		w.int(-2)
+		return
+	}
+	if e.expireAt.IsZero() {
+		w.int(-1)
+		return
+	}
+
+	unit := time.Second
+	if "PTTL" == strings.ToUpper(args[0]) {
+		unit = time.Millisecond
+	}
+
+	left := e.expireAt.Sub(s.now())
+	w.int(int64((left + unit - 1) / unit))
+}
+
+func cmdType(s *Server, c *client, w *respWriter, args []string) {
+
+	e := s.lookup(c, args[1])
+	if nil == e {
+		w.status("none")
+		return
+	}
+
+	w.status(kindName(e.kind))
+}
+
+func kindName(kind valueKind) string {
+
+	switch kind {
+	case kindHash:
+		return "hash"
+	case kindList:
+		return "list"
+	case kindZSet:
+		return "zset"
+	}
+
+	return "string"
+}
+
+//sorted names of live keys, caller holds mu
+func (s *Server) liveKeys(c *client) []string {
+
+	keys := make([]string, 0, len(s.db(c.db).data))
+	for key := range s.db(c.db).data {
+		if nil != s.lookup(c, key) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+func cmdKeys(s *Server, c *client, w *respWriter, args []string) {
+
+	keys := []string{}
+	for _, key := range s.liveKeys(c) {
+		if fakes.MatchPattern(args[1], key) {
+			keys = append(keys, key)
+		}
+	}
+
+	w.strings(keys)
+}
+
+//SCAN cursor [MATCH pattern] [COUNT count] [TYPE type]
+//the cursor names the last returned key of the sorted key list, so keys deleted during a scan
+//never shift the rest away, keys added during a scan may be missed like in redis
+func cmdScan(s *Server, c *client, w *respWriter, args []string) {
+
+	cursor, err := strconv.Atoi(args[1])
+	after, known := s.scanCursors[cursor]
+	if nil != err || (cursor != 0 && !known) {
+		w.error("ERR invalid cursor")
+		return
+	}
+
+	pattern, count, typ := "*", 10, ""
+	for i := 2; i < len(args); i += 2 {
+		if i+1 >= len(args) {
+			w.error(msgSyntax)
+			return
+		}
+		switch strings.ToUpper(args[i]) {
+		case "MATCH":
+			pattern = args[i+1]
+		case "COUNT":
+			count, err = strconv.Atoi(args[i+1])
+			if nil != err || count < 1 {
+				w.error(msgSyntax)
+				return
+			}
+		case "TYPE":
+			typ = strings.ToLower(args[i+1])
+		default:
+			w.error(msgSyntax)
+			return
+		}
+	}
+
+	all := s.liveKeys(c)
+	from := 0
+	if cursor != 0 {
+		from = sort.SearchStrings(all, after)
+		if from < len(all) && all[from] == after {
+			from++
+		}
+	}
+	end := from + count
+	if end >= len(all) {
+		end = len(all)
+	}
+
+	keys := []string{}
+	if from < len(all) {
+		for _, key := range all[from:end] {
+			if !fakes.MatchPattern(pattern, key) {
+				continue
+			}
+			if "" != typ && kindName(s.lookup(c, key).kind) != typ {
+				continue
+			}
+			keys = append(keys, key)
+		}
+	}
+
+	next := 0
+	if end < len(all) {
+		s.scanSeq++
+		next = s.scanSeq
+		s.scanCursors[next] = all[end-1]
+	}
+
+	w.arrayLen(2)
+	w.bulk(strconv.Itoa(next))
+	w.strings(keys)
+}
+
+//dumpPayload -> DUMP serialization, opaque to clients like the redis RDB format
+type dumpPayload struct {
+	Kind valueKind          `json:"k"`
+	Str  string             `json:"s,omitempty"`
+	Hash map[string]string  `json:"h,omitempty"`
+	List []string           `json:"l,omitempty"`
+	ZSet map[string]float64 `json:"z,omitempty"`
+}
+
+func cmdDump(s *Server, c *client, w *respWriter, args []string) {
+
+	e := s.lookup(c, args[1])
+	if nil == e {
+		w.nilBulk()
+		return
+	}
+
+	payload, err := json.Marshal(dumpPayload{Kind: e.kind, Str: e.str, Hash: e.hash, List: e.list, ZSet: e.zset})
+	if nil != err {
+		w.error("ERR " + err.Error())
+		return
+	}
+
+	w.bulk(string(payload))
+}
+
+//RESTORE key ttl payload [REPLACE] [ABSTTL], ttl in milliseconds, 0 means no expire
+func cmdRestore(s *Server, c *client, w *respWriter, args []string) {
+
+	ttl, err := strconv.ParseInt(args[2], 10, 64)
+	if nil != err || ttl < 0 {
+		w.error("ERR Invalid TTL value, must be >= 0")
+		return
+	}
+
+	replace, absTTL := false, false
+	for _, arg := range args[4:] {
+		switch strings.ToUpper(arg) {
+		case "REPLACE":
+			replace = true
+		case "ABSTTL":
+			absTTL = true
+		default:
+			w.error(msgSyntax)
+			return
+		}
+	}
+
+	var payload dumpPayload
+	err = json.Unmarshal([]byte(args[3]), &payload)
+	if nil != err {
+		w.error("ERR DUMP payload version or checksum are wrong")
+		return
+	}
+
+	if !replace && nil != s.lookup(c, args[1]) {
+		w.error("BUSYKEY Target key name already exists.")
+		return
+	}
+
+	e := &entry{kind: payload.Kind, str: payload.Str, hash: payload.Hash, list: payload.List, zset: payload.ZSet}
+	if e.kind == kindHash && nil == e.hash {
+		e.hash = make(map[string]string)
+	}
+	if e.kind == kindZSet && nil == e.zset {
+		e.zset = make(map[string]float64)
+	}
+	if ttl > 0 {
+		if absTTL {
+			e.expireAt = time.Unix(0, ttl*int64(time.Millisecond))
+		} else {
+			e.expireAt = s.now().Add(time.Duration(ttl) * time.Millisecond)
+		}
+	}
+	s.db(c.db).data[args[1]] = e
+	if e.kind == kindList {
+		s.notifyPush()
+	}
+
+	w.status("OK")
+}
+
+//SET key value [EX seconds|PX milliseconds] [NX|XX] [KEEPTTL]
+func cmdSet(s *Server, c *client, w *respWriter, args []string) {
+
+	var ttl time.Duration
+	var nx, xx, keepTTL bool
+
+	for i := 3; i < len(args); i++ {
+		switch strings.ToUpper(args[i]) {
+		case "EX", "PX":
+			if i+1 >= len(args) {
+				w.error(msgSyntax)
+				return
+			}
+			n, err := strconv.ParseInt(args[i+1], 10, 64)
+			if nil != err || n <= 0 {
+				w.error("ERR invalid expire time in set")
+				return
+			}
+			unit := time.Second
+			if "PX" == strings.ToUpper(args[i]) {
+				unit = time.Millisecond
+			}
+			ttl = time.Duration(n) * unit
+			i++
+		case "NX":
+			nx = true
+		case "XX":
+			xx = true
+		case "KEEPTTL":
+			keepTTL = true
+		default:
+			w.error(msgSyntax)
+			return
+		}
+	}
+
+	old := s.lookup(c, args[1])
+	if (nx && nil != old) || (xx && nil == old) {
+		w.nilBulk()
+		return
+	}
+
+	e := &entry{kind: kindString, str: args[2]}
+	if ttl > 0 {
+		e.expireAt = s.now().Add(ttl)
+	} else if keepTTL && nil != old {
+		e.expireAt = old.expireAt
+	}
+	s.db(c.db).data[args[1]] = e
+	s.notify(c.db, "set", '$', args[1])
+
+	w.status("OK")
+}
+
+func cmdSetNX(s *Server, c *client, w *respWriter, args []string) {
+
+	if nil != s.lookup(c, args[1]) {
+		w.int(0)
+		return
+	}
+
+	s.db(c.db).data[args[1]] = &entry{kind: kindString, str: args[2]}
+	s.notify(c.db, "set", '$', args[1])
+	w.int(1)
+}
+
+func cmdGet(s *Server, c *client, w *respWriter, args []string) {
+
+	e := s.lookup(c, args[1])
+	if nil == e {
+		w.nilBulk()
+		return
+	}
+	if e.kind != kindString {
+		w.error(msgWrongType)
+		return
+	}
+
+	w.bulk(e.str)
+}
+
+func cmdStrLen(s *Server, c *client, w *respWriter, args []string) {
+
+	e := s.lookup(c, args[1])
+	if nil == e {
+		w.int(0)
+		return
+	}
+	if e.kind != kindString {
+		w.error(msgWrongType)
+		return
+	}
+
+	w.int(int64(len(e.str)))
+}
+
+func cmdMGet(s *Server, c *client, w *respWriter, args []string) {
+
+	w.arrayLen(len(args) - 1)
+	for _, key := range args[1:] {
+		e := s.lookup(c, key)
+		if nil == e || e.kind != kindString {
+			w.nilBulk()
+			continue
+		}
+		w.bulk(e.str)
+	}
+}
+
+func cmdMSet(s *Server, c *client, w *respWriter, args []string) {
+
+	if len(args)%2 != 1 {
+		w.error("ERR wrong number of arguments for 'mset' command")
+		return
+	}
+
+	for i := 1; i < len(args); i += 2 {
+		s.db(c.db).data[args[i]] = &entry{kind: kindString, str: args[i+1]}
+		s.notify(c.db, "set", '$', args[i])
+	}
+
+	w.status("OK")
+}
+
+//bit offset of SETBIT / GETBIT, redis caps strings at 512MB
+func bitOffset(arg string) (int, bool) {
+
+	offset, err := strconv.ParseInt(arg, 10, 64)
+	if nil != err || offset < 0 || offset >= 4<<30 {
+		return 0, false
+	}
+
+	return int(offset), true
+}
+
+//SETBIT key offset 0|1, bit 0 is the most significant bit of the first byte, returns the old bit
+func cmdSetBit(s *Server, c *client, w *respWriter, args []string) {
+
+	offset, ok := bitOffset(args[2])
+	if !ok {
+		w.error("ERR bit offset is not an integer or out of range")
+		return
+	}
+	if "0" != args[3] && "1" != args[3] {
+		w.error("ERR bit is not an integer or out of range")
+		return
+	}
+
+	e := s.lookupOrCreate(c, args[1], kindString)
+	if nil == e {
+		w.error(msgWrongType)
+		return
+	}
+
+	buf := []byte(e.str)
+	index := offset / 8
+	if index >= len(buf) {
+		buf = append(buf, make([]byte, index-len(buf)+1)...)
+	}
+	mask := byte(1) << uint(7-offset%8)
+
+	var old int64
+	if buf[index]&mask != 0 {
+		old = 1
+	}
+	if "1" == args[3] {
+		buf[index] |= mask
+	} else {
+		buf[index] &^= mask
+	}
+	e.str = string(buf)
+	s.notify(c.db, "setbit", '$', args[1])
+
+	w.int(old)
+}
+
+//GETBIT key offset, bits past the end of the string are 0
+func cmdGetBit(s *Server, c *client, w *respWriter, args []string) {
+
+	offset, ok := bitOffset(args[2])
+	if !ok {
+		w.error("ERR bit offset is not an integer or out of range")
+		return
+	}
+
+	e := s.lookup(c, args[1])
+	if nil == e {
+		w.int(0)
+		return
+	}
+	if e.kind != kindString {
+		w.error(msgWrongType)
+		return
+	}
+
+	index := offset / 8
+	if index >= len(e.str) || e.str[index]&(byte(1)<<uint(7-offset%8)) == 0 {
+		w.int(0)
+		return
+	}
+
+	w.int(1)
+}
+
+//INCR / DECR key, INCRBY / DECRBY key delta
+func cmdIncr(s *Server, c *client, w *respWriter, args []string) {
+
+	name := strings.ToUpper(args[0])
+
+	delta := int64(1)
+	if len(args) == 3 {
+		var err error
+		delta, err = strconv.ParseInt(args[2], 10, 64)
+		if nil != err {
+			w.error(msgNotInteger)
+			return
+		}
+	}
+	if strings.HasPrefix(name, "DECR") {
+		delta = -delta
+	}
+
+	e := s.lookupOrCreate(c, args[1], kindString)
+	if nil == e {
+		w.error(msgWrongType)
+		return
+	}
+
+	var cur int64
+	if "" != e.str {
+		var err error
+		cur, err = strconv.ParseInt(e.str, 10, 64)
+		if nil != err {
+			w.error(msgNotInteger)
+			return
+		}
+	}
+
+	cur += delta
+	e.str = strconv.FormatInt(cur, 10)
+
+	w.int(cur)
+}
+
+func cmdIncrByFloat(s *Server, c *client, w *respWriter, args []string) {
+
+	delta, err := strconv.ParseFloat(args[2], 64)
+	if nil != err {
+		w.error(msgNotFloat)
+		return
+	}
+
+	e := s.lookupOrCreate(c, args[1], kindString)
+	if nil == e {
+		w.error(msgWrongType)
+		return
+	}
+
+	var cur float64
+	if "" != e.str {
+		cur, err = strconv.ParseFloat(e.str, 64)
+		if nil != err {
+			w.error(msgNotFloat)
+			return
+		}
+	}
+
+	cur += delta
+	e.str = strconv.FormatFloat(cur, 'f', -1, 64)
+
+	w.bulk(e.str)
+}
+
+//HSET / HMSET key field value [field value ...]
+func cmdHSet(s *Server, c *client, w *respWriter, args []string) {
+
+	if len(args)%2 != 0 {
+		w.error("ERR wrong number of arguments for 'hset' command")
+		return
+	}
+
+	e := s.lookupOrCreate(c, args[1], kindHash)
+	if nil == e {
+		w.error(msgWrongType)
+		return
+	}
+
+	var added int64
+	for i := 2; i < len(args); i += 2 {
+		if _, ok := e.hash[args[i]]; !ok {
+			added++
+		}
+		e.hash[args[i]] = args[i+1]
+	}
+
+	if "HMSET" == strings.ToUpper(args[0]) {
+		w.status("OK")
+		return
+	}
+	w.int(added)
+}
+
+func cmdHGet(s *Server, c *client, w *respWriter, args []string) {
+
+	e := s.lookup(c, args[1])
+	if nil == e {
+		w.nilBulk()
+		return
+	}
+	if e.kind != kindHash {
+		w.error(msgWrongType)
+		return
+	}
+
+	value, ok := e.hash[args[2]]
+	if !ok {
+		w.nilBulk()
+		return
+	}
+
+	w.bulk(value)
+}
+
+func cmdHDel(s *Server, c *client, w *respWriter, args []string) {
+
+	e := s.lookup(c, args[1])
+	if nil == e {
+		w.int(0)
+		return
+	}
+	if e.kind != kindHash {
+		w.error(msgWrongType)
+		return
+	}
+
+	var n int64
+	for _, field := range args[2:] {
+		if _, ok := e.hash[field]; ok {
+			delete(e.hash, field)
+			n++
+		}
+	}
+	s.dropIfEmpty(c, args[1], e)
+
+	w.int(n)
+}
+
+func cmdHExists(s *Server, c *client, w *respWriter, args []string) {
+
+	e := s.lookup(c, args[1])
+	if nil != e && e.kind != kindHash {
+		w.error(msgWrongType)
+		return
+	}
+
+	if nil != e {
+		if _, ok := e.hash[args[2]]; ok {
+			w.int(1)
+			return
+		}
+	}
+
+	w.int(0)
+}
+
+func cmdHLen(s *Server, c *client, w *respWriter, args []string) {
+
+	e := s.lookup(c, args[1])
+	if nil == e {
+		w.int(0)
+		return
+	}
+	if e.kind != kindHash {
+		w.error(msgWrongType)
+		return
+	}
+
+	w.int(int64(len(e.hash)))
+}
+
+func cmdHGetAll(s *Server, c *client, w *respWriter, args []string) {
+
+	e := s.lookup(c, args[1])
+	if nil == e {
+		w.arrayLen(0)
+		return
+	}
+	if e.kind != kindHash {
+		w.error(msgWrongType)
+		return
+	}
+
+	fields := make([]string, 0, len(e.hash))
+	for field := range e.hash {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	w.arrayLen(len(fields) * 2)
+	for _, field := range fields {
+		w.bulk(field)
+		w.bulk(e.hash[field])
+	}
+}
+
+//LPUSH / RPUSH key element [element ...]
+func cmdPush(s *Server, c *client, w *respWriter, args []string) {
+
+	e := s.lookupOrCreate(c, args[1], kindList)
+	if nil == e {
+		w.error(msgWrongType)
+		return
+	}
+
+	for _, value := range args[2:] {
+		if "LPUSH" == strings.ToUpper(args[0]) {
+			e.list = append([]string{value}, e.list...)
+		} else {
+			e.list = append(e.list, value)
+		}
+	}
+	s.notifyPush()
+
+	w.int(int64(len(e.list)))
+}
+
+//pop the head (left) or tail of key, ok is false when key is empty, caller holds mu
+func (s *Server) pop(c *client, key string, left bool) (value string, ok bool, wrongType bool) {
+
+	e := s.lookup(c, key)
+	if nil == e {
+		return "", false, false
+	}
+	if e.kind != kindList {
+		return "", false, true
+	}
+
+	if left {
+		value = e.list[0]
+		e.list = e.list[1:]
+	} else {
+		value = e.list[len(e.list)-1]
+		e.list = e.list[:len(e.list)-1]
+	}
+	s.dropIfEmpty(c, key, e)
+
+	return value, true, false
+}
+
+func cmdPop(s *Server, c *client, w *respWriter, args []string) {
+
+	value, ok, wrongType := s.pop(c, args[1], "LPOP" == strings.ToUpper(args[0]))
+	if wrongType {
+		w.error(msgWrongType)
+		return
+	}
+	if !ok {
+		w.nilBulk()
+		return
+	}
+
+	w.bulk(value)
+}
+
+//BLPOP / BRPOP key [key ...] timeout, timeout in seconds, 0 blocks forever
+func cmdBPop(s *Server, c *client, w *respWriter, args []string) {
+
+	seconds, err := strconv.ParseFloat(args[len(args)-1], 64)
+	if nil != err || seconds < 0 {
+		w.error("ERR timeout is not a float or out of range")
+		return
+	}
+
+	keys := args[1 : len(args)-1]
+	left := "BLPOP" == strings.ToUpper(args[0])
+
+	var deadline <-chan time.Time
+	if seconds > 0 {
+		timer := time.NewTimer(time.Duration(seconds * float64(time.Second)))
+		defer timer.Stop()
+		deadline = timer.C
+	}
+
+	for {
+		s.mu.Lock()
+		for _, key := range keys {
+			value, ok, wrongType := s.pop(c, key, left)
+			if wrongType {
+				s.mu.Unlock()
+				w.error(msgWrongType)
+				return
+			}
+			if ok {
+				s.mu.Unlock()
+				w.strings([]string{key, value})
+				return
+			}
+		}
+		pushed := s.pushed
+		s.mu.Unlock()
+
+		select {
+		case <-pushed:
+		case <-deadline:
+			w.nilArray()
+			return
+		case <-s.closed:
+			w.nilArray()
+			return
+		}
+	}
+}
+
+func cmdLLen(s *Server, c *client, w *respWriter, args []string) {
+
+	e := s.lookup(c, args[1])
+	if nil == e {
+		w.int(0)
+		return
+	}
+	if e.kind != kindList {
+		w.error(msgWrongType)
+		return
+	}
+
+	w.int(int64(len(e.list)))
+}
+
+func cmdLRange(s *Server, c *client, w *respWriter, args []string) {
+
+	start, err1 := strconv.Atoi(args[2])
+	stop, err2 := strconv.Atoi(args[3])
+	if nil != err1 || nil != err2 {
+		w.error(msgNotInteger)
+		return
+	}
+
+	e := s.lookup(c, args[1])
+	if nil == e {
+		w.arrayLen(0)
+		return
+	}
+	if e.kind != kindList {
+		w.error(msgWrongType)
+		return
+	}
+
+	from, to, ok := rangeIndex(len(e.list), start, stop)
+	if !ok {
+		w.arrayLen(0)
+		return
+	}
+
+	w.strings(e.list[from : to+1])
+}
+
+//ZADD key [NX|XX] [CH] score member [score member ...]
+func cmdZAdd(s *Server, c *client, w *respWriter, args []string) {
+
+	i := 2
+	var nx, xx, ch bool
+	for ; i < len(args); i++ {
+		switch strings.ToUpper(args[i]) {
+		case "NX":
+			nx = true
+			continue
+		case "XX":
+			xx = true
+			continue
+		case "CH":
+			ch = true
+			continue
+		}
+		break
+	}
+
+	pairs := args[i:]
+	if len(pairs) == 0 || len(pairs)%2 != 0 {
+		w.error(msgSyntax)
+		return
+	}
+
+	scores := make([]float64, 0, len(pairs)/2)
+	for j := 0; j < len(pairs); j += 2 {
+		score, err := strconv.ParseFloat(pairs[j], 64)
+		if nil != err || math.IsNaN(score) {
+			w.error(msgNotFloat)
+			return
+		}
+		scores = append(scores, score)
+	}
+
+	e := s.lookupOrCreate(c, args[1], kindZSet)
+	if nil == e {
+		w.error(msgWrongType)
+		return
+	}
+
+	var n int64
+	for j := 0; j < len(pairs); j += 2 {
+		member, score := pairs[j+1], scores[j/2]
+		old, exists := e.zset[member]
+		if (nx && exists) || (xx && !exists) {
+			continue
+		}
+		e.zset[member] = score
+		if !exists || (ch && old != score) {
+			n++
+		}
+	}
+	s.dropIfEmpty(c, args[1], e)
+
+	w.int(n)
+}
+
+type zmember struct {
+	member string
+	score  float64
+}
+
+//members sorted by score, then by member
+func sortedZSet(zset map[string]float64) []zmember {
+
+	all := make([]zmember, 0, len(zset))
+	for member, score := range zset {
+		all = append(all, zmember{member: member, score: score})
+	}
+
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].score != all[j].score {
+			return all[i].score < all[j].score
+		}
+		return all[i].member < all[j].member
+	})
+
+	return all
+}
+
+func formatScore(score float64) string {
+
+	return strconv.FormatFloat(score, 'g', 17, 64)
+}
+
+func cmdZRank(s *Server, c *client, w *respWriter, args []string) {
+
+	e := s.lookup(c, args[1])
+	if nil == e {
+		w.nilBulk()
+		return
+	}
+	if e.kind != kindZSet {
+		w.error(msgWrongType)
+		return
+	}
+
+	for i, z := range sortedZSet(e.zset) {
+		if z.member == args[2] {
+			w.int(int64(i))
+			return
+		}
+	}
+
+	w.nilBulk()
+}
+
+func cmdZScore(s *Server, c *client, w *respWriter, args []string) {
+
+	e := s.lookup(c, args[1])
+	if nil == e {
+		w.nilBulk()
+		return
+	}
+	if e.kind != kindZSet {
+		w.error(msgWrongType)
+		return
+	}
+
+	score, ok := e.zset[args[2]]
+	if !ok {
+		w.nilBulk()
+		return
+	}
+
+	w.bulk(formatScore(score))
+}
+
+func cmdZCard(s *Server, c *client, w *respWriter, args []string) {
+
+	e := s.lookup(c, args[1])
+	if nil == e {
+		w.int(0)
+		return
+	}
+	if e.kind != kindZSet {
+		w.error(msgWrongType)
+		return
+	}
+
+	w.int(int64(len(e.zset)))
+}
+
+//ZRANGE key start stop [WITHSCORES]
+func cmdZRange(s *Server, c *client, w *respWriter, args []string) {
+
+	start, err1 := strconv.Atoi(args[2])
+	stop, err2 := strconv.Atoi(args[3])
+	if nil != err1 || nil != err2 {
+		w.error(msgNotInteger)
+		return
+	}
+
+	withScores := false
+	if len(args) == 5 && "WITHSCORES" == strings.ToUpper(args[4]) {
+		withScores = true
+	} else if len(args) > 4 {
+		w.error(msgSyntax)
+		return
+	}
+
+	e := s.lookup(c, args[1])
+	if nil == e {
+		w.arrayLen(0)
+		return
+	}
+	if e.kind != kindZSet {
+		w.error(msgWrongType)
+		return
+	}
+
+	all := sortedZSet(e.zset)
+	from, to, ok := rangeIndex(len(all), start, stop)
+	if !ok {
+		w.arrayLen(0)
+		return
+	}
+
+	if withScores {
+		w.arrayLen((to - from + 1) * 2)
+	} else {
+		w.arrayLen(to - from + 1)
+	}
+	for _, z := range all[from : to+1] {
+		w.bulk(z.member)
+		if withScores {
+			w.bulk(formatScore(z.score))
+		}
+	}
+}
+
+func cmdZRem(s *Server, c *client, w *respWriter, args []string) {
+
+	e := s.lookup(c, args[1])
+	if nil == e {
+		w.int(0)
+		return
+	}
+	if e.kind != kindZSet {
+		w.error(msgWrongType)
+		return
+	}
+
+	var n int64
+	for _, member := range args[2:] {
+		if _, ok := e.zset[member]; ok {
+			delete(e.zset, member)
+			n++
+		}
+	}
+	s.dropIfEmpty(c, args[1], e)
+
+	w.int(n)
+}
+
+//members of key with score in [min, max], nil entry when key is missing, ok false on error reply
+func (s *Server) zrangeByScore(c *client, w *respWriter, key, min, max string) ([]zmember, bool) {
+
+	inRange, err := fakes.ParseScoreRange(min, max)
+	if nil != err {
+		w.error(err.Error())
+		return nil, false
+	}
+
+	e := s.lookup(c, key)
+	if nil == e {
+		return []zmember{}, true
+	}
+	if e.kind != kindZSet {
+		w.error(msgWrongType)
+		return nil, false
+	}
+
+	members := []zmember{}
+	for _, z := range sortedZSet(e.zset) {
+		if inRange(z.score) {
+			members = append(members, z)
+		}
+	}
+
+	return members, true
+}
+
+func cmdZCount(s *Server, c *client, w *respWriter, args []string) {
+
+	members, ok := s.zrangeByScore(c, w, args[1], args[2], args[3])
+	if !ok {
+		return
+	}
+
+	w.int(int64(len(members)))
+}
+
+//ZRANGEBYSCORE key min max [WITHSCORES] [LIMIT offset count]
+func cmdZRangeByScore(s *Server, c *client, w *respWriter, args []string) {
+
+	withScores := false
+	offset, count := 0, -1
+	for i := 4; i < len(args); i++ {
+		switch strings.ToUpper(args[i]) {
+		case "WITHSCORES":
+			withScores = true
+		case "LIMIT":
+			if i+2 >= len(args) {
+				w.error(msgSyntax)
+				return
+			}
+			var err1, err2 error
+			offset, err1 = strconv.Atoi(args[i+1])
+			count, err2 = strconv.Atoi(args[i+2])
+			if nil != err1 || nil != err2 {
+				w.error(msgNotInteger)
+				return
+			}
+			i += 2
+		default:
+			w.error(msgSyntax)
+			return
+		}
+	}
+
+	members, ok := s.zrangeByScore(c, w, args[1], args[2], args[3])
+	if !ok {
+		return
+	}
+
+	if offset < 0 || offset >= len(members) {
+		members = []zmember{}
+	} else {
+		members = members[offset:]
+		if count >= 0 && count < len(members) {
+			members = members[:count]
+		}
+	}
+
+	if withScores {
+		w.arrayLen(len(members) * 2)
+	} else {
+		w.arrayLen(len(members))
+	}
+	for _, z := range members {
+		w.bulk(z.member)
+		if withScores {
+			w.bulk(formatScore(z.score))
+		}
+	}
+}
+
+func cmdZRemRangeByScore(s *Server, c *client, w *respWriter, args []string) {
+
+	members, ok := s.zrangeByScore(c, w, args[1], args[2], args[3])
+	if !ok {
+		return
+	}
+
+	if len(members) > 0 {
+		e := s.lookup(c, args[1])
+		for _, z := range members {
+			delete(e.zset, z.member)
+		}
+		s.dropIfEmpty(c, args[1], e)
+	}
+
+	w.int(int64(len(members)))
+}
+
+//deliver a message to every subscriber of channel, returns the number of receivers, caller holds mu
+func (s *Server) publish(channel string, message string) int64 {
+
+	var n int64
+	for sub := range s.subscribers {
+		w := &respWriter{}
+		if sub.channels[channel] {
+			w.strings([]string{"message", channel, message})
+			n++
+		}
+		for pattern := range sub.patterns {
+			if fakes.MatchPattern(pattern, channel) {
+				w.strings([]string{"pmessage", pattern, channel, message})
+				n++
+			}
+		}
+		w.flushTo(sub)
+	}
+
+	return n
+}
+
+func cmdPublish(s *Server, c *client, w *respWriter, args []string) {
+
+	w.int(s.publish(args[1], args[2]))
+}
+
+//SUBSCRIBE channel [channel ...] / PSUBSCRIBE pattern [pattern ...]
+func cmdSubscribe(s *Server, c *client, w *respWriter, args []string) {
+
+	kind, set := "subscribe", c.channels
+	if "PSUBSCRIBE" == strings.ToUpper(args[0]) {
+		kind, set = "psubscribe", c.patterns
+	}
+
+	for _, name := range args[1:] {
+		set[name] = true
+		w.arrayLen(3)
+		w.bulk(kind)
+		w.bulk(name)
+		w.int(int64(c.subscriptions()))
+	}
+	s.subscribers[c] = struct{}{}
+}
+
+//UNSUBSCRIBE [channel ...] / PUNSUBSCRIBE [pattern ...], no argument drops every subscription of the kind
+func cmdUnsubscribe(s *Server, c *client, w *respWriter, args []string) {
+
+	kind, set := "unsubscribe", c.channels
+	if "PUNSUBSCRIBE" == strings.ToUpper(args[0]) {
+		kind, set = "punsubscribe", c.patterns
+	}
+
+	names := args[1:]
+	if len(names) == 0 {
+		for name := range set {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+
+	if len(names) == 0 {
+		w.arrayLen(3)
+		w.bulk(kind)
+		w.nilBulk()
+		w.int(int64(c.subscriptions()))
+	}
+	for _, name := range names {
+		delete(set, name)
+		w.arrayLen(3)
+		w.bulk(kind)
+		w.bulk(name)
+		w.int(int64(c.subscriptions()))
+	}
+
+	if 0 == c.subscriptions() {
+		delete(s.subscribers, c)
+	}
+}
+
+//translate redis start/stop (negative from the end, stop inclusive) into slice bounds
+func rangeIndex(length int, start int, stop int) (from int, to int, ok bool) {
+
+	if start < 0 {
+		start += length
+	}
+	if stop < 0 {
+		stop += length
+	}
+	if start < 0 {
+		start = 0
+	}
+	if stop >= length {
+		stop = length - 1
+	}
+	if start > stop || start >= length {
+		return 0, 0, false
+	}
+
+	return start, stop, true
+}
@@ -0,0 +1,99 @@
+/**
+ * @Author KYIMH
+ * @Description
+ * @Date 2026/10/20 03:20
+ **/
+
+package redistest_test
+
+import (
+	"github.com/KYIMH/CCS_Utils/redis/redistest"
+	goredis "github.com/go-redis/redis"
+	"strings"
+	"testing"
+)
+
+const tag = "ccs"
+
+func newClient(t *testing.T) *goredis.Client {
+
+	srv, cli, err := redistest.NewClientImpl(tag)
+	if nil != err {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		cli.Close()
+		srv.Close()
+	})
+
+	raw, err := cli.GetClient(tag)
+	if nil != err {
+		t.Fatal(err)
+	}
+
+	return raw
+}
+
+func TestEval(t *testing.T) {
+
+	cli := newClient(t)
+
+	script := goredis.NewScript(`
+redis.call('SET', KEYS[1], ARGV[1])
+local n = redis.call('INCRBY', KEYS[1], 1700000000000)
+return {n, redis.call('GET', KEYS[1]), redis.call('GET', KEYS[2]), tonumber(ARGV[1]) * 2}
+`)
+	reply, err := script.Run(cli, []string{"k", "missing"}, 1).Result()
+	if nil != err {
+		t.Fatal(err)
+	}
+	//GET of a missing key is false in lua and a nil element of the reply
+	values, _ := reply.([]interface{})
+	if 4 != len(values) || int64(1700000000001) != values[0] || "1700000000001" != values[1] || nil != values[2] ||
+		int64(2) != values[3] {
+		t.Fatalf("EVAL = %#v", reply)
+	}
+
+	//Run loaded the script, EVALSHA finds it
+	if exists, err := cli.ScriptExists(script.Hash()).Result(); nil != err || !exists[0] {
+		t.Fatalf("SCRIPT EXISTS = %v, %v", exists, err)
+	}
+	if err := cli.EvalSha(strings.Repeat("0", 40), nil).Err(); nil == err || !strings.HasPrefix(err.Error(), "NOSCRIPT") {
+		t.Fatalf("EVALSHA of an unknown script = %v", err)
+	}
+}
+
+func TestEvalReplies(t *testing.T) {
+
+	cli := newClient(t)
+
+	if v, err := cli.Eval(`return redis.status_reply('DONE')`, nil).Result(); nil != err || "DONE" != v {
+		t.Fatalf("status reply = %v, %v", v, err)
+	}
+	if err := cli.Eval(`return redis.error_reply('BUSY nope')`, nil).Err(); nil == err || "BUSY nope" != err.Error() {
+		t.Fatalf("error reply = %v", err)
+	}
+	if v, err := cli.Eval(`return true`, nil).Result(); nil != err || int64(1) != v {
+		t.Fatalf("true = %v, %v", v, err)
+	}
+	if err := cli.Eval(`return false`, nil).Err(); err != goredis.Nil {
+		t.Fatalf("false = %v", err)
+	}
+
+	//redis.call raises the error of the command, redis.pcall hands it to the script
+	if err := cli.Eval(`redis.call('SET', KEYS[1], 'x') return redis.call('INCR', KEYS[1])`, []string{"k"}).Err(); nil == err ||
+		!strings.Contains(err.Error(), "not an integer") {
+		t.Fatalf("redis.call of a failing command = %v", err)
+	}
+	v, err := cli.Eval(`local r = redis.pcall('INCR', KEYS[1]) return type(r) == 'table' and r.err ~= nil`, []string{"k"}).Result()
+	if nil != err || int64(1) != v {
+		t.Fatalf("redis.pcall of a failing command = %v, %v", v, err)
+	}
+
+	if err := cli.Eval(`return redis.call('BLPOP', KEYS[1], 0)`, []string{"l"}).Err(); nil == err {
+		t.Fatal("blocking command allowed from a script")
+	}
+	if err := cli.Eval(`this is not lua`, nil).Err(); nil == err {
+		t.Fatal("EVAL of a syntax error succeeded")
+	}
+}
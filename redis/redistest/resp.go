@@ -0,0 +1,138 @@
+/**
+ * @Author KYIMH
+ * @Description
+ * @Date 2026/10/19 15:02
+ **/
+
+package redistest
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+)
+
+var errProtocol = errors.New("ERR Protocol error")
+
+//read one command, clients send arrays of bulk strings, inline commands are accepted for telnet
+func readCommand(r *bufio.Reader) ([]string, error) {
+
+	line, err := readLine(r)
+	if nil != err {
+		return nil, err
+	}
+
+	if len(line) == 0 {
+		return []string{}, nil
+	}
+
+	if line[0] != '*' {
+		return strings.Fields(line), nil
+	}
+
+	n, err := strconv.Atoi(line[1:])
+	if nil != err || n < 0 {
+		return nil, errProtocol
+	}
+
+	args := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		head, err := readLine(r)
+		if nil != err {
+			return nil, err
+		}
+		if len(head) == 0 || head[0] != '$' {
+			return nil, errProtocol
+		}
+
+		size, err := strconv.Atoi(head[1:])
+		if nil != err || size < 0 {
+			return nil, errProtocol
+		}
+
+		buf := make([]byte, size+2)
+		_, err = io.ReadFull(r, buf)
+		if nil != err {
+			return nil, err
+		}
+		args = append(args, string(buf[:size]))
+	}
+
+	return args, nil
+}
+
+func readLine(r *bufio.Reader) (string, error) {
+
+	line, err := r.ReadString('\n')
+	if nil != err {
+		return "", err
+	}
+
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+//respWriter -> buffer of RESP2 replies, written to the connection after each command
+type respWriter struct {
+	w bytes.Buffer
+}
+
+func (w *respWriter) status(s string) {
+
+	w.w.WriteString("+" + s + "\r\n")
+}
+
+func (w *respWriter) error(msg string) {
+
+	w.w.WriteString("-" + msg + "\r\n")
+}
+
+func (w *respWriter) int(n int64) {
+
+	w.w.WriteString(":" + strconv.FormatInt(n, 10) + "\r\n")
+}
+
+func (w *respWriter) bulk(s string) {
+
+	w.w.WriteString("$" + strconv.Itoa(len(s)) + "\r\n" + s + "\r\n")
+}
+
+func (w *respWriter) nilBulk() {
+
+	w.w.WriteString("$-1\r\n")
+}
+
+func (w *respWriter) arrayLen(n int) {
+
+	w.w.WriteString("*" + strconv.Itoa(n) + "\r\n")
+}
+
+func (w *respWriter) nilArray() {
+
+	w.w.WriteString("*-1\r\n")
+}
+
+func (w *respWriter) strings(values []string) {
+
+	w.arrayLen(len(values))
+	for _, v := range values {
+		w.bulk(v)
+	}
+}
+
+//write buffered replies to conn under the connection write lock
+func (w *respWriter) flushTo(c *client) error {
+
+	if w.w.Len() == 0 {
+		return nil
+	}
+
+	c.wmu.Lock()
+	_, err := c.conn.Write(w.w.Bytes())
+	c.wmu.Unlock()
+	w.w.Reset()
+
+	return err
+}
@@ -0,0 +1,381 @@
+/**
+ * @Author KYIMH
+ * @Description
+ * @Date 2026/10/20 03:00
+ **/
+
+package redistest
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	lua "github.com/yuin/gopher-lua"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+//replyError -> error reply of a command called by a script
+type replyError string
+
+func (e replyError) Error() string {
+
+	return string(e)
+}
+
+//statusReply -> status reply of a command called by a script, lua sees it as {ok = status}
+type statusReply string
+
+//scripting commands run other commands, registered here to break the initialization cycle with commands
+func init() {
+
+	commands["EVAL"] = command{fn: cmdEval, arity: -3}
+	commands["EVALSHA"] = command{fn: cmdEval, arity: -3}
+	commands["SCRIPT"] = command{fn: cmdScript, arity: -2}
+}
+
+func scriptSha(src string) string {
+
+	sum := sha1.Sum([]byte(src))
+
+	return hex.EncodeToString(sum[:])
+}
+
+//EVAL script numkeys [key ...] [arg ...], EVALSHA sha1 numkeys [key ...] [arg ...]
+func cmdEval(s *Server, c *client, w *respWriter, args []string) {
+
+	src := args[1]
+	if "EVALSHA" == strings.ToUpper(args[0]) {
+		var ok bool
+		src, ok = s.scripts[strings.ToLower(args[1])]
+		if !ok {
+			w.error("NOSCRIPT No matching script. Please use EVAL.")
+			return
+		}
+	} else {
+		s.scripts[scriptSha(src)] = src
+	}
+
+	numKeys, err := strconv.Atoi(args[2])
+	if nil != err || numKeys < 0 {
+		w.error(msgNotInteger)
+		return
+	}
+	if numKeys > len(args)-3 {
+		w.error("ERR Number of keys can't be greater than number of args")
+		return
+	}
+
+	reply, err := s.runScript(c, src, args[3:3+numKeys], args[3+numKeys:])
+	if nil != err {
+		w.error("ERR Error running script (call to f_" + scriptSha(src) + "): " + err.Error())
+		return
+	}
+
+	writeReply(w, reply)
+}
+
+//SCRIPT LOAD script | EXISTS sha1 [sha1 ...] | FLUSH
+func cmdScript(s *Server, c *client, w *respWriter, args []string) {
+
+	switch strings.ToUpper(args[1]) {
+	case "LOAD":
+		if len(args) != 3 {
+			w.error("ERR wrong number of arguments for 'script|load' command")
+			return
+		}
+		sha := scriptSha(args[2])
+		s.scripts[sha] = args[2]
+		w.bulk(sha)
+	case "EXISTS":
+		w.arrayLen(len(args) - 2)
+		for _, sha := range args[2:] {
+			if _, ok := s.scripts[strings.ToLower(sha)]; ok {
+				w.int(1)
+			} else {
+				w.int(0)
+			}
+		}
+	case "FLUSH":
+		s.scripts = make(map[string]string)
+		w.status("OK")
+	default:
+		w.error("ERR Unknown subcommand '" + args[1] + "'")
+	}
+}
+
+//run src in a fresh lua state with KEYS, ARGV and the redis table of a real server, caller holds mu
+//so the script is atomic like on a real server
+func (s *Server) runScript(c *client, src string, keys []string, argv []string) (interface{}, error) {
+
+	L := lua.NewState()
+	defer L.Close()
+
+	L.SetGlobal("KEYS", luaStrings(L, keys))
+	L.SetGlobal("ARGV", luaStrings(L, argv))
+
+	mod := L.NewTable()
+	L.SetField(mod, "call", L.NewFunction(func(L *lua.LState) int {
+		return s.luaCall(L, c, true)
+	}))
+	L.SetField(mod, "pcall", L.NewFunction(func(L *lua.LState) int {
+		return s.luaCall(L, c, false)
+	}))
+	L.SetField(mod, "status_reply", L.NewFunction(func(L *lua.LState) int {
+		L.Push(luaReplyTable(L, "ok", L.CheckString(1)))
+		return 1
+	}))
+	L.SetField(mod, "error_reply", L.NewFunction(func(L *lua.LState) int {
+		L.Push(luaReplyTable(L, "err", L.CheckString(1)))
+		return 1
+	}))
+	L.SetGlobal("redis", mod)
+
+	fn, err := L.LoadString(src)
+	if nil != err {
+		return nil, luaError(err)
+	}
+	L.Push(fn)
+	err = L.PCall(0, 1, nil)
+	if nil != err {
+		return nil, luaError(err)
+	}
+
+	return fromLua(L.Get(-1)), nil
+}
+
+//error of a failed script on one line, without the traceback gopher-lua appends
+func luaError(err error) error {
+
+	msg := err.Error()
+	if apiErr, ok := err.(*lua.ApiError); ok && nil != apiErr.Object {
+		msg = apiErr.Object.String()
+	}
+
+	return errors.New(strings.Join(strings.Fields(msg), " "))
+}
+
+//redis.call and redis.pcall, raise makes an error reply a lua error instead of an {err = ...} table
+func (s *Server) luaCall(L *lua.LState, c *client, raise bool) int {
+
+	n := L.GetTop()
+	if 0 == n {
+		L.RaiseError("Please specify at least one argument for redis.call()")
+		return 0
+	}
+
+	args := make([]string, n)
+	for i := 1; i <= n; i++ {
+		switch v := L.Get(i).(type) {
+		case lua.LString:
+			args[i-1] = string(v)
+		case lua.LNumber:
+			args[i-1] = luaNumberArg(float64(v))
+		default:
+			L.RaiseError("Lua redis() command arguments must be strings or integers")
+			return 0
+		}
+	}
+
+	reply, err := s.call(c, args...)
+	if nil != err {
+		if raise {
+			L.RaiseError("%s", err.Error())
+			return 0
+		}
+		L.Push(luaReplyTable(L, "err", err.Error()))
+		return 1
+	}
+
+	L.Push(toLua(L, reply))
+
+	return 1
+}
+
+//number argument the way redis formats it, integers without exponent
+func luaNumberArg(f float64) string {
+
+	if f == math.Trunc(f) && math.Abs(f) < 1e17 {
+		return strconv.FormatInt(int64(f), 10)
+	}
+
+	return strconv.FormatFloat(f, 'g', 17, 64)
+}
+
+func luaStrings(L *lua.LState, values []string) *lua.LTable {
+
+	t := L.CreateTable(len(values), 0)
+	for _, v := range values {
+		t.Append(lua.LString(v))
+	}
+
+	return t
+}
+
+func luaReplyTable(L *lua.LState, field string, msg string) *lua.LTable {
+
+	t := L.NewTable()
+	L.SetField(t, field, lua.LString(msg))
+
+	return t
+}
+
+//command reply to lua: integer -> number, bulk -> string, nil -> false, array -> table,
+//status -> {ok = status}, error -> {err = message}
+func toLua(L *lua.LState, reply interface{}) lua.LValue {
+
+	switch v := reply.(type) {
+	case int64:
+		return lua.LNumber(v)
+	case string:
+		return lua.LString(v)
+	case statusReply:
+		return luaReplyTable(L, "ok", string(v))
+	case replyError:
+		return luaReplyTable(L, "err", string(v))
+	case []interface{}:
+		t := L.CreateTable(len(v), 0)
+		for _, item := range v {
+			t.Append(toLua(L, item))
+		}
+		return t
+	}
+
+	return lua.LFalse
+}
+
+//lua return value to reply: number -> integer, string -> bulk, true -> 1, false and nil -> nil,
+//{ok = ...} -> status, {err = ...} -> error, table -> array up to its first nil
+func fromLua(value lua.LValue) interface{} {
+
+	switch v := value.(type) {
+	case lua.LNumber:
+		return int64(v)
+	case lua.LString:
+		return string(v)
+	case lua.LBool:
+		if bool(v) {
+			return int64(1)
+		}
+		return nil
+	case *lua.LTable:
+		if msg, ok := v.RawGetString("err").(lua.LString); ok {
+			return replyError(msg)
+		}
+		if status, ok := v.RawGetString("ok").(lua.LString); ok {
+			return statusReply(status)
+		}
+		items := []interface{}{}
+		for i := 1; ; i++ {
+			item := v.RawGetInt(i)
+			if lua.LNil == item {
+				break
+			}
+			items = append(items, fromLua(item))
+		}
+		return items
+	}
+
+	return nil
+}
+
+func writeReply(w *respWriter, reply interface{}) {
+
+	switch v := reply.(type) {
+	case nil:
+		w.nilBulk()
+	case int64:
+		w.int(v)
+	case string:
+		w.bulk(v)
+	case statusReply:
+		w.status(string(v))
+	case replyError:
+		w.error(string(v))
+	case []interface{}:
+		w.arrayLen(len(v))
+		for _, item := range v {
+			writeReply(w, item)
+		}
+	default:
+		w.error("ERR redistest: unsupported script reply")
+	}
+}
+
+//run a command from a script like redis.call, caller holds mu
+//returns int64, string, statusReply, nil, []interface{}, an error reply is returned as replyError
+func (s *Server) call(c *client, args ...string) (interface{}, error) {
+
+	cmd, ok := commands[strings.ToUpper(args[0])]
+	if !ok || cmd.blocking || cmd.pubsub && "PING" != strings.ToUpper(args[0]) {
+		return nil, replyError("ERR This Redis command is not allowed from scripts")
+	}
+	if cmd.arity > 0 && len(args) != cmd.arity || cmd.arity < 0 && len(args) < -cmd.arity {
+		return nil, replyError("ERR Wrong number of args calling Redis command From Lua script")
+	}
+
+	w := &respWriter{}
+	cmd.fn(s, c, w, args)
+
+	return readReply(bufio.NewReader(&w.w))
+}
+
+//parse one reply written by respWriter
+func readReply(r *bufio.Reader) (interface{}, error) {
+
+	line, err := readLine(r)
+	if nil != err {
+		return nil, err
+	}
+	if len(line) == 0 {
+		return nil, errProtocol
+	}
+
+	switch line[0] {
+	case '+':
+		return statusReply(line[1:]), nil
+	case '-':
+		return nil, replyError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		size, err := strconv.Atoi(line[1:])
+		if nil != err {
+			return nil, err
+		}
+		if size < 0 {
+			return nil, nil
+		}
+		buf := make([]byte, size+2)
+		_, err = io.ReadFull(r, buf)
+		if nil != err {
+			return nil, err
+		}
+		return string(buf[:size]), nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if nil != err {
+			return nil, err
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		items := make([]interface{}, 0, n)
+		for i := 0; i < n; i++ {
+			item, err := readReply(r)
+			if _, isReply := err.(replyError); nil != err && !isReply {
+				return nil, err
+			}
+			if nil != err {
+				item = err
+			}
+			items = append(items, item)
+		}
+		return items, nil
+	}
+
+	return nil, errProtocol
+}
@@ -0,0 +1,422 @@
+/**
+ * @Author KYIMH
+ * @Description
+ * @Date 2026/10/19 15:02
+ **/
+
+//Package redistest provides an in-process RESP2 server for tests, so the real
+//go-redis code paths of redis.ClientImpl run without a redis binary.
+//EVAL and EVALSHA run the real Lua source in an embedded interpreter, see scripts.go.
+package redistest
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"github.com/KYIMH/CCS_Utils/redis"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type valueKind int
+
+const (
+	kindString valueKind = iota
+	kindHash
+	kindList
+	kindZSet
+)
+
+type entry struct {
+	kind     valueKind
+	str      string
+	hash     map[string]string
+	list     []string
+	zset     map[string]float64
+	expireAt time.Time
+}
+
+type database struct {
+	data map[string]*entry
+}
+
+//Server -> in-process RESP2 server supporting the commands redis.Dal uses
+type Server struct {
+	listener net.Listener
+	//run_id of INFO server, random per server like a redis process
+	runId string
+
+	mu     sync.Mutex
+	dbs    map[int]*database
+	offset time.Duration
+	//closed and replaced on every list push so blocked pops wake up
+	pushed chan struct{}
+	conns  map[net.Conn]struct{}
+	//clients with at least one subscription
+	subscribers map[*client]struct{}
+	//notify-keyspace-events flags set by CONFIG SET
+	notifyFlags string
+	//SCAN cursor -> last key returned
+	scanCursors map[int]string
+	scanSeq     int
+	//sha1 -> source of scripts sent by EVAL or SCRIPT LOAD
+	scripts map[string]string
+	closed  chan struct{}
+	wg      sync.WaitGroup
+}
+
+//create a server without listening, call Start or use Run
+func NewServer() *Server {
+
+	id := make([]byte, 20)
+	rand.Read(id)
+
+	return &Server{
+		runId:       hex.EncodeToString(id),
+		dbs:         make(map[int]*database),
+		pushed:      make(chan struct{}),
+		conns:       make(map[net.Conn]struct{}),
+		subscribers: make(map[*client]struct{}),
+		scanCursors: make(map[int]string),
+		scripts:     make(map[string]string),
+		closed:      make(chan struct{}),
+	}
+}
+
+//start a server on a random local port
+func Run() (*Server, error) {
+
+	s := NewServer()
+	err := s.Start("127.0.0.1:0")
+	if nil != err {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+//listen on addr and serve in background
+func (s *Server) Start(addr string) error {
+
+	ln, err := net.Listen("tcp", addr)
+	if nil != err {
+		return err
+	}
+	s.listener = ln
+
+	s.wg.Add(1)
+	go s.serve()
+
+	return nil
+}
+
+//address the server listens on, example: 127.0.0.1:53412
+func (s *Server) Addr() string {
+
+	return s.listener.Addr().String()
+}
+
+//stop listening and drop every connection
+func (s *Server) Close() {
+
+	s.mu.Lock()
+	select {
+	case <-s.closed:
+		s.mu.Unlock()
+		return
+	default:
+	}
+	close(s.closed)
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+
+	if nil != s.listener {
+		s.listener.Close()
+	}
+	s.wg.Wait()
+}
+
+//drop every key of every db
+func (s *Server) FlushAll() {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.dbs = make(map[int]*database)
+}
+
+//move the server clock forward to expire keys without sleeping
+func (s *Server) FastForward(d time.Duration) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.offset += d
+
+	//expire due keys actively so expired events fire like on a real server
+	now := s.now()
+	for index, db := range s.dbs {
+		for key, e := range db.data {
+			if !e.expireAt.IsZero() && !now.Before(e.expireAt) {
+				delete(db.data, key)
+				s.notify(index, "expired", 'x', key)
+			}
+		}
+	}
+}
+
+//remove key as if maxmemory evicted it, evicted events fire, returns false if key does not exist
+func (s *Server) Evict(db int, key string) bool {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.db(db).data[key]; !ok {
+		return false
+	}
+	delete(s.db(db).data, key)
+	s.notify(db, "evicted", 'e', key)
+
+	return true
+}
+
+//create a redis.ClientImpl whose tags all point to this server
+func (s *Server) NewClientImpl(redisTags ...string) *redis.ClientImpl {
+
+	configs := make([]redis.RedisConfig, 0, len(redisTags))
+	for _, tag := range redisTags {
+		configs = append(configs, redis.RedisConfig{RedisTag: tag, Addr: s.Addr(), Timeout: 1000})
+	}
+
+	return redis.NewClientImpl(configs...)
+}
+
+//start a server on a random port and return a ClientImpl wired to it
+//close both with cli.Close() and srv.Close()
+func NewClientImpl(redisTags ...string) (*Server, *redis.ClientImpl, error) {
+
+	srv, err := Run()
+	if nil != err {
+		return nil, nil, err
+	}
+
+	return srv, srv.NewClientImpl(redisTags...), nil
+}
+
+func (s *Server) serve() {
+
+	defer s.wg.Done()
+
+	for {
+		conn, err := s.listener.Accept()
+		if nil != err {
+			return
+		}
+
+		s.mu.Lock()
+		select {
+		case <-s.closed:
+			s.mu.Unlock()
+			conn.Close()
+			return
+		default:
+		}
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+
+		s.wg.Add(1)
+		go s.handleConn(conn)
+	}
+}
+
+//client -> state of one connection
+//wmu serializes replies of the connection goroutine with messages pushed by PUBLISH
+type client struct {
+	db       int
+	conn     net.Conn
+	wmu      sync.Mutex
+	channels map[string]bool
+	patterns map[string]bool
+}
+
+//number of channels and patterns the client subscribes to
+func (c *client) subscriptions() int {
+
+	return len(c.channels) + len(c.patterns)
+}
+
+func (s *Server) handleConn(conn net.Conn) {
+
+	r := bufio.NewReader(conn)
+	w := &respWriter{}
+	c := &client{
+		conn:     conn,
+		channels: make(map[string]bool),
+		patterns: make(map[string]bool),
+	}
+
+	defer s.wg.Done()
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		delete(s.subscribers, c)
+		s.mu.Unlock()
+		conn.Close()
+	}()
+
+	for {
+		args, err := readCommand(r)
+		if nil != err {
+			if err == errProtocol {
+				w.error(err.Error())
+				w.flushTo(c)
+			}
+			return
+		}
+		if len(args) == 0 {
+			continue
+		}
+
+		quit := s.dispatch(c, w, args)
+		if nil != w.flushTo(c) || quit {
+			return
+		}
+	}
+}
+
+//run one command, returns true when the connection must be closed
+func (s *Server) dispatch(c *client, w *respWriter, args []string) bool {
+
+	name := strings.ToUpper(args[0])
+	if "QUIT" == name {
+		w.status("OK")
+		return true
+	}
+
+	cmd, ok := commands[name]
+	if !ok {
+		w.error("ERR unknown command '" + args[0] + "'")
+		return false
+	}
+
+	if c.subscriptions() > 0 && !cmd.pubsub {
+		w.error("ERR only (P)SUBSCRIBE / (P)UNSUBSCRIBE / PING / QUIT allowed in this context")
+		return false
+	}
+
+	if cmd.arity > 0 && len(args) != cmd.arity || cmd.arity < 0 && len(args) < -cmd.arity {
+		w.error("ERR wrong number of arguments for '" + strings.ToLower(args[0]) + "' command")
+		return false
+	}
+
+	if cmd.blocking {
+		cmd.fn(s, c, w, args)
+		return false
+	}
+
+	s.mu.Lock()
+	cmd.fn(s, c, w, args)
+	s.mu.Unlock()
+
+	return false
+}
+
+//server clock, caller holds mu
+func (s *Server) now() time.Time {
+
+	return time.Now().Add(s.offset)
+}
+
+//caller holds mu
+func (s *Server) db(index int) *database {
+
+	db, ok := s.dbs[index]
+	if !ok {
+		db = &database{data: make(map[string]*entry)}
+		s.dbs[index] = db
+	}
+
+	return db
+}
+
+//get a live entry, expired entries are removed, caller holds mu
+func (s *Server) lookup(c *client, key string) *entry {
+
+	db := s.db(c.db)
+	e, ok := db.data[key]
+	if !ok {
+		return nil
+	}
+
+	if !e.expireAt.IsZero() && !s.now().Before(e.expireAt) {
+		delete(db.data, key)
+		s.notify(c.db, "expired", 'x', key)
+		return nil
+	}
+
+	return e
+}
+
+//get a live entry of kind or create an empty one, nil when key holds another kind, caller holds mu
+func (s *Server) lookupOrCreate(c *client, key string, kind valueKind) *entry {
+
+	e := s.lookup(c, key)
+	if nil == e {
+		e = &entry{kind: kind}
+		switch kind {
+		case kindHash:
+			e.hash = make(map[string]string)
+		case kindZSet:
+			e.zset = make(map[string]float64)
+		}
+		s.db(c.db).data[key] = e
+		return e
+	}
+
+	if e.kind != kind {
+		return nil
+	}
+
+	return e
+}
+
+//remove key if its collection became empty, caller holds mu
+func (s *Server) dropIfEmpty(c *client, key string, e *entry) {
+
+	if len(e.hash) == 0 && len(e.list) == 0 && len(e.zset) == 0 && e.kind != kindString {
+		delete(s.db(c.db).data, key)
+	}
+}
+
+//publish a keyspace notification when notifyFlags enable class, caller holds mu
+//class: g generic, $ string, x expired, e evicted
+func (s *Server) notify(db int, event string, class byte, key string) {
+
+	flags := s.notifyFlags
+	if strings.ContainsRune(flags, 'A') {
+		flags += "g$lshzxe"
+	}
+	if !strings.ContainsRune(flags, rune(class)) {
+		return
+	}
+
+	prefix := "@" + strconv.Itoa(db) + "__:"
+	if strings.ContainsRune(flags, 'K') {
+		s.publish("__keyspace"+prefix+key, event)
+	}
+	if strings.ContainsRune(flags, 'E') {
+		s.publish("__keyevent"+prefix+event, key)
+	}
+}
+
+//wake blocked pops, caller holds mu
+func (s *Server) notifyPush() {
+
+	close(s.pushed)
+	s.pushed = make(chan struct{})
+}
@@ -0,0 +1,241 @@
+/**
+ * @Author KYIMH
+ * @Description
+ * @Date 2026/10/19 20:40
+ **/
+
+package redis
+
+import (
+	"errors"
+	"fmt"
+	"github.com/KYIMH/CCS_Utils/share/multierr"
+	"github.com/go-redis/redis"
+	"github.com/sirupsen/logrus"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const defaultReplicaCheckInterval = 5 * time.Second
+
+type ReplicaPoolType map[string]*ReplicaSet
+
+//ReplicaSet -> read replicas of one redis tag, the primary stays in ClientImpl.Pool
+//reads go round robin over healthy replicas, a replica is marked down when a read fails
+//with a connection error and comes back after the next successful health check
+type ReplicaSet struct {
+	Clients []*redis.Client
+
+	next    uint32
+	healthy []int32
+	stop    chan struct{}
+	done    chan struct{}
+	once    sync.Once
+}
+
+//create a replica set and start its health check, checkInterval 0 takes 5s, negative disables the check
+func NewReplicaSet(clients []*redis.Client, checkInterval time.Duration) *ReplicaSet {
+
+	r := &ReplicaSet{
+		Clients: clients,
+		healthy: make([]int32, len(clients)),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	for i := range r.healthy {
+		r.healthy[i] = 1
+	}
+
+	if checkInterval == 0 {
+		checkInterval = defaultReplicaCheckInterval
+	}
+	if checkInterval < 0 {
+		close(r.done)
+		return r
+	}
+
+	go func() {
+		defer close(r.done)
+
+		ticker := time.NewTicker(checkInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				r.Check()
+			case <-r.stop:
+				return
+			}
+		}
+	}()
+
+	return r
+}
+
+//create the replica set of config.Replicas, nil if the tag has none
+func NewReplicaSetWithConfig(config RedisConfig) *ReplicaSet {
+
+	if len(config.Replicas) == 0 {
+		return nil
+	}
+
+	clients := make([]*redis.Client, 0, len(config.Replicas))
+	for _, addr := range config.Replicas {
+		replica := config
+		replica.Addr = addr
+		clients = append(clients, NewClientWithConfig(replica))
+	}
+
+	return NewReplicaSet(clients, time.Duration(config.ReplicaCheck)*time.Millisecond)
+}
+
+//ping every replica and refresh its health, a replica whose link to the primary is down is unhealthy
+func (r *ReplicaSet) Check() {
+
+	for i, cli := range r.Clients {
+		up := nil == cli.Ping().Err()
+		if up {
+			//INFO may be renamed or disabled, only a reported down link marks the replica down
+			info, err := cli.Info("replication").Result()
+			if nil == err && strings.Contains(info, "master_link_status:down") {
+				up = false
+			}
+		}
+
+		was := atomic.LoadInt32(&r.healthy[i]) == 1
+		if up {
+			atomic.StoreInt32(&r.healthy[i], 1)
+		} else {
+			atomic.StoreInt32(&r.healthy[i], 0)
+		}
+		if was != up {
+			logrus.Warn("redis replica ", cli.Options().Addr, " healthy: ", up)
+		}
+	}
+}
+
+//number of healthy replicas
+func (r *ReplicaSet) Healthy() int {
+
+	n := 0
+	for i := range r.healthy {
+		if atomic.LoadInt32(&r.healthy[i]) == 1 {
+			n++
+		}
+	}
+
+	return n
+}
+
+//next healthy replica round robin, -1 when none is healthy
+func (r *ReplicaSet) pick() int {
+
+	n := len(r.Clients)
+	start := int(atomic.AddUint32(&r.next, 1))
+	for i := 0; i < n; i++ {
+		index := (start + i) % n
+		if atomic.LoadInt32(&r.healthy[index]) == 1 {
+			return index
+		}
+	}
+
+	return -1
+}
+
+func (r *ReplicaSet) markDown(index int) {
+
+	if atomic.CompareAndSwapInt32(&r.healthy[index], 1, 0) {
+		logrus.Warn("redis replica ", r.Clients[index].Options().Addr, " marked down")
+	}
+}
+
+//stop the health check and close every replica client, every client is closed even if some fail and all errors are returned
+func (r *ReplicaSet) Close() error {
+
+	r.once.Do(func() {
+		close(r.stop)
+	})
+	<-r.done
+
+	var errs multierr.Errors
+	for _, cli := range r.Clients {
+		err := cli.Close()
+		if nil != err {
+			errs.Add(fmt.Errorf("redis replica %s: %w", cli.Options().Addr, err))
+		}
+	}
+
+	return errs.Err()
+}
+
+//true when err means the replica cannot serve, not that the command failed
+func replicaUnavailable(err error) bool {
+
+	if nil == err || err == redis.Nil {
+		return false
+	}
+
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+
+	msg := err.Error()
+	for _, prefix := range []string{"LOADING ", "MASTERDOWN ", "redis: client is closed", "redis: connection pool timeout"} {
+		if strings.HasPrefix(msg, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+//copy of the client whose reads go to the primary, the default, turns a Replica() view back
+func (c ClientImpl) Primary() Dal {
+
+	c.replicaReads = false
+
+	return c
+}
+
+//copy of the client whose reads go to the replicas of the tag, opt in for reads that tolerate replication lag,
+//never hand it to code that reads its own writes or revokes something
+func (c ClientImpl) Replica() Dal {
+
+	c.replicaReads = true
+
+	return c
+}
+
+//run a read only command on the primary of redisTag, or on a healthy replica in a Replica() view,
+//falls back to the primary when the tag has no replica, none is healthy or the replica fails
+func (c ClientImpl) read(redisTag string, fn func(cli *redis.Client) error) error {
+
+	replicas, ok := c.replicas(redisTag)
+	if ok && c.replicaReads && len(replicas.Clients) > 0 {
+		index := replicas.pick()
+		if index >= 0 {
+			err := fn(replicas.Clients[index])
+			if !replicaUnavailable(err) {
+				return err
+			}
+			replicas.markDown(index)
+		}
+	}
+
+	cli, err := c.GetClient(redisTag)
+	if nil != err {
+		return err
+	}
+
+	return fn(cli)
+}
@@ -0,0 +1,92 @@
+/**
+ * @Author KYIMH
+ * @Description
+ * @Date 2026/10/19 21:10
+ **/
+
+package redis
+
+import (
+	"hash/crc32"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const defaultVirtualNodes = 160
+
+//ring -> consistent hash ring of redis tags, immutable once built
+type ring struct {
+	tags   []string
+	vnodes int
+	points []uint32
+	owners map[uint32]string
+}
+
+//build a ring with vnodes points per tag
+func newRing(tags []string, vnodes int) *ring {
+
+	if vnodes <= 0 {
+		vnodes = defaultVirtualNodes
+	}
+
+	r := &ring{
+		tags:   append([]string{}, tags...),
+		vnodes: vnodes,
+		points: make([]uint32, 0, len(tags)*vnodes),
+		owners: make(map[uint32]string, len(tags)*vnodes),
+	}
+	for _, tag := range tags {
+		for i := 0; i < vnodes; i++ {
+			point := crc32.ChecksumIEEE([]byte(tag + "#" + strconv.Itoa(i)))
+			//on a collision the first tag keeps the point so every process builds the same ring
+			if _, ok := r.owners[point]; ok {
+				continue
+			}
+			r.owners[point] = tag
+			r.points = append(r.points, point)
+		}
+	}
+	sort.Slice(r.points, func(i, j int) bool { return r.points[i] < r.points[j] })
+
+	return r
+}
+
+//ring with one more tag
+func (r *ring) with(tag string) *ring {
+
+	return newRing(append(append([]string{}, r.tags...), tag), r.vnodes)
+}
+
+//tag owning key
+func (r *ring) owner(key string) string {
+
+	if len(r.points) == 0 {
+		return ""
+	}
+
+	h := crc32.ChecksumIEEE([]byte(HashSlotKey(key)))
+	i := sort.Search(len(r.points), func(i int) bool { return r.points[i] >= h })
+	if i == len(r.points) {
+		i = 0
+	}
+
+	return r.owners[r.points[i]]
+}
+
+//HashSlotKey -> part of key that is hashed, the content of the first non empty {...} like redis cluster
+//example: user:{42}:inbox and user:{42}:seen land on the same shard
+func HashSlotKey(key string) string {
+
+	start := strings.IndexByte(key, '{')
+	if start < 0 {
+		return key
+	}
+
+	end := strings.IndexByte(key[start+1:], '}')
+	if end <= 0 {
+		return key
+	}
+
+	return key[start+1 : start+1+end]
+}
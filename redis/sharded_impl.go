@@ -0,0 +1,641 @@
+/**
+ * @Author KYIMH
+ * @Description
+ * @Date 2026/10/19 21:10
+ **/
+
+package redis
+
+import (
+	"errors"
+	"github.com/go-redis/redis"
+	"github.com/sirupsen/logrus"
+	"sync"
+	"time"
+)
+
+//shardState -> ring shared by a ShardedImpl and its Primary and Replica copies
+//migration: keys moving to the shard AddShard adds, nil unless AddShard runs, guarded by mu
+//moving: held for writing while AddShard starts or ends a migration, write commands hold it for reading
+type shardState struct {
+	mu        sync.RWMutex
+	ring      *ring
+	migration *migration
+	rebalance sync.Mutex
+	moving    sync.RWMutex
+}
+
+//ShardedImpl -> redis.Dal spreading keys over the tags of Dal with a consistent hash ring
+//the redisTag argument of every method is ignored, the key picks the shard,
+//keys sharing a {hash tag} land on the same shard so multi key commands can use them together
+//GetClient and RedisPublish use the first shard unless redisTag names a shard,
+//scripts run through GetClient therefore see only that shard
+type ShardedImpl struct {
+	Dal Dal
+
+	state *shardState
+}
+
+//create a sharded Dal over redisTags of dal, vnodes points per shard, 160 if 0
+//every process must list the tags in the same order to agree on the first shard
+func NewSharded(dal Dal, redisTags []string, vnodes int) *ShardedImpl {
+
+	return &ShardedImpl{
+		Dal:   dal,
+		state: &shardState{ring: newRing(redisTags, vnodes)},
+	}
+}
+
+func (s *ShardedImpl) ring() *ring {
+
+	s.state.mu.RLock()
+	defer s.state.mu.RUnlock()
+
+	return s.state.ring
+}
+
+//tags of the shards in ring order of addition
+func (s *ShardedImpl) Shards() []string {
+
+	return append([]string{}, s.ring().tags...)
+}
+
+//tag of the shard holding key, the owner in the ring or, while AddShard runs, the new shard once key moved there
+func (s *ShardedImpl) ShardOf(key string) string {
+
+	r, m := s.routing()
+
+	return holderOf(r, m, key)
+}
+
+//shard of keys, ErrCrossShard if they map to different shards
+func (s *ShardedImpl) shardOfAll(keys []string) (string, error) {
+
+	r, m := s.routing()
+	tag := ""
+	for i, key := range keys {
+		owner := holderOf(r, m, key)
+		if i > 0 && owner != tag {
+			return "", ErrCrossShard
+		}
+		tag = owner
+	}
+
+	return tag, nil
+}
+
+//client of shard redisTag, the first shard if redisTag is not a shard
+func (s *ShardedImpl) GetClient(redisTag string) (*redis.Client, error) {
+
+	r := s.ring()
+	for _, tag := range r.tags {
+		if tag == redisTag {
+			return s.Dal.GetClient(tag)
+		}
+	}
+
+	if len(r.tags) == 0 {
+		return nil, errors.New("no shard in ring")
+	}
+
+	return s.Dal.GetClient(r.tags[0])
+}
+
+func (s *ShardedImpl) Close() error {
+
+	return s.Dal.Close()
+}
+
+//copy whose reads always go to the primaries, shares the ring
+func (s *ShardedImpl) Primary() Dal {
+
+	return &ShardedImpl{Dal: PrimaryOf(s.Dal), state: s.state}
+}
+
+//copy whose reads may go to the replicas of the shards, shares the ring
+func (s *ShardedImpl) Replica() Dal {
+
+	return &ShardedImpl{Dal: ReplicaOf(s.Dal), state: s.state}
+}
+
+func (s *ShardedImpl) RedisSet(_ string, key string, value interface{}, expire int) error {
+
+	tag, done, err := s.writeShard(key)
+	if nil != err {
+		return err
+	}
+	defer done()
+
+	return s.Dal.RedisSet(tag, key, value, expire)
+}
+
+func (s *ShardedImpl) RedisSetNX(_ string, key string, value interface{}, expire int) (bool, error) {
+
+	tag, done, err := s.writeShard(key)
+	if nil != err {
+		return false, err
+	}
+	defer done()
+
+	nx, err := AsNXSetter(s.Dal)
+	if nil != err {
+		return false, err
+	}
+
+	return nx.RedisSetNX(tag, key, value, expire)
+}
+
+func (s *ShardedImpl) RedisSetXX(_ string, key string, value interface{}, expire int) (bool, error) {
+
+	tag, done, err := s.writeShard(key)
+	if nil != err {
+		return false, err
+	}
+	defer done()
+
+	xx, err := AsXXSetter(s.Dal)
+	if nil != err {
+		return false, err
+	}
+
+	return xx.RedisSetXX(tag, key, value, expire)
+}
+
+func (s *ShardedImpl) RedisKeyExists(_ string, key string) (bool, error) {
+
+	return s.Dal.RedisKeyExists(s.ShardOf(key), key)
+}
+
+func (s *ShardedImpl) RedisGet(_ string, key string) (string, error) {
+
+	return s.Dal.RedisGet(s.ShardOf(key), key)
+}
+
+func (s *ShardedImpl) RedisGetResult(_ string, key string) (interface{}, error) {
+
+	return s.Dal.RedisGetResult(s.ShardOf(key), key)
+}
+
+func (s *ShardedImpl) RedisGetInt(_ string, key string) (int, error) {
+
+	return s.Dal.RedisGetInt(s.ShardOf(key), key)
+}
+
+func (s *ShardedImpl) RedisGetInt64(_ string, key string) (int64, error) {
+
+	return s.Dal.RedisGetInt64(s.ShardOf(key), key)
+}
+
+func (s *ShardedImpl) RedisGetUint64(_ string, key string) (uint64, error) {
+
+	return s.Dal.RedisGetUint64(s.ShardOf(key), key)
+}
+
+func (s *ShardedImpl) RedisGetFloat64(_ string, key string) (float64, error) {
+
+	return s.Dal.RedisGetFloat64(s.ShardOf(key), key)
+}
+
+func (s *ShardedImpl) RedisExpire(_ string, key string, expire int) error {
+
+	tag, done, err := s.writeShard(key)
+	if nil != err {
+		return err
+	}
+	defer done()
+
+	return s.Dal.RedisExpire(tag, key, expire)
+}
+
+func (s *ShardedImpl) RedisPExpire(_ string, key string, expire int) (bool, error) {
+
+	tag, done, err := s.writeShard(key)
+	if nil != err {
+		return false, err
+	}
+	defer done()
+
+	xx, err := AsXXSetter(s.Dal)
+	if nil != err {
+		return false, err
+	}
+
+	return xx.RedisPExpire(tag, key, expire)
+}
+
+func (s *ShardedImpl) RedisPTTL(_ string, key string) (int, error) {
+
+	return s.Dal.RedisPTTL(s.ShardOf(key), key)
+}
+
+func (s *ShardedImpl) RedisTTL(_ string, key string) (int, error) {
+
+	return s.Dal.RedisTTL(s.ShardOf(key), key)
+}
+
+func (s *ShardedImpl) RedisDel(_ string, key string) error {
+
+	tag, done, err := s.writeShard(key)
+	if nil != err {
+		return err
+	}
+	defer done()
+
+	return s.Dal.RedisDel(tag, key)
+}
+
+func (s *ShardedImpl) RedisHGet(_ string, key string, field string) (string, error) {
+
+	return s.Dal.RedisHGet(s.ShardOf(key), key, field)
+}
+
+func (s *ShardedImpl) RedisHSet(_ string, key string, field string, value string) error {
+
+	tag, done, err := s.writeShard(key)
+	if nil != err {
+		return err
+	}
+	defer done()
+
+	return s.Dal.RedisHSet(tag, key, field, value)
+}
+
+func (s *ShardedImpl) RedisHDel(_ string, key string, field string) error {
+
+	tag, done, err := s.writeShard(key)
+	if nil != err {
+		return err
+	}
+	defer done()
+
+	return s.Dal.RedisHDel(tag, key, field)
+}
+
+func (s *ShardedImpl) RedisZAdd(_ string, key string, member string, score string) error {
+
+	tag, done, err := s.writeShard(key)
+	if nil != err {
+		return err
+	}
+	defer done()
+
+	return s.Dal.RedisZAdd(tag, key, member, score)
+}
+
+func (s *ShardedImpl) RedisZRank(_ string, key string, member string) (int, error) {
+
+	return s.Dal.RedisZRank(s.ShardOf(key), key, member)
+}
+
+func (s *ShardedImpl) RedisZRange(_ string, key string, start int, stop int) ([]string, error) {
+
+	return s.Dal.RedisZRange(s.ShardOf(key), key, start, stop)
+}
+
+func (s *ShardedImpl) RedisZRangeWithScores(_ string, key string, start int, stop int) ([]redis.Z, error) {
+
+	return s.Dal.RedisZRangeWithScores(s.ShardOf(key), key, start, stop)
+}
+
+func (s *ShardedImpl) RedisZRem(_ string, key string, member string) error {
+
+	tag, done, err := s.writeShard(key)
+	if nil != err {
+		return err
+	}
+	defer done()
+
+	return s.Dal.RedisZRem(tag, key, member)
+}
+
+func (s *ShardedImpl) RedisZScore(_ string, key string, member string) (float64, bool, error) {
+
+	zs, err := AsSortedSets(s.Dal)
+	if nil != err {
+		return 0, false, err
+	}
+
+	return zs.RedisZScore(s.ShardOf(key), key, member)
+}
+
+func (s *ShardedImpl) RedisZCount(_ string, key string, min string, max string) (int64, error) {
+
+	zs, err := AsSortedSets(s.Dal)
+	if nil != err {
+		return 0, err
+	}
+
+	return zs.RedisZCount(s.ShardOf(key), key, min, max)
+}
+
+func (s *ShardedImpl) RedisZRangeByScoreWithScores(_ string, key string, min string, max string, offset int64, count int64) ([]redis.Z, error) {
+
+	zs, err := AsSortedSets(s.Dal)
+	if nil != err {
+		return nil, err
+	}
+
+	return zs.RedisZRangeByScoreWithScores(s.ShardOf(key), key, min, max, offset, count)
+}
+
+func (s *ShardedImpl) RedisZRemRangeByScore(_ string, key string, min string, max string) (int64, error) {
+
+	tag, done, err := s.writeShard(key)
+	if nil != err {
+		return 0, err
+	}
+	defer done()
+
+	zs, err := AsSortedSets(s.Dal)
+	if nil != err {
+		return 0, err
+	}
+
+	return zs.RedisZRemRangeByScore(tag, key, min, max)
+}
+
+func (s *ShardedImpl) RedisRPUSH(_ string, key string, member string) error {
+
+	tag, done, err := s.writeShard(key)
+	if nil != err {
+		return err
+	}
+	defer done()
+
+	return s.Dal.RedisRPUSH(tag, key, member)
+}
+
+//all keys must share a shard, use a {hash tag}, does not wait for AddShard, see there
+func (s *ShardedImpl) RedisBLPOP(_ string, timeout time.Duration, keys ...string) ([]string, error) {
+
+	tag, err := s.shardOfAll(keys)
+	if nil != err {
+		return nil, err
+	}
+
+	return s.Dal.RedisBLPOP(tag, timeout, keys...)
+}
+
+func (s *ShardedImpl) RedisLLEN(_ string, key string) (int64, error) {
+
+	return s.Dal.RedisLLEN(s.ShardOf(key), key)
+}
+
+func (s *ShardedImpl) RedisLRange(_ string, key string, start int, stop int) ([]string, error) {
+
+	return s.Dal.RedisLRange(s.ShardOf(key), key, start, stop)
+}
+
+//keys matching pattern on every shard
+func (s *ShardedImpl) RedisKeys(_ string, pattern string) ([]string, error) {
+
+	keys := []string{}
+	for _, tag := range s.Shards() {
+		part, err := s.Dal.RedisKeys(tag, pattern)
+		if nil != err {
+			return nil, err
+		}
+		keys = append(keys, part...)
+	}
+
+	return keys, nil
+}
+
+//values with prefix on every shard
+func (s *ShardedImpl) RedisListAllValuesWithPrefix(_ string, prefix string) (map[string]string, error) {
+
+	values := make(map[string]string)
+	for _, tag := range s.Shards() {
+		part, err := s.Dal.RedisListAllValuesWithPrefix(tag, prefix)
+		if nil != err {
+			return nil, err
+		}
+		for k, v := range part {
+			values[k] = v
+		}
+	}
+
+	return values, nil
+}
+
+//delete keys grouped by shard, keys may span shards
+func (s *ShardedImpl) RedisBatchDel(_ string, keys ...string) error {
+
+	tags, done, err := s.writeShards(keys)
+	if nil != err {
+		return err
+	}
+	defer done()
+
+	groups := make(map[string][]string)
+	for i, key := range keys {
+		groups[tags[i]] = append(groups[tags[i]], key)
+	}
+
+	for tag, group := range groups {
+		err := s.Dal.RedisBatchDel(tag, group...)
+		if nil != err {
+			return err
+		}
+	}
+
+	return nil
+}
+
+//set key value pairs grouped by shard, atomic only per shard, keys must be strings
+func (s *ShardedImpl) RedisMset(_ string, pairs ...interface{}) error {
+
+	if len(pairs)%2 != 0 {
+		return errors.New("redis: odd number of mset arguments")
+	}
+
+	keys := make([]string, 0, len(pairs)/2)
+	for i := 0; i < len(pairs); i += 2 {
+		key, ok := pairs[i].(string)
+		if !ok {
+			return errors.New("redis: sharded mset keys must be strings")
+		}
+		keys = append(keys, key)
+	}
+
+	tags, done, err := s.writeShards(keys)
+	if nil != err {
+		return err
+	}
+	defer done()
+
+	groups := make(map[string][]interface{})
+	for i, key := range keys {
+		groups[tags[i]] = append(groups[tags[i]], key, pairs[2*i+1])
+	}
+
+	for tag, group := range groups {
+		err := s.Dal.RedisMset(tag, group...)
+		if nil != err {
+			return err
+		}
+	}
+
+	return nil
+}
+
+//publish on the first shard, subscribers must use GetClient
+func (s *ShardedImpl) RedisPublish(redisTag string, channel string, message interface{}) error {
+
+	cli, err := s.GetClient(redisTag)
+	if nil != err {
+		return err
+	}
+
+	err = cli.Publish(channel, message).Err()
+	if nil != err {
+		logrus.Error("RedisPublish Error! channel:", channel, "Details:", err.Error())
+	}
+
+	return err
+}
+
+func (s *ShardedImpl) RedisIncr(_ string, key string) (int64, error) {
+
+	tag, done, err := s.writeShard(key)
+	if nil != err {
+		return 0, err
+	}
+	defer done()
+
+	counters, err := AsCounters(s.Dal)
+	if nil != err {
+		return 0, err
+	}
+
+	return counters.RedisIncr(tag, key)
+}
+
+func (s *ShardedImpl) RedisIncrBy(_ string, key string, delta int64) (int64, error) {
+
+	tag, done, err := s.writeShard(key)
+	if nil != err {
+		return 0, err
+	}
+	defer done()
+
+	counters, err := AsCounters(s.Dal)
+	if nil != err {
+		return 0, err
+	}
+
+	return counters.RedisIncrBy(tag, key, delta)
+}
+
+func (s *ShardedImpl) RedisIncrByFloat(_ string, key string, delta float64) (float64, error) {
+
+	tag, done, err := s.writeShard(key)
+	if nil != err {
+		return 0.0, err
+	}
+	defer done()
+
+	counters, err := AsCounters(s.Dal)
+	if nil != err {
+		return 0, err
+	}
+
+	return counters.RedisIncrByFloat(tag, key, delta)
+}
+
+func (s *ShardedImpl) RedisDecr(_ string, key string) (int64, error) {
+
+	tag, done, err := s.writeShard(key)
+	if nil != err {
+		return 0, err
+	}
+	defer done()
+
+	counters, err := AsCounters(s.Dal)
+	if nil != err {
+		return 0, err
+	}
+
+	return counters.RedisDecr(tag, key)
+}
+
+func (s *ShardedImpl) RedisDecrBy(_ string, key string, delta int64) (int64, error) {
+
+	tag, done, err := s.writeShard(key)
+	if nil != err {
+		return 0, err
+	}
+	defer done()
+
+	counters, err := AsCounters(s.Dal)
+	if nil != err {
+		return 0, err
+	}
+
+	return counters.RedisDecrBy(tag, key, delta)
+}
+
+func (s *ShardedImpl) RedisIncrWithExpire(_ string, key string, delta int64, expire int) (int64, error) {
+
+	tag, done, err := s.writeShard(key)
+	if nil != err {
+		return 0, err
+	}
+	defer done()
+
+	counters, err := AsCounters(s.Dal)
+	if nil != err {
+		return 0, err
+	}
+
+	return counters.RedisIncrWithExpire(tag, key, delta, expire)
+}
+
+func (s *ShardedImpl) RedisIncrCapped(_ string, key string, delta int64, limit int64, expire int) (int64, bool, error) {
+
+	tag, done, err := s.writeShard(key)
+	if nil != err {
+		return 0, false, err
+	}
+	defer done()
+
+	counters, err := AsCounters(s.Dal)
+	if nil != err {
+		return 0, false, err
+	}
+
+	return counters.RedisIncrCapped(tag, key, delta, limit, expire)
+}
+
+//read counters grouped by shard, values keep the order of keys
+func (s *ShardedImpl) RedisMGetInt64(_ string, keys ...string) ([]int64, error) {
+
+	counters, err := AsCounters(s.Dal)
+	if nil != err {
+		return nil, err
+	}
+
+	r, m := s.routing()
+	groups := make(map[string][]int)
+	for i, key := range keys {
+		tag := holderOf(r, m, key)
+		groups[tag] = append(groups[tag], i)
+	}
+
+	values := make([]int64, len(keys))
+	for tag, indexes := range groups {
+		group := make([]string, len(indexes))
+		for j, i := range indexes {
+			group[j] = keys[i]
+		}
+		part, err := counters.RedisMGetInt64(tag, group...)
+		if nil != err {
+			return nil, err
+		}
+		for j, i := range indexes {
+			values[i] = part[j]
+		}
+	}
+
+	return values, nil
+}
@@ -0,0 +1,280 @@
+/**
+ * @Author KYIMH
+ * @Description
+ * @Date 2026/10/19 11:05
+ **/
+
+package resilience
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	defaultFailureThreshold = 5
+	defaultOpenTimeout      = 10 * time.Second
+	defaultHalfOpenMaxCalls = 1
+)
+
+//BreakerImpl -> consecutive failure circuit breaker
+//generation: bumped on every state change, results of calls allowed in an older generation are dropped
+type BreakerImpl struct {
+	name   string
+	config BreakerConfig
+
+	mu         sync.Mutex
+	state      State
+	generation uint64
+	failures   int
+	openedAt   time.Time
+	inFlight   int
+	successes  int
+	changes    []stateChange
+}
+
+type stateChange struct {
+	from State
+	to   State
+}
+
+//create new circuit breaker, zero fields of config take the defaults
+func NewBreaker(name string, config BreakerConfig) *BreakerImpl {
+
+	if config.FailureThreshold <= 0 {
+		config.FailureThreshold = defaultFailureThreshold
+	}
+	if config.OpenTimeout <= 0 {
+		config.OpenTimeout = defaultOpenTimeout
+	}
+	if config.HalfOpenMaxCalls <= 0 {
+		config.HalfOpenMaxCalls = defaultHalfOpenMaxCalls
+	}
+	if nil == config.IsFailure {
+		config.IsFailure = DefaultIsFailure
+	}
+
+	return &BreakerImpl{
+		name:   name,
+		config: config,
+		state:  StateClosed,
+	}
+}
+
+func (b *BreakerImpl) Name() string {
+
+	return b.name
+}
+
+//current state, an open breaker whose timeout elapsed reports half-open
+func (b *BreakerImpl) State() State {
+
+	b.mu.Lock()
+	defer b.unlock()
+
+	b.refresh(time.Now())
+	return b.state
+}
+
+//ask for permission to call the backend, report the result of the call with done, only the first call of done counts
+//a call still running when the breaker changes state is not counted, so a slow success from before the breaker
+//opened cannot close it again
+func (b *BreakerImpl) Allow() (func(err error), error) {
+
+	b.mu.Lock()
+	defer b.unlock()
+
+	b.refresh(time.Now())
+
+	switch b.state {
+	case StateOpen:
+		return nil, ErrCircuitOpen
+	case StateHalfOpen:
+		if b.inFlight >= b.config.HalfOpenMaxCalls {
+			return nil, ErrTooManyRequests
+		}
+	}
+
+	b.inFlight++
+	generation := b.generation
+
+	var once sync.Once
+	return func(err error) {
+		once.Do(func() {
+			b.done(generation, err)
+		})
+	}, nil
+}
+
+//count the result of a call allowed in generation
+func (b *BreakerImpl) done(generation uint64, err error) {
+
+	b.mu.Lock()
+	defer b.unlock()
+
+	if generation != b.generation {
+		return
+	}
+	if b.inFlight > 0 {
+		b.inFlight--
+	}
+
+	failed := b.config.IsFailure(err)
+
+	switch b.state {
+	case StateClosed:
+		if !failed {
+			b.failures = 0
+			return
+		}
+		b.failures++
+		if b.failures >= b.config.FailureThreshold {
+			b.setState(StateOpen)
+		}
+	case StateHalfOpen:
+		if failed {
+			b.setState(StateOpen)
+			return
+		}
+		b.successes++
+		if b.successes >= b.config.HalfOpenMaxCalls {
+			b.setState(StateClosed)
+		}
+	}
+}
+
+//call fn through the breaker
+func (b *BreakerImpl) Execute(fn func() error) error {
+
+	done, err := b.Allow()
+	if nil != err {
+		return err
+	}
+
+	err = fn()
+	done(err)
+
+	return err
+}
+
+//force the breaker back to closed
+func (b *BreakerImpl) Reset() {
+
+	b.mu.Lock()
+	defer b.unlock()
+
+	b.setState(StateClosed)
+}
+
+//move an open breaker to half-open after OpenTimeout, caller holds mu
+func (b *BreakerImpl) refresh(now time.Time) {
+
+	if b.state == StateOpen && now.Sub(b.openedAt) >= b.config.OpenTimeout {
+		b.setState(StateHalfOpen)
+	}
+}
+
+//caller holds mu
+func (b *BreakerImpl) setState(state State) {
+
+	from := b.state
+
+	//calls in flight belong to the old generation and are no longer counted
+	b.generation++
+	b.state = state
+	b.failures = 0
+	b.successes = 0
+	b.inFlight = 0
+	if state == StateOpen {
+		b.openedAt = time.Now()
+	}
+
+	if from != state && nil != b.config.OnStateChange {
+		b.changes = append(b.changes, stateChange{from: from, to: state})
+	}
+}
+
+//release mu, then fire the state change callbacks so they may query the breaker
+func (b *BreakerImpl) unlock() {
+
+	changes := b.changes
+	b.changes = nil
+	b.mu.Unlock()
+
+	for _, change := range changes {
+		b.config.OnStateChange(b.name, change.from, change.to)
+	}
+}
+
+//GuardImpl -> retry policy plus one breaker per backend name
+//Policy: nil means no retry
+//Config: config of breakers created on demand
+type GuardImpl struct {
+	Policy *RetryPolicy
+	Config BreakerConfig
+
+	mu       sync.Mutex
+	breakers map[string]*BreakerImpl
+}
+
+//create new guard
+func NewGuard(policy *RetryPolicy, config BreakerConfig) *GuardImpl {
+
+	return &GuardImpl{
+		Policy:   policy,
+		Config:   config,
+		breakers: make(map[string]*BreakerImpl),
+	}
+}
+
+//get the breaker of name, create it if not exists
+func (g *GuardImpl) Breaker(name string) Breaker {
+
+	return g.breaker(name)
+}
+
+func (g *GuardImpl) breaker(name string) *BreakerImpl {
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	//a GuardImpl built by struct literal has no map yet
+	if nil == g.breakers {
+		g.breakers = make(map[string]*BreakerImpl)
+	}
+
+	b, ok := g.breakers[name]
+	if !ok {
+		b = NewBreaker(name, g.Config)
+		g.breakers[name] = b
+	}
+
+	return b
+}
+
+//call fn with retry, every attempt goes through the breaker of name
+func (g *GuardImpl) Do(name string, fn func() error) error {
+
+	b := g.breaker(name)
+	call := func() error {
+		return b.Execute(fn)
+	}
+
+	if nil == g.Policy {
+		return call()
+	}
+
+	return g.Policy.Do(call)
+}
+
+//guard a call of redis.Dal on redisTag
+func (g *GuardImpl) DoRedis(redisTag string, fn func() error) error {
+
+	return g.Do("redis:"+redisTag, fn)
+}
+
+//guard a call of mongo.MogDal on dbName
+func (g *GuardImpl) DoMongo(dbName string, fn func() error) error {
+
+	return g.Do("mongo:"+dbName, fn)
+}
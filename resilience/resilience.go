@@ -0,0 +1,86 @@
+/**
+ * @Author KYIMH
+ * @Description
+ * @Date 2026/10/19 11:05
+ **/
+
+package resilience
+
+import (
+	"errors"
+	"time"
+)
+
+//ErrCircuitOpen -> returned without calling the backend while the breaker is open
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+//ErrTooManyRequests -> returned while the breaker is half-open and all probe calls are in flight
+var ErrTooManyRequests = errors.New("circuit breaker is half-open, too many requests")
+
+//State -> state of a circuit breaker
+type State int
+
+const (
+	StateClosed   State = iota // calls pass through, failures are counted
+	StateHalfOpen              // a limited number of probe calls pass through
+	StateOpen                  // calls fail fast with ErrCircuitOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateHalfOpen:
+		return "half-open"
+	case StateOpen:
+		return "open"
+	}
+	return "unknown"
+}
+
+//RetryPolicy -> how a failed call is retried
+//MaxAttempts: total attempts including the first one, <= 1 means no retry
+//InitialBackoff: wait before the second attempt
+//MaxBackoff: upper bound of a single wait
+//Multiplier: backoff grows by Multiplier after each attempt
+//Jitter: 0 ~ 1, fraction of the backoff that is randomized
+//Retryable: decides whether an error is worth another attempt, DefaultRetryable if nil
+type RetryPolicy struct {
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Multiplier     float64
+	Jitter         float64
+	Retryable      func(err error) bool
+}
+
+//BreakerConfig -> when a circuit breaker trips and recovers
+//FailureThreshold: consecutive failures that open the breaker
+//OpenTimeout: time the breaker stays open before going half-open
+//HalfOpenMaxCalls: probe calls allowed while half-open, all must succeed to close
+//IsFailure: decides whether an error counts as a backend failure, DefaultIsFailure if nil
+//OnStateChange: called after every state change
+type BreakerConfig struct {
+	FailureThreshold int
+	OpenTimeout      time.Duration
+	HalfOpenMaxCalls int
+	IsFailure        func(err error) bool
+	OnStateChange    func(name string, from State, to State)
+}
+
+//circuit breaker operators
+type Breaker interface {
+	Name() string
+	State() State
+	Allow() (done func(err error), err error)
+	Execute(fn func() error) error
+	Reset()
+}
+
+//retry and circuit breaker operators keyed by backend name
+type Guard interface {
+	Breaker(name string) Breaker
+	Do(name string, fn func() error) error
+	DoRedis(redisTag string, fn func() error) error
+	DoMongo(dbName string, fn func() error) error
+}
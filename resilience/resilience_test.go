@@ -0,0 +1,65 @@
+/**
+ * @Author KYIMH
+ * @Description
+ * @Date 2026/10/20 07:40
+ **/
+
+package resilience_test
+
+import (
+	"errors"
+	"github.com/KYIMH/CCS_Utils/resilience"
+	"testing"
+	"time"
+)
+
+var errBackend = errors.New("backend down")
+
+func TestBreakerIgnoresStaleResults(t *testing.T) {
+
+	b := resilience.NewBreaker("mongo", resilience.BreakerConfig{
+		FailureThreshold: 1,
+		OpenTimeout:      20 * time.Millisecond,
+	})
+
+	//a slow call allowed while closed finishes after the breaker opened and went half-open
+	slow, err := b.Allow()
+	if nil != err {
+		t.Fatalf("Allow = %v", err)
+	}
+	err = b.Execute(func() error { return errBackend })
+	if errBackend != err || resilience.StateOpen != b.State() {
+		t.Fatalf("Execute = %v, %v", err, b.State())
+	}
+	time.Sleep(30 * time.Millisecond)
+	probe, err := b.Allow()
+	if nil != err {
+		t.Fatalf("Allow = %v", err)
+	}
+
+	slow(nil)
+	if resilience.StateHalfOpen != b.State() {
+		t.Fatalf("State after stale success = %v", b.State())
+	}
+	_, err = b.Allow()
+	if resilience.ErrTooManyRequests != err {
+		t.Fatalf("Allow while probing = %v", err)
+	}
+
+	probe(nil)
+	probe(errBackend)
+	if resilience.StateClosed != b.State() {
+		t.Fatalf("State after probe = %v", b.State())
+	}
+
+	//a failure allowed before Reset does not count against the new generation
+	stale, err := b.Allow()
+	if nil != err {
+		t.Fatalf("Allow = %v", err)
+	}
+	b.Reset()
+	stale(errBackend)
+	if resilience.StateClosed != b.State() {
+		t.Fatalf("State after stale failure = %v", b.State())
+	}
+}
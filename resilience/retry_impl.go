@@ -0,0 +1,186 @@
+/**
+ * @Author KYIMH
+ * @Description
+ * @Date 2026/10/19 11:05
+ **/
+
+package resilience
+
+import (
+	"context"
+	"errors"
+	"github.com/go-redis/redis"
+	"github.com/qiniu/qmgo"
+	"go.mongodb.org/mongo-driver/mongo"
+	"io"
+	"math"
+	"math/rand"
+	"net"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+//redis error prefixes which mean the server may accept the command later
+var retryableRedisPrefixes = []string{"LOADING", "READONLY", "MASTERDOWN", "TRYAGAIN", "CLUSTERDOWN", "BUSY"}
+
+var (
+	randMu  sync.Mutex
+	randSrc = rand.New(rand.NewSource(time.Now().UnixNano()))
+)
+
+//create a retry policy with exponential backoff, maxAttempts can be MgoConfig.RetryTimes
+func NewRetryPolicy(maxAttempts int) *RetryPolicy {
+
+	return &RetryPolicy{
+		MaxAttempts:    maxAttempts,
+		InitialBackoff: 8 * time.Millisecond,
+		MaxBackoff:     512 * time.Millisecond,
+		Multiplier:     2,
+		Jitter:         0.2,
+	}
+}
+
+//error is a transient network or server error and the call can be repeated
+func DefaultRetryable(err error) bool {
+
+	if nil == err || !DefaultIsFailure(err) {
+		return false
+	}
+
+	if errors.Is(err, ErrCircuitOpen) || errors.Is(err, ErrTooManyRequests) {
+		return false
+	}
+
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+
+	if errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+
+	if mongo.IsNetworkError(err) || mongo.IsTimeout(err) {
+		return true
+	}
+
+	var labeled interface{ HasErrorLabel(string) bool }
+	if errors.As(err, &labeled) {
+		if labeled.HasErrorLabel("RetryableWriteError") || labeled.HasErrorLabel("TransientTransactionError") {
+			return true
+		}
+	}
+
+	msg := err.Error()
+	for _, prefix := range retryableRedisPrefixes {
+		if strings.HasPrefix(msg, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+//error tells the backend is unhealthy, misses like redis.Nil and no document are not failures
+func DefaultIsFailure(err error) bool {
+
+	if nil == err {
+		return false
+	}
+
+	if err == redis.Nil || errors.Is(err, qmgo.ErrNoSuchDocuments) {
+		return false
+	}
+
+	if mongo.IsDuplicateKeyError(err) {
+		return false
+	}
+
+	return true
+}
+
+//wait before the given attempt, attempt starts from 1 for the first retry
+func (p *RetryPolicy) Backoff(attempt int) time.Duration {
+
+	if attempt < 1 || p.InitialBackoff <= 0 {
+		return 0
+	}
+
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+
+	backoff := float64(p.InitialBackoff) * math.Pow(multiplier, float64(attempt-1))
+	if p.MaxBackoff > 0 && backoff > float64(p.MaxBackoff) {
+		backoff = float64(p.MaxBackoff)
+	}
+
+	jitter := p.Jitter
+	if jitter > 1 {
+		jitter = 1
+	}
+	if jitter > 0 {
+		randMu.Lock()
+		r := randSrc.Float64()
+		randMu.Unlock()
+		//spread the backoff over [backoff*(1-jitter), backoff]
+		backoff -= backoff * jitter * r
+	}
+
+	return time.Duration(backoff)
+}
+
+func (p *RetryPolicy) retryable(err error) bool {
+
+	if nil != p.Retryable {
+		return p.Retryable(err)
+	}
+
+	return DefaultRetryable(err)
+}
+
+//call fn until it succeeds, returns a non retryable error or runs out of attempts
+func (p *RetryPolicy) Do(fn func() error) error {
+
+	return p.DoContext(context.Background(), fn)
+}
+
+//same as Do, but stop waiting when ctx is done
+func (p *RetryPolicy) DoContext(ctx context.Context, fn func() error) error {
+
+	attempts := p.MaxAttempts
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	var err error
+	for attempt := 0; attempt < attempts; attempt++ {
+		if attempt > 0 {
+			timer := time.NewTimer(p.Backoff(attempt))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return err
+			case <-timer.C:
+			}
+		}
+
+		err = fn()
+		if nil == err || !p.retryable(err) {
+			return err
+		}
+	}
+
+	return err
+}
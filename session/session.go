@@ -0,0 +1,64 @@
+/**
+ * @Author KYIMH
+ * @Description
+ * @Date 2026/10/19 19:00
+ **/
+
+package session
+
+import (
+	"errors"
+	"github.com/KYIMH/CCS_Utils/share/codec"
+	"time"
+)
+
+//ErrNotFound -> the session does not exist or has expired
+var ErrNotFound = errors.New("session: not found")
+
+//Session -> a chat user session
+//Id: random url safe id, hand it to the client
+//Data: payload encoded by the store codec, decode it with Decode
+type Session struct {
+	Id        string    `json:"id"`
+	UserId    string    `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+	Data      []byte    `json:"data"`
+
+	codec codec.Codec
+}
+
+//decode the session payload into v, with codec.JSON when the session was not read from a store
+func (s *Session) Decode(v interface{}) error {
+
+	if len(s.Data) == 0 {
+		return nil
+	}
+	if nil == s.codec {
+		return codec.JSON{}.Unmarshal(s.Data, v)
+	}
+
+	return s.codec.Unmarshal(s.Data, v)
+}
+
+//Config -> session store config
+//RedisTag: redis tag of the pool holding the sessions
+//Prefix: key prefix, example: ccs:sess:
+//TTL: idle time after which a session expires, renewed by Touch
+//Codec: payload codec, codec.JSON if nil
+type Config struct {
+	RedisTag string
+	Prefix   string
+	TTL      time.Duration
+	Codec    codec.Codec
+}
+
+//session store operators
+type Store interface {
+	Create(userId string, payload interface{}) (*Session, error)
+	Get(id string) (*Session, error)
+	Touch(id string) error
+	Save(s *Session, payload interface{}) error
+	Destroy(id string) error
+	ListByUser(userId string) ([]*Session, error)
+	RevokeUser(userId string) error
+}
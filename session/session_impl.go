@@ -0,0 +1,325 @@
+/**
+ * @Author KYIMH
+ * @Description
+ * @Date 2026/10/19 19:00
+ **/
+
+package session
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/KYIMH/CCS_Utils/redis"
+	"github.com/KYIMH/CCS_Utils/share/codec"
+	"github.com/sirupsen/logrus"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultPrefix = "ccs:sess:"
+	defaultTTL    = 30 * time.Minute
+	idBytes       = 32
+)
+
+//record -> value stored under the session key
+type record struct {
+	UserId    string    `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+	Data      []byte    `json:"data"`
+}
+
+//StoreImpl -> session store on the redis pool
+//sessions live in string keys with a sliding TTL,
+//every user has a sorted set index of its session ids scored by expire time
+//Touch and Save need Redis to be a redis.XXSetter
+type StoreImpl struct {
+	Redis  redis.Dal
+	Config Config
+}
+
+//create new session store, zero fields of config take the defaults
+func NewStore(redisDal redis.Dal, config Config) *StoreImpl {
+
+	if "" == config.Prefix {
+		config.Prefix = defaultPrefix
+	}
+	if config.TTL <= 0 {
+		config.TTL = defaultTTL
+	}
+	if nil == config.Codec {
+		config.Codec = codec.JSON{}
+	}
+
+	return &StoreImpl{
+		Redis:  redisDal,
+		Config: config,
+	}
+}
+
+func (s *StoreImpl) sessionKey(id string) string {
+
+	return s.Config.Prefix + "s:" + id
+}
+
+func (s *StoreImpl) userKey(userId string) string {
+
+	return s.Config.Prefix + "u:" + userId
+}
+
+//TTL in whole seconds for RedisExpire, at least 1
+func (s *StoreImpl) ttlSeconds() int {
+
+	seconds := int((s.Config.TTL + time.Second - 1) / time.Second)
+	if seconds < 1 {
+		seconds = 1
+	}
+
+	return seconds
+}
+
+//generate a session id from crypto/rand
+func NewId() (string, error) {
+
+	buf := make([]byte, idBytes)
+	_, err := rand.Read(buf)
+	if nil != err {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+//view of Redis reading from the primary, for reads followed by a write or a delete
+func (s *StoreImpl) primary() redis.Dal {
+
+	return redis.PrimaryOf(s.Redis)
+}
+
+//write the record with a fresh TTL and index it under the user
+func (s *StoreImpl) write(id string, rec *record) error {
+
+	value, err := json.Marshal(rec)
+	if nil != err {
+		return err
+	}
+
+	err = s.Redis.RedisSet(s.Config.RedisTag, s.sessionKey(id), value, int(s.Config.TTL/time.Millisecond))
+	if nil != err {
+		return err
+	}
+
+	return s.index(id, rec.UserId)
+}
+
+//add or refresh id in the user index, the index lives as long as its newest session
+func (s *StoreImpl) index(id string, userId string) error {
+
+	expireAt := time.Now().Add(s.Config.TTL).UnixNano() / int64(time.Millisecond)
+
+	err := s.Redis.RedisZAdd(s.Config.RedisTag, s.userKey(userId), id, strconv.FormatInt(expireAt, 10))
+	if nil != err {
+		return err
+	}
+
+	return s.Redis.RedisExpire(s.Config.RedisTag, s.userKey(userId), s.ttlSeconds())
+}
+
+func (s *StoreImpl) read(dal redis.Dal, id string) (*record, error) {
+
+	value, err := dal.RedisGetResult(s.Config.RedisTag, s.sessionKey(id))
+	if nil != err {
+		return nil, err
+	}
+
+	str, ok := value.(string)
+	if !ok || "" == str {
+		return nil, ErrNotFound
+	}
+
+	rec := new(record)
+	err = json.Unmarshal([]byte(str), rec)
+	if nil != err {
+		return nil, err
+	}
+
+	return rec, nil
+}
+
+//create a session of userId holding payload, payload may be nil
+func (s *StoreImpl) Create(userId string, payload interface{}) (*Session, error) {
+
+	id, err := NewId()
+	if nil != err {
+		return nil, err
+	}
+
+	rec := &record{UserId: userId, CreatedAt: time.Now()}
+	if nil != payload {
+		rec.Data, err = s.Config.Codec.Marshal(payload)
+		if nil != err {
+			return nil, err
+		}
+	}
+
+	err = s.write(id, rec)
+	if nil != err {
+		return nil, err
+	}
+
+	return s.toSession(id, rec), nil
+}
+
+func (s *StoreImpl) toSession(id string, rec *record) *Session {
+
+	return &Session{
+		Id:        id,
+		UserId:    rec.UserId,
+		CreatedAt: rec.CreatedAt,
+		Data:      rec.Data,
+		codec:     s.Config.Codec,
+	}
+}
+
+//get a session without renewing it, ErrNotFound if it does not exist
+func (s *StoreImpl) Get(id string) (*Session, error) {
+
+	rec, err := s.read(s.Redis, id)
+	if nil != err {
+		return nil, err
+	}
+
+	return s.toSession(id, rec), nil
+}
+
+//renew the TTL of a session, ErrNotFound if it has already expired
+//the renew only touches a live key, so a session destroyed or revoked meanwhile is not brought back
+func (s *StoreImpl) Touch(id string) error {
+
+	xx, err := redis.AsXXSetter(s.Redis)
+	if nil != err {
+		return err
+	}
+
+	rec, err := s.read(s.primary(), id)
+	if nil != err {
+		return err
+	}
+
+	ok, err := xx.RedisPExpire(s.Config.RedisTag, s.sessionKey(id), int(s.Config.TTL/time.Millisecond))
+	if nil != err {
+		return err
+	}
+	if !ok {
+		return ErrNotFound
+	}
+
+	return s.index(id, rec.UserId)
+}
+
+//replace the payload of a session and renew its TTL, ErrNotFound if it has expired
+//the write only replaces a live key, so a session destroyed or revoked after it was read is not brought back
+func (s *StoreImpl) Save(sess *Session, payload interface{}) error {
+
+	xx, err := redis.AsXXSetter(s.Redis)
+	if nil != err {
+		return err
+	}
+
+	data, err := s.Config.Codec.Marshal(payload)
+	if nil != err {
+		return err
+	}
+
+	rec, err := s.read(s.primary(), sess.Id)
+	if nil != err {
+		return err
+	}
+	rec.Data = data
+
+	value, err := json.Marshal(rec)
+	if nil != err {
+		return err
+	}
+
+	ok, err := xx.RedisSetXX(s.Config.RedisTag, s.sessionKey(sess.Id), value, int(s.Config.TTL/time.Millisecond))
+	if nil != err {
+		return err
+	}
+	if !ok {
+		return ErrNotFound
+	}
+
+	//a revoke landing right here leaves a dangling index entry, ListByUser prunes it
+	err = s.index(sess.Id, rec.UserId)
+	if nil != err {
+		return err
+	}
+	sess.Data = data
+
+	return nil
+}
+
+//delete a session, deleting a missing session is not an error
+func (s *StoreImpl) Destroy(id string) error {
+
+	rec, err := s.read(s.primary(), id)
+	if err == ErrNotFound {
+		return nil
+	}
+	if nil != err {
+		return err
+	}
+
+	err = s.Redis.RedisDel(s.Config.RedisTag, s.sessionKey(id))
+	if nil != err {
+		return err
+	}
+
+	return s.Redis.RedisZRem(s.Config.RedisTag, s.userKey(rec.UserId), id)
+}
+
+//list live sessions of userId, expired ids are pruned from the index on the way
+func (s *StoreImpl) ListByUser(userId string) ([]*Session, error) {
+
+	ids, err := s.Redis.RedisZRange(s.Config.RedisTag, s.userKey(userId), 0, -1)
+	if nil != err {
+		return nil, err
+	}
+
+	sessions := make([]*Session, 0, len(ids))
+	for _, id := range ids {
+		rec, err := s.read(s.Redis, id)
+		if err == ErrNotFound {
+			if remErr := s.Redis.RedisZRem(s.Config.RedisTag, s.userKey(userId), id); nil != remErr {
+				logrus.Error("session prune Error! user:", userId, "id:", id, "Details:", remErr.Error())
+			}
+			continue
+		}
+		if nil != err {
+			return nil, err
+		}
+		sessions = append(sessions, s.toSession(id, rec))
+	}
+
+	return sessions, nil
+}
+
+//delete every session of userId
+//the index is read from the primary, a session indexed by a lagging replica must not survive
+func (s *StoreImpl) RevokeUser(userId string) error {
+
+	ids, err := s.primary().RedisZRange(s.Config.RedisTag, s.userKey(userId), 0, -1)
+	if nil != err {
+		return err
+	}
+
+	keys := make([]string, 0, len(ids)+1)
+	for _, id := range ids {
+		keys = append(keys, s.sessionKey(id))
+	}
+	keys = append(keys, s.userKey(userId))
+
+	return s.Redis.RedisBatchDel(s.Config.RedisTag, keys...)
+}
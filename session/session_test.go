@@ -0,0 +1,126 @@
+/**
+ * @Author KYIMH
+ * @Description
+ * @Date 2026/10/20 03:20
+ **/
+
+package session_test
+
+import (
+	"github.com/KYIMH/CCS_Utils/redis"
+	"github.com/KYIMH/CCS_Utils/redis/redistest"
+	"github.com/KYIMH/CCS_Utils/session"
+	"testing"
+	"time"
+)
+
+const tag = "ccs"
+
+func newClient(t *testing.T) *redis.ClientImpl {
+
+	srv, cli, err := redistest.NewClientImpl(tag)
+	if nil != err {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		cli.Close()
+		srv.Close()
+	})
+
+	return cli
+}
+
+//racingDal -> runs afterRead once right after the next session read, before the store writes
+type racingDal struct {
+	*redis.ClientImpl
+	afterRead func()
+}
+
+func (d *racingDal) RedisGetResult(redisTag string, key string) (interface{}, error) {
+
+	value, err := d.ClientImpl.RedisGetResult(redisTag, key)
+	if hook := d.afterRead; nil != hook {
+		d.afterRead = nil
+		hook()
+	}
+
+	return value, err
+}
+
+//reads of the store go through the hook whatever view it asks for
+func (d *racingDal) Primary() redis.Dal {
+
+	return d
+}
+
+func TestStore(t *testing.T) {
+
+	store := session.NewStore(newClient(t), session.Config{RedisTag: tag, TTL: time.Minute})
+
+	sess, err := store.Create("u1", map[string]string{"lang": "en"})
+	if nil != err {
+		t.Fatal(err)
+	}
+
+	got, err := store.Get(sess.Id)
+	if nil != err {
+		t.Fatal(err)
+	}
+	var data map[string]string
+	if err := got.Decode(&data); nil != err || "en" != data["lang"] {
+		t.Fatalf("Decode = %v, %v", data, err)
+	}
+
+	if err := store.RevokeUser("u1"); nil != err {
+		t.Fatal(err)
+	}
+	if _, err := store.Get(sess.Id); err != session.ErrNotFound {
+		t.Fatalf("Get of a revoked session = %v", err)
+	}
+}
+
+func TestStoreRevokeRaces(t *testing.T) {
+
+	dal := &racingDal{ClientImpl: newClient(t)}
+	store := session.NewStore(dal, session.Config{RedisTag: tag, TTL: time.Minute})
+
+	sess, err := store.Create("u1", map[string]string{"lang": "en"})
+	if nil != err {
+		t.Fatal(err)
+	}
+	got, err := store.Get(sess.Id)
+	if nil != err {
+		t.Fatal(err)
+	}
+
+	revoke := func() {
+		if err := store.RevokeUser("u1"); nil != err {
+			t.Error(err)
+		}
+	}
+
+	//RevokeUser lands between the read and the write of Save
+	dal.afterRead = revoke
+	if err := store.Save(got, map[string]string{"lang": "fr"}); err != session.ErrNotFound {
+		t.Fatalf("Save racing RevokeUser = %v", err)
+	}
+	if _, err := store.Get(sess.Id); err != session.ErrNotFound {
+		t.Fatalf("Get after Save racing RevokeUser = %v", err)
+	}
+	if list, err := store.ListByUser("u1"); nil != err || 0 != len(list) {
+		t.Fatalf("ListByUser after Save racing RevokeUser = %v, %v", list, err)
+	}
+
+	//same for the read and the renew of Touch
+	sess, err = store.Create("u1", nil)
+	if nil != err {
+		t.Fatal(err)
+	}
+	dal.afterRead = revoke
+	if err := store.Touch(sess.Id); err != session.ErrNotFound {
+		t.Fatalf("Touch racing RevokeUser = %v", err)
+	}
+	if _, err := store.Get(sess.Id); err != session.ErrNotFound {
+		t.Fatalf("Get after Touch racing RevokeUser = %v", err)
+	}
+}
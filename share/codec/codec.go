@@ -0,0 +1,51 @@
+/**
+ * @Author KYIMH
+ * @Description
+ * @Date 2026/10/19 19:00
+ **/
+
+package codec
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+)
+
+//Codec -> turn a value into bytes stored in redis and back
+type Codec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+//JSON -> encoding/json codec
+type JSON struct{}
+
+func (JSON) Marshal(v interface{}) ([]byte, error) {
+
+	return json.Marshal(v)
+}
+
+func (JSON) Unmarshal(data []byte, v interface{}) error {
+
+	return json.Unmarshal(data, v)
+}
+
+//Gob -> encoding/gob codec, keeps go types but is only readable from go
+type Gob struct{}
+
+func (Gob) Marshal(v interface{}) ([]byte, error) {
+
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(v)
+	if nil != err {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (Gob) Unmarshal(data []byte, v interface{}) error {
+
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
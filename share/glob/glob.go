@@ -0,0 +1,97 @@
+/**
+ * @Author KYIMH
+ * @Description
+ * @Date 2026/10/19 20:10
+ **/
+
+package glob
+
+//Match -> redis style glob match as used by KEYS, SCAN and PSUBSCRIBE
+//supports *, ?, [abc], [^abc], [a-z] and \ escapes, '/' is an ordinary character
+func Match(pattern string, s string) bool {
+
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for len(pattern) > 1 && pattern[1] == '*' {
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 1 {
+				return true
+			}
+			for i := 0; i <= len(s); i++ {
+				if Match(pattern[1:], s[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if len(s) == 0 {
+				return false
+			}
+			s = s[1:]
+			pattern = pattern[1:]
+		case '[':
+			if len(s) == 0 {
+				return false
+			}
+			matched, rest, ok := matchClass(pattern[1:], s[0])
+			if !ok || !matched {
+				return false
+			}
+			s = s[1:]
+			pattern = rest
+		case '\\':
+			if len(pattern) > 1 {
+				pattern = pattern[1:]
+			}
+			fallthrough
+		default:
+			if len(s) == 0 || s[0] != pattern[0] {
+				return false
+			}
+			s = s[1:]
+			pattern = pattern[1:]
+		}
+	}
+
+	return len(s) == 0
+}
+
+//match c against a [...] class, pattern starts after '[', returns the pattern after ']'
+func matchClass(pattern string, c byte) (matched bool, rest string, ok bool) {
+
+	negate := false
+	if len(pattern) > 0 && pattern[0] == '^' {
+		negate = true
+		pattern = pattern[1:]
+	}
+
+	for len(pattern) > 0 && pattern[0] != ']' {
+		lo := pattern[0]
+		if lo == '\\' && len(pattern) > 1 {
+			pattern = pattern[1:]
+			lo = pattern[0]
+		}
+		pattern = pattern[1:]
+
+		hi := lo
+		if len(pattern) > 1 && pattern[0] == '-' && pattern[1] != ']' {
+			hi = pattern[1]
+			pattern = pattern[2:]
+			if lo > hi {
+				lo, hi = hi, lo
+			}
+		}
+
+		if lo <= c && c <= hi {
+			matched = true
+		}
+	}
+
+	if len(pattern) == 0 {
+		return false, "", false
+	}
+
+	return matched != negate, pattern[1:], true
+}
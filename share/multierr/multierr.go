@@ -0,0 +1,67 @@
+/**
+ * @Author KYIMH
+ * @Description
+ * @Date 2026/10/20 00:10
+ **/
+
+package multierr
+
+import (
+	"errors"
+	"strings"
+)
+
+//Errors -> errors collected while going on after a failure, example: closing every client of a pool
+type Errors []error
+
+//append err if it is not nil
+func (e *Errors) Add(err error) {
+
+	if nil != err {
+		*e = append(*e, err)
+	}
+}
+
+//nil when no error was collected, so the result can be returned directly
+func (e Errors) Err() error {
+
+	if len(e) == 0 {
+		return nil
+	}
+
+	return e
+}
+
+func (e Errors) Error() string {
+
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+
+	return strings.Join(msgs, "; ")
+}
+
+//errors.Is matches any collected error
+func (e Errors) Is(target error) bool {
+
+	for _, err := range e {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+
+	return false
+}
+
+//errors.As matches the first collected error of the target type
+func (e Errors) As(target interface{}) bool {
+
+	for _, err := range e {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+
+	return false
+}
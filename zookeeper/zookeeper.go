@@ -15,7 +15,6 @@ type ZkClient interface {
 //zookeeper node and data operators
 type ZkDal interface {
 	SetWatch()
-	watchHandler()
 	GetNodeData(path string) ([]byte, error)
 	Watch()
 }